@@ -73,6 +73,10 @@ func main() {
 			fmt.Printf("Error creating puzzle %s: %v\n", p.name, err)
 			continue
 		}
+		// Seeded puzzles are published so they show up on profiles and dashboards
+		if err := service.SetPuzzleVisibility(ctx, created.ID, createdUsers[p.owner], app.VisibilityPublic); err != nil {
+			fmt.Printf("Error publishing puzzle %s: %v\n", p.name, err)
+		}
 		fmt.Printf("Created puzzle: %s (ID: %s) for %s\n", created.Name, created.ID, p.owner)
 	}
 
@@ -6,6 +6,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"share_word/internal/app"
 	"share_word/internal/db"
 	"share_word/internal/transport"
@@ -28,6 +31,38 @@ func main() {
 		dbPath = "shareword.db"
 	}
 
+	// The event log lives next to the database unless told otherwise
+	natsStoreDir := os.Getenv("NATS_STORE_DIR")
+	if natsStoreDir == "" {
+		natsStoreDir = filepath.Join(filepath.Dir(dbPath), "nats")
+	}
+
+	// Replicas share events through an external NATS server (NATS_URL) or by
+	// clustering their embedded ones (NATS_CLUSTER and NATS_ROUTES)
+	natsCfg := app.Config{
+		NatsURL:           os.Getenv("NATS_URL"),
+		NatsStoreDir:      natsStoreDir,
+		NatsServerName:    os.Getenv("NATS_SERVER_NAME"),
+		NatsCluster:       os.Getenv("NATS_CLUSTER"),
+		NatsClusterListen: os.Getenv("NATS_CLUSTER_LISTEN"),
+	}
+	if natsCfg.NatsServerName == "" {
+		natsCfg.NatsServerName, _ = os.Hostname()
+	}
+	if natsCfg.NatsClusterListen == "" {
+		natsCfg.NatsClusterListen = "0.0.0.0:6222"
+	}
+	if routes := os.Getenv("NATS_ROUTES"); routes != "" {
+		natsCfg.NatsRoutes = strings.Split(routes, ",")
+	}
+	if replicas := os.Getenv("NATS_EVENT_REPLICAS"); replicas != "" {
+		n, err := strconv.Atoi(replicas)
+		if err != nil {
+			log.Fatalf("Invalid NATS_EVENT_REPLICAS: %v", err)
+		}
+		natsCfg.EventReplicas = n
+	}
+
 	env := os.Getenv("ENV")
 	isProd := env == "production"
 
@@ -51,7 +86,7 @@ func main() {
 	}
 
 	queries := db.New(dbConn)
-	service := app.NewService(queries, dbConn)
+	service := app.NewServiceWithConfig(queries, dbConn, natsCfg)
 	defer service.Shutdown()
 	server := transport.NewServer(service, dbConn, isProd)
 
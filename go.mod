@@ -13,6 +13,7 @@ require (
 	github.com/stretchr/testify v1.11.0
 	golang.org/x/crypto v0.46.0
 	golang.org/x/text v0.32.0
+	golang.org/x/time v0.14.0
 	modernc.org/sqlite v1.42.2
 )
 
@@ -29,7 +30,6 @@ require (
 	github.com/nats-io/nuid v1.0.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	golang.org/x/time v0.14.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
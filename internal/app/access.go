@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"share_word/internal/db"
+)
+
+// ErrForbidden is returned when the caller may not perform an action on a puzzle.
+var ErrForbidden = errors.New("forbidden")
+
+// Role is a user's standing on a single puzzle. Roles are ordered: each one
+// can do everything the roles below it can.
+type Role string
+
+const (
+	RoleNone          Role = ""
+	RoleViewer        Role = "viewer"
+	RoleSolver        Role = "solver"
+	RoleCoConstructor Role = "co-constructor"
+	RoleOwner         Role = "owner"
+)
+
+var roleRank = map[Role]int{
+	RoleNone:          0,
+	RoleViewer:        1,
+	RoleSolver:        2,
+	RoleCoConstructor: 3,
+	RoleOwner:         4,
+}
+
+// AtLeast reports whether r grants everything min does.
+func (r Role) AtLeast(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+// CanView reports whether the role may watch the puzzle stream.
+func (r Role) CanView() bool { return r.AtLeast(RoleViewer) }
+
+// CanSolve reports whether the role may type letters in solve mode.
+func (r Role) CanSolve() bool { return r.AtLeast(RoleSolver) }
+
+// CanEdit reports whether the role may change blocks, dimensions and clues.
+func (r Role) CanEdit() bool { return r.AtLeast(RoleCoConstructor) }
+
+// IsAssignable reports whether the role can be given to an invited member.
+func (r Role) IsAssignable() bool {
+	return r == RoleViewer || r == RoleSolver || r == RoleCoConstructor
+}
+
+// Visibility decides who besides the owner and members can find and watch a puzzle.
+type Visibility string
+
+const (
+	// VisibilityPrivate puzzles are only open to the owner and members.
+	VisibilityPrivate Visibility = "private"
+	// VisibilityUnlisted puzzles can be watched by anyone with the link.
+	VisibilityUnlisted Visibility = "unlisted"
+	// VisibilityFollowers puzzles are listed for and watchable by the owner's followers.
+	VisibilityFollowers Visibility = "followers"
+	// VisibilityPublic puzzles are listed for and watchable by everyone.
+	VisibilityPublic Visibility = "public"
+)
+
+// IsValid reports whether v is one of the known visibility levels.
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityPrivate, VisibilityUnlisted, VisibilityFollowers, VisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// PuzzleRole resolves the role userID holds on a puzzle. The owner comes from
+// puzzles.owner_id, members from puzzle_members. Everyone else may watch if
+// the puzzle's visibility lets them, and gets RoleNone otherwise.
+func (s *Service) PuzzleRole(ctx context.Context, puzzleID, userID string) (Role, error) {
+	p, err := s.Queries.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return RoleNone, err
+	}
+
+	if userID != "" {
+		if p.OwnerID == userID {
+			return RoleOwner, nil
+		}
+
+		m, err := s.Queries.GetPuzzleMember(ctx, db.GetPuzzleMemberParams{
+			PuzzleID: puzzleID,
+			UserID:   userID,
+		})
+		if err == nil {
+			return Role(m.Role), nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return RoleNone, err
+		}
+	}
+
+	switch Visibility(p.Visibility) {
+	case VisibilityPublic, VisibilityUnlisted:
+		return RoleViewer, nil
+	case VisibilityFollowers:
+		if userID == "" {
+			return RoleNone, nil
+		}
+		following, err := s.IsFollowing(ctx, userID, p.OwnerID)
+		if err != nil {
+			return RoleNone, err
+		}
+		if following {
+			return RoleViewer, nil
+		}
+	}
+
+	return RoleNone, nil
+}
+
+// AuthorizePuzzle returns the caller's role, or ErrForbidden if it is below min.
+func (s *Service) AuthorizePuzzle(ctx context.Context, puzzleID, userID string, min Role) (Role, error) {
+	role, err := s.PuzzleRole(ctx, puzzleID, userID)
+	if err != nil {
+		return RoleNone, err
+	}
+	if !role.AtLeast(min) {
+		return role, ErrForbidden
+	}
+	return role, nil
+}
+
+// CanEditPuzzle reports whether userID may change the structure of a puzzle
+// (blocks, dimensions, imports and clues).
+func (s *Service) CanEditPuzzle(ctx context.Context, puzzleID, userID string) (bool, error) {
+	role, err := s.PuzzleRole(ctx, puzzleID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role.CanEdit(), nil
+}
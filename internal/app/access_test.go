@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"share_word/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuzzleRole(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	other, err := svc.RegisterUser(ctx, "visitor", "password123456")
+	require.NoError(t, err)
+
+	p, err := svc.CreatePuzzle(ctx, "Owned", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	role, err := svc.PuzzleRole(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleOwner, role)
+
+	role, err = svc.PuzzleRole(ctx, p.ID, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RoleNone, role, "new puzzles are private drafts")
+
+	role, err = svc.PuzzleRole(ctx, p.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, RoleNone, role)
+
+	require.NoError(t, svc.Queries.UpsertPuzzleMember(ctx, db.UpsertPuzzleMemberParams{
+		PuzzleID: p.ID,
+		UserID:   other.ID,
+		Role:     string(RoleCoConstructor),
+	}))
+	ok, err := svc.CanEditPuzzle(ctx, p.ID, other.ID)
+	require.NoError(t, err)
+	assert.True(t, ok, "co-constructors can edit")
+
+	_, err = svc.AuthorizePuzzle(ctx, p.ID, "", RoleSolver)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = svc.AuthorizePuzzle(ctx, p.ID, owner.ID, RoleOwner)
+	assert.NoError(t, err)
+	_, err = svc.AuthorizePuzzle(ctx, "missing", owner.ID, RoleViewer)
+	assert.Error(t, err)
+}
+
+func TestPuzzleVisibility(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	follower, err := svc.RegisterUser(ctx, "follower", "password123456")
+	require.NoError(t, err)
+	stranger, err := svc.RegisterUser(ctx, "stranger", "password123456")
+	require.NoError(t, err)
+	require.NoError(t, svc.FollowUser(ctx, follower.ID, owner.ID))
+
+	p, err := svc.CreatePuzzle(ctx, "Shared", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	tests := []struct {
+		visibility Visibility
+		follower   Role
+		stranger   Role
+		anonymous  Role
+	}{
+		{VisibilityPrivate, RoleNone, RoleNone, RoleNone},
+		{VisibilityUnlisted, RoleViewer, RoleViewer, RoleViewer},
+		{VisibilityFollowers, RoleViewer, RoleNone, RoleNone},
+		{VisibilityPublic, RoleViewer, RoleViewer, RoleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.visibility), func(t *testing.T) {
+			require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, tt.visibility))
+
+			role, err := svc.PuzzleRole(ctx, p.ID, follower.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.follower, role)
+
+			role, err = svc.PuzzleRole(ctx, p.ID, stranger.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.stranger, role)
+
+			role, err = svc.PuzzleRole(ctx, p.ID, "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.anonymous, role)
+		})
+	}
+
+	t.Run("listings respect visibility", func(t *testing.T) {
+		list := func(viewerID string, vis Visibility) int {
+			require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, vis))
+			puzzles, err := svc.Queries.GetPuzzlesByOwner(ctx, db.GetPuzzlesByOwnerParams{
+				OwnerID:  owner.ID,
+				ViewerID: viewerID,
+				Limit:    10,
+			})
+			require.NoError(t, err)
+			return len(puzzles)
+		}
+
+		assert.Equal(t, 1, list(owner.ID, VisibilityPrivate))
+		assert.Equal(t, 0, list(stranger.ID, VisibilityPrivate))
+		assert.Equal(t, 0, list(stranger.ID, VisibilityUnlisted), "unlisted puzzles are not listed")
+		assert.Equal(t, 1, list(follower.ID, VisibilityFollowers))
+		assert.Equal(t, 0, list(stranger.ID, VisibilityFollowers))
+		assert.Equal(t, 1, list(stranger.ID, VisibilityPublic))
+	})
+
+	t.Run("only the owner changes visibility", func(t *testing.T) {
+		err := svc.SetPuzzleVisibility(ctx, p.ID, stranger.ID, VisibilityPrivate)
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.Error(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, "secret"))
+	})
+}
+
+func TestRoleOrdering(t *testing.T) {
+	tests := []struct {
+		role                       Role
+		canView, canSolve, canEdit bool
+	}{
+		{RoleNone, false, false, false},
+		{RoleViewer, true, false, false},
+		{RoleSolver, true, true, false},
+		{RoleCoConstructor, true, true, true},
+		{RoleOwner, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			assert.Equal(t, tt.canView, tt.role.CanView())
+			assert.Equal(t, tt.canSolve, tt.role.CanSolve())
+			assert.Equal(t, tt.canEdit, tt.role.CanEdit())
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"share_word/internal/db"
+	"time"
+)
+
+// MaxEntryGap caps the solve clock credited to a single letter, so a solver
+// who walked away mid-solve doesn't make the next square look impossible.
+const MaxEntryGap = 2 * time.Minute
+
+// HeatMetric is what a solve heatmap shades squares by.
+type HeatMetric string
+
+const (
+	HeatTime     HeatMetric = "time"
+	HeatErasures HeatMetric = "erasures"
+	HeatChecks   HeatMetric = "checks"
+	HeatReveals  HeatMetric = "reveals"
+)
+
+// HeatMetrics lists the metrics in the order they are offered.
+var HeatMetrics = []HeatMetric{HeatTime, HeatErasures, HeatChecks, HeatReveals}
+
+// ClueStats is how solvers got on with one clue, over every solve of the
+// puzzle.
+type ClueStats struct {
+	Clue
+	Solves   int           // Solves that entered a letter in the clue
+	Time     time.Duration // Solve clock spent on it, summed over those solves
+	Checks   int           // Solves that checked one of its squares
+	Reveals  int           // Solves that revealed one of its squares
+	Erasures int           // Letters cleared or typed over in its squares
+}
+
+// AvgTime is the time an average solve spent on the clue.
+func (c ClueStats) AvgTime() time.Duration {
+	if c.Solves == 0 {
+		return 0
+	}
+	return c.Time / time.Duration(c.Solves)
+}
+
+// SquareStats is how solvers got on with one square.
+type SquareStats struct {
+	Time     time.Duration
+	Checks   int // Times the square was checked
+	Wrong    int // Checks that found it wrong
+	Reveals  int
+	Erasures int
+}
+
+// SolveAnalytics sums up every recorded solve of a puzzle.
+type SolveAnalytics struct {
+	Solves  int
+	Clues   []ClueStats
+	Squares map[string]SquareStats // Keyed by "x,y"
+}
+
+// PuzzleAnalytics works out, from the letters and checks recorded in every
+// solve of the puzzle, where solvers spent their time, checked, revealed and
+// erased. Time between two letters goes to the square the later one went
+// in, and to the clue the solver was working: the one both letters share,
+// or split between its across and down clues when that can't be told. Only
+// the puzzle's constructors may see it.
+func (s *Service) PuzzleAnalytics(ctx context.Context, puzzleID, userID string) (SolveAnalytics, error) {
+	if _, err := s.AuthorizePuzzle(ctx, puzzleID, userID, RoleCoConstructor); err != nil {
+		return SolveAnalytics{}, err
+	}
+	p, err := s.Queries.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return SolveAnalytics{}, err
+	}
+	cells, err := s.Queries.GetCells(ctx, puzzleID)
+	if err != nil {
+		return SolveAnalytics{}, err
+	}
+	clues, err := s.GetFullClues(ctx, puzzleID, cells)
+	if err != nil {
+		return SolveAnalytics{}, err
+	}
+	entries, err := s.Queries.GetPuzzleSolveEntries(ctx, puzzleID)
+	if err != nil {
+		return SolveAnalytics{}, err
+	}
+	checks, err := s.Queries.GetPuzzleSolveChecks(ctx, puzzleID)
+	if err != nil {
+		return SolveAnalytics{}, err
+	}
+	return analyzeSolves(s.wordsBySquare(int(p.Width), int(p.Height), cells), clues, entries, checks), nil
+}
+
+// analyzeSolves is PuzzleAnalytics on loaded data. entries and checkLog are
+// grouped by session, in the order they were recorded.
+func analyzeSolves(words map[string]map[Direction]string, clues []Clue, entries []db.SolveEntry, checkLog []db.SolveCheck) SolveAnalytics {
+	a := SolveAnalytics{Squares: make(map[string]SquareStats)}
+	byClue := make(map[string]*ClueStats, len(clues))
+	a.Clues = make([]ClueStats, len(clues))
+	for i, c := range clues {
+		a.Clues[i] = ClueStats{Clue: c}
+		byClue[fmt.Sprintf("%d-%s", c.Number, c.Direction)] = &a.Clues[i]
+	}
+
+	// Clues touched by the session being walked, for the per-solve counts
+	var solved, revealed, checked map[string]bool
+	count := func(set map[string]bool, field func(*ClueStats) *int) {
+		for key := range set {
+			if c := byClue[key]; c != nil {
+				*field(c)++
+			}
+		}
+	}
+	solves := func(c *ClueStats) *int { return &c.Solves }
+	reveals := func(c *ClueStats) *int { return &c.Reveals }
+	checks := func(c *ClueStats) *int { return &c.Checks }
+	touch := func(set map[string]bool, square string) {
+		for _, key := range words[square] {
+			set[key] = true
+		}
+	}
+
+	var session, prev string
+	var prevElapsed int64
+	var letters map[string]string
+	for _, e := range entries {
+		if e.SessionID != session {
+			count(solved, solves)
+			count(revealed, reveals)
+			a.Solves++
+			session, prev, prevElapsed = e.SessionID, "", 0
+			letters = make(map[string]string)
+			solved, revealed = make(map[string]bool), make(map[string]bool)
+		}
+		square := fmt.Sprintf("%d,%d", e.X, e.Y)
+		stats := a.Squares[square]
+
+		gap := min(max(time.Duration(e.ElapsedMs-prevElapsed)*time.Millisecond, 0), MaxEntryGap)
+		prevElapsed = e.ElapsedMs
+		stats.Time += gap
+		working := workingClues(words[square], words[prev])
+		for _, key := range working {
+			if c := byClue[key]; c != nil {
+				c.Time += gap / time.Duration(len(working))
+			}
+		}
+		prev = square
+
+		if old := letters[square]; !e.Revealed && old != "" && old != e.Char {
+			stats.Erasures++
+			for _, key := range words[square] {
+				if c := byClue[key]; c != nil {
+					c.Erasures++
+				}
+			}
+		}
+		letters[square] = e.Char
+		if e.Revealed {
+			stats.Reveals++
+			touch(revealed, square)
+		} else if e.Char != "" {
+			touch(solved, square)
+		}
+		a.Squares[square] = stats
+	}
+	count(solved, solves)
+	count(revealed, reveals)
+
+	// Checks are walked separately; a check alone doesn't count as a solve
+	session = ""
+	for _, c := range checkLog {
+		if c.SessionID != session {
+			count(checked, checks)
+			session = c.SessionID
+			checked = make(map[string]bool)
+		}
+		square := fmt.Sprintf("%d,%d", c.X, c.Y)
+		stats := a.Squares[square]
+		stats.Checks++
+		if c.Wrong {
+			stats.Wrong++
+		}
+		a.Squares[square] = stats
+		touch(checked, square)
+	}
+	count(checked, checks)
+	return a
+}
+
+// workingClues guesses which of a square's clues the solver was on when they
+// typed in it, from the square they typed in before.
+func workingClues(square, prev map[Direction]string) []string {
+	for _, dir := range []Direction{DirectionAcross, DirectionDown} {
+		if square[dir] != "" && square[dir] == prev[dir] {
+			return []string{square[dir]}
+		}
+	}
+	var keys []string
+	for _, dir := range []Direction{DirectionAcross, DirectionDown} {
+		if square[dir] != "" {
+			keys = append(keys, square[dir])
+		}
+	}
+	return keys
+}
+
+// Heat shades the squares by metric: each square's mark has a Heat between 0
+// and 1, relative to the square that scored highest.
+func (a SolveAnalytics) Heat(metric HeatMetric) map[string]CellMark {
+	value := func(s SquareStats) float64 {
+		switch metric {
+		case HeatErasures:
+			return float64(s.Erasures)
+		case HeatChecks:
+			return float64(s.Checks)
+		case HeatReveals:
+			return float64(s.Reveals)
+		}
+		return s.Time.Seconds()
+	}
+
+	var top float64
+	for _, s := range a.Squares {
+		top = max(top, value(s))
+	}
+	marks := make(map[string]CellMark)
+	if top == 0 {
+		return marks
+	}
+	for square, s := range a.Squares {
+		if v := value(s); v > 0 {
+			marks[square] = CellMark{Heat: v / top}
+		}
+	}
+	return marks
+}
+
+// wordsBySquare maps each white square, by "x,y", to the keys ("1-across")
+// of the clues it is part of.
+func (s *Service) wordsBySquare(width, height int, cells []db.Cell) map[string]map[Direction]string {
+	grid := make(map[string]AnnotatedCell)
+	for _, c := range s.CalculateNumbers(width, height, cells) {
+		grid[fmt.Sprintf("%d,%d", c.X, c.Y)] = c
+	}
+	white := func(x, y int) bool {
+		c, ok := grid[fmt.Sprintf("%d,%d", x, y)]
+		return ok && !c.IsBlock
+	}
+
+	words := make(map[string]map[Direction]string)
+	add := func(x, y int, dir Direction, key string) {
+		square := fmt.Sprintf("%d,%d", x, y)
+		if words[square] == nil {
+			words[square] = make(map[Direction]string)
+		}
+		words[square][dir] = key
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := grid[fmt.Sprintf("%d,%d", x, y)]
+			if !white(x, y) || c.Number == 0 {
+				continue
+			}
+			if !white(x-1, y) && white(x+1, y) {
+				key := fmt.Sprintf("%d-%s", c.Number, DirectionAcross)
+				for cx := x; white(cx, y); cx++ {
+					add(cx, y, DirectionAcross, key)
+				}
+			}
+			if !white(x, y-1) && white(x, y+1) {
+				key := fmt.Sprintf("%d-%s", c.Number, DirectionDown)
+				for cy := y; white(x, cy); cy++ {
+					add(x, cy, DirectionDown, key)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// logCheck records a checked square for the puzzle's analytics. q may be
+// bound to a transaction.
+func logCheck(ctx context.Context, q *db.Queries, sessionID string, x, y int64, wrong bool) error {
+	now := time.Now()
+	var elapsed time.Duration
+	if sess, err := q.GetSolveSession(ctx, sessionID); err == nil {
+		elapsed = SolveElapsed(sess, now)
+	}
+	return q.CreateSolveCheck(ctx, db.CreateSolveCheckParams{
+		SessionID: sessionID,
+		X:         x,
+		Y:         y,
+		Wrong:     wrong,
+		ElapsedMs: elapsed.Milliseconds(),
+		CheckedAt: now.UnixMilli(),
+	})
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"share_word/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuzzleAnalytics(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	_, err = svc.RegisterUser(ctx, "helper", "password123456")
+	require.NoError(t, err)
+	solver, err := svc.RegisterUser(ctx, "solver", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Measured", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, VisibilityPublic))
+	cells, err := svc.Queries.GetCells(ctx, p.ID)
+	require.NoError(t, err)
+	clues, err := svc.GetFullClues(ctx, p.ID, cells)
+	require.NoError(t, err)
+
+	t.Run("time, checks, reveals and erasures add up per clue and square", func(t *testing.T) {
+		entry := func(session string, x, y int64, char string, ms int64) db.SolveEntry {
+			return db.SolveEntry{SessionID: session, X: x, Y: y, Char: char, ElapsedMs: ms}
+		}
+		reveal := entry("a", 2, 0, "C", 401_000)
+		reveal.Revealed = true
+		entries := []db.SolveEntry{
+			entry("a", 0, 0, "A", 10_000), // 1 across or 1 down: split
+			entry("a", 1, 0, "B", 14_000), // Following 1 across
+			entry("a", 1, 0, "", 15_000),
+			entry("a", 1, 0, "X", 400_000), // After a long break
+			reveal,
+			entry("b", 0, 2, "D", 3_000),
+		}
+		checks := []db.SolveCheck{
+			{SessionID: "a", X: 0, Y: 0, Wrong: true},
+			{SessionID: "a", X: 0, Y: 0},
+			{SessionID: "b", X: 4, Y: 4, Wrong: true},
+		}
+		a := analyzeSolves(svc.wordsBySquare(5, 5, cells), clues, entries, checks)
+		assert.Equal(t, 2, a.Solves)
+
+		byKey := make(map[string]ClueStats)
+		for _, c := range a.Clues {
+			byKey[fmt.Sprintf("%d-%s", c.Number, c.Direction)] = c
+		}
+		across := byKey["1-across"]
+		assert.Equal(t, 1, across.Solves)
+		assert.Equal(t, 5*time.Second+4*time.Second+time.Second+MaxEntryGap+time.Second, across.Time)
+		assert.Equal(t, 1, across.Erasures, "clearing a letter counts; filling the empty square doesn't")
+		assert.Equal(t, 1, across.Reveals)
+		assert.Equal(t, 1, across.Checks, "checks count once per solve")
+
+		down := byKey["1-down"]
+		assert.Equal(t, 2, down.Solves)
+		assert.Equal(t, 6500*time.Millisecond, down.Time)
+		assert.Equal(t, 3250*time.Millisecond, down.AvgTime())
+		assert.Equal(t, 1, byKey["5-across"].Checks)
+		assert.Equal(t, 1, byKey["2-down"].Reveals)
+		assert.Zero(t, byKey["2-down"].Solves, "a revealed letter isn't solving")
+
+		assert.Equal(t, SquareStats{Time: 10 * time.Second, Checks: 2, Wrong: 1}, a.Squares["0,0"])
+		assert.Equal(t, SquareStats{Time: 5*time.Second + MaxEntryGap, Erasures: 1}, a.Squares["1,0"])
+
+		heat := a.Heat(HeatTime)
+		assert.Equal(t, 1.0, heat["1,0"].Heat)
+		assert.InDelta(t, 10.0/125, heat["0,0"].Heat, 0.001)
+		assert.Equal(t, map[string]CellMark{"1,0": {Heat: 1}}, a.Heat(HeatErasures))
+		assert.Empty(t, analyzeSolves(nil, clues, nil, nil).Heat(HeatTime))
+	})
+
+	t.Run("solves are recorded as they happen", func(t *testing.T) {
+		sess, err := svc.PersonalSession(ctx, p.ID, solver.ID)
+		require.NoError(t, err)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, solver.ID, 0, 0, "Z", false))
+		require.NoError(t, svc.CheckCells(ctx, p.ID, sess.ID, ScopeWord, 0, 0, DirectionAcross))
+		require.NoError(t, svc.RevealCells(ctx, p.ID, sess.ID, ScopeLetter, 0, 0, DirectionAcross))
+
+		a, err := svc.PuzzleAnalytics(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, a.Solves)
+		square := a.Squares["0,0"]
+		assert.Equal(t, 1, square.Checks, "only filled squares are checked")
+		assert.Equal(t, 1, square.Wrong)
+		assert.Equal(t, 1, square.Reveals)
+		assert.Zero(t, square.Erasures, "a reveal isn't an erasure")
+	})
+
+	t.Run("only constructors see it", func(t *testing.T) {
+		_, err := svc.PuzzleAnalytics(ctx, p.ID, solver.ID)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = svc.PuzzleAnalytics(ctx, p.ID, "")
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		require.NoError(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "helper", RoleCoConstructor))
+		helper, err := svc.Queries.GetUserByUsername(ctx, "helper")
+		require.NoError(t, err)
+		_, err = svc.PuzzleAnalytics(ctx, p.ID, helper.ID)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"share_word/internal/db"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxChatMessage is the longest message, in characters, that can be posted.
+const MaxChatMessage = 500
+
+// ChatHistory is how many of a room's latest messages are shown.
+const ChatHistory = 100
+
+// clueRefPattern matches clue references like "12D", "4 across" or "7-a".
+var clueRefPattern = regexp.MustCompile(`(?i)\b(\d{1,3})\s*-?\s*(a|d|across|down)\b`)
+
+// ChatSegment is a run of a message's text, which may refer to a clue.
+type ChatSegment struct {
+	Text      string
+	Number    int
+	Direction Direction
+}
+
+// IsClue reports whether the segment refers to a clue.
+func (c ChatSegment) IsClue() bool {
+	return c.Number > 0
+}
+
+// PostChatMessage adds a message to a room's chat and tells the room. A
+// room is a solve session, or the puzzle's constructors when sessionID is
+// empty.
+func (s *Service) PostChatMessage(ctx context.Context, puzzleID, sessionID, userID, body string) (db.ChatMessage, error) {
+	body = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, body))
+	if body == "" {
+		return db.ChatMessage{}, errors.New("message is empty")
+	}
+	if utf8.RuneCountInString(body) > MaxChatMessage {
+		return db.ChatMessage{}, fmt.Errorf("message must be at most %d characters", MaxChatMessage)
+	}
+
+	// Only signed-in users chat; messages keep a reference to their poster
+	poster, err := s.Queries.GetUser(ctx, userID)
+	if err != nil {
+		return db.ChatMessage{}, err
+	}
+
+	msg, err := s.Queries.CreateChatMessage(ctx, db.CreateChatMessageParams{
+		ID:        uuid.New().String(),
+		PuzzleID:  puzzleID,
+		SessionID: sessionID,
+		UserID:    userID,
+		Body:      body,
+	})
+	if err != nil {
+		return db.ChatMessage{}, err
+	}
+	s.Publish(Event{
+		Type:      EventChatPosted,
+		PuzzleID:  puzzleID,
+		SessionID: sessionID,
+		UserID:    userID,
+		Chat:      &EventChat{ID: msg.ID, Username: poster.Username, Body: msg.Body},
+	})
+	return msg, nil
+}
+
+// GetChatMessages lists the latest messages in a room, oldest first.
+func (s *Service) GetChatMessages(ctx context.Context, puzzleID, sessionID string) ([]db.GetChatMessagesRow, error) {
+	msgs, err := s.Queries.GetChatMessages(ctx, db.GetChatMessagesParams{
+		PuzzleID:  puzzleID,
+		SessionID: sessionID,
+		Limit:     ChatHistory,
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(msgs)
+	return msgs, nil
+}
+
+// ParseChatMessage splits a message into plain text and references to the
+// given clues. References to clues the puzzle doesn't have stay plain text.
+func ParseChatMessage(body string, clues []Clue) []ChatSegment {
+	exists := make(map[string]bool)
+	for _, c := range clues {
+		exists[fmt.Sprintf("%d-%s", c.Number, c.Direction)] = true
+	}
+
+	var segments []ChatSegment
+	last := 0
+	for _, m := range clueRefPattern.FindAllStringSubmatchIndex(body, -1) {
+		number, _ := strconv.Atoi(body[m[2]:m[3]])
+		dir := DirectionAcross
+		if strings.EqualFold(body[m[4]:m[4]+1], "d") {
+			dir = DirectionDown
+		}
+		if !exists[fmt.Sprintf("%d-%s", number, dir)] {
+			continue
+		}
+		if m[0] > last {
+			segments = append(segments, ChatSegment{Text: body[last:m[0]]})
+		}
+		segments = append(segments, ChatSegment{Text: body[m[0]:m[1]], Number: number, Direction: dir})
+		last = m[1]
+	}
+	if last < len(body) {
+		segments = append(segments, ChatSegment{Text: body[last:]})
+	}
+	return segments
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChatMessage(t *testing.T) {
+	clues := []Clue{
+		{Number: 1, Direction: DirectionAcross},
+		{Number: 12, Direction: DirectionDown},
+		{Number: 4, Direction: DirectionAcross},
+	}
+
+	tests := []struct {
+		body string
+		want []ChatSegment
+	}{
+		{"no refs here", []ChatSegment{{Text: "no refs here"}}},
+		{"12D?", []ChatSegment{{Text: "12D", Number: 12, Direction: DirectionDown}, {Text: "?"}}},
+		{"try 4 across and 1a", []ChatSegment{
+			{Text: "try "},
+			{Text: "4 across", Number: 4, Direction: DirectionAcross},
+			{Text: " and "},
+			{Text: "1a", Number: 1, Direction: DirectionAcross},
+		}},
+		{"12-Down", []ChatSegment{{Text: "12-Down", Number: 12, Direction: DirectionDown}}},
+		{"99A isn't a clue", []ChatSegment{{Text: "99A isn't a clue"}}},
+		{"12Dx or 412D", []ChatSegment{{Text: "12Dx or 412D"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseChatMessage(tt.body, clues))
+		})
+	}
+}
+
+func TestChatMessages(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Chatty", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	t.Run("posting stores the message and tells the room", func(t *testing.T) {
+		events := make(chan *nats.Msg, 4)
+		sub, err := svc.NC.ChanSubscribe(SessionSubject(p.ID, room.ID), events)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+
+		posted, err := svc.PostChatMessage(ctx, p.ID, room.ID, owner.ID, "  what's 6A?\x07 ")
+		require.NoError(t, err)
+		select {
+		case msg := <-events:
+			event, err := ParseEvent(msg.Data)
+			require.NoError(t, err)
+			assert.Equal(t, EventChatPosted, event.Type)
+			assert.Equal(t, &EventChat{ID: posted.ID, Username: "owner", Body: "what's 6A?"}, event.Chat)
+		case <-time.After(time.Second):
+			t.Fatal("no chat event")
+		}
+
+		_, err = svc.PostChatMessage(ctx, p.ID, room.ID, owner.ID, "never mind")
+		require.NoError(t, err)
+
+		msgs, err := svc.GetChatMessages(ctx, p.ID, room.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		assert.Equal(t, "what's 6A?", msgs[0].Body, "trimmed, control characters dropped")
+		assert.Equal(t, "never mind", msgs[1].Body, "oldest first")
+		assert.Equal(t, "owner", msgs[0].Username)
+	})
+
+	t.Run("rooms are separate", func(t *testing.T) {
+		_, err := svc.PostChatMessage(ctx, p.ID, "", owner.ID, "constructors only")
+		require.NoError(t, err)
+
+		msgs, err := svc.GetChatMessages(ctx, p.ID, "")
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "constructors only", msgs[0].Body)
+	})
+
+	t.Run("bad messages are rejected", func(t *testing.T) {
+		_, err := svc.PostChatMessage(ctx, p.ID, room.ID, owner.ID, "   ")
+		assert.Error(t, err)
+		_, err = svc.PostChatMessage(ctx, p.ID, room.ID, owner.ID, strings.Repeat("x", MaxChatMessage+1))
+		assert.Error(t, err)
+		_, err = svc.PostChatMessage(ctx, p.ID, room.ID, "", "hi")
+		assert.Error(t, err, "guests can't chat")
+	})
+
+	t.Run("only the latest messages are kept in view", func(t *testing.T) {
+		for i := 0; i < ChatHistory+5; i++ {
+			_, err := svc.PostChatMessage(ctx, p.ID, "", owner.ID, "spam")
+			require.NoError(t, err)
+		}
+		msgs, err := svc.GetChatMessages(ctx, p.ID, "")
+		require.NoError(t, err)
+		assert.Len(t, msgs, ChatHistory)
+	})
+
+	t.Run("clue references resolve to the clue's first square", func(t *testing.T) {
+		cells, err := svc.Queries.GetCells(ctx, p.ID)
+		require.NoError(t, err)
+
+		x, y, ok := svc.GetClueFocusTarget(ctx, p.ID, cells, 6, DirectionAcross)
+		assert.True(t, ok)
+		assert.Equal(t, []int64{0, 1}, []int64{x, y})
+
+		x, y, ok = svc.GetClueFocusTarget(ctx, p.ID, cells, 3, DirectionDown)
+		assert.True(t, ok)
+		assert.Equal(t, []int64{2, 0}, []int64{x, y})
+
+		_, _, ok = svc.GetClueFocusTarget(ctx, p.ID, cells, 6, DirectionDown)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"share_word/internal/db"
+	"strings"
+)
+
+// CheckScope is how much of the grid a check or reveal covers.
+type CheckScope string
+
+const (
+	ScopeLetter CheckScope = "letter"
+	ScopeWord   CheckScope = "word"
+	ScopePuzzle CheckScope = "puzzle"
+)
+
+// CheckCells compares the session's letters in scope against the solution.
+// Wrong letters are marked; letters that turn out right lose their mark.
+// Pencilled letters are checked like any other. Empty cells are left alone.
+// Each check is recorded for the puzzle's analytics. x, y and dir locate the
+// solver's cursor.
+func (s *Service) CheckCells(ctx context.Context, puzzleID, sessionID string, scope CheckScope, x, y int64, dir Direction) error {
+	targets, err := s.scopeCells(ctx, puzzleID, sessionID, scope, x, y, dir)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	for _, c := range targets {
+		if c.Char == "" {
+			continue
+		}
+		wrong := !strings.EqualFold(c.Char, c.Solution)
+		err = qtx.SetSessionCellWrong(ctx, db.SetSessionCellWrongParams{
+			IsWrong:   wrong,
+			SessionID: sessionID,
+			X:         c.X,
+			Y:         c.Y,
+		})
+		if err != nil {
+			return err
+		}
+		if err := logCheck(ctx, qtx, sessionID, c.X, c.Y, wrong); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RevealCells fills the cells in scope with their solution, in pen, and flags
+// them as revealed. Cells that are already right are not flagged. Revealed
+// letters go into the session's replay. A reveal that completes the grid
+// still finishes the session.
+func (s *Service) RevealCells(ctx context.Context, puzzleID, sessionID string, scope CheckScope, x, y int64, dir Direction) error {
+	targets, err := s.scopeCells(ctx, puzzleID, sessionID, scope, x, y, dir)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	for _, c := range targets {
+		if strings.EqualFold(c.Char, c.Solution) {
+			continue
+		}
+		err = qtx.RevealSessionCell(ctx, db.RevealSessionCellParams{
+			SessionID: sessionID,
+			X:         c.X,
+			Y:         c.Y,
+			Char:      c.Solution,
+		})
+		if err != nil {
+			return err
+		}
+		if err := logEntry(ctx, qtx, sessionID, "", c.X, c.Y, c.Solution, false, true); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.finishIfSolved(ctx, sessionID)
+}
+
+// ScopePoints lists the squares a check or reveal with the same arguments
+// covers.
+func (s *Service) ScopePoints(ctx context.Context, puzzleID, sessionID string, scope CheckScope, x, y int64, dir Direction) ([]Point, error) {
+	targets, err := s.scopeCells(ctx, puzzleID, sessionID, scope, x, y, dir)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]Point, len(targets))
+	for i, c := range targets {
+		points[i] = Point{X: c.X, Y: c.Y}
+	}
+	return points, nil
+}
+
+// scopeCells returns the session's cells covered by scope that have a known
+// solution. Letter and word scopes are empty when the cursor is off the grid.
+func (s *Service) scopeCells(ctx context.Context, puzzleID, sessionID string, scope CheckScope, x, y int64, dir Direction) ([]db.Cell, error) {
+	p, err := s.Queries.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := s.GetSolveCells(ctx, puzzleID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	hasSolution := false
+	for _, c := range cells {
+		if c.Solution != "" {
+			hasSolution = true
+			break
+		}
+	}
+	if !hasSolution {
+		return nil, errors.New("this puzzle has no solution to check against")
+	}
+
+	var inScope map[string]bool
+	switch scope {
+	case ScopeLetter:
+		inScope = map[string]bool{fmt.Sprintf("%d,%d", x, y): true}
+	case ScopeWord:
+		inScope = s.GetActiveWordCells(int(p.Width), int(p.Height), cells, x, y, dir)
+	case ScopePuzzle:
+	default:
+		return nil, errors.New("invalid scope")
+	}
+
+	var targets []db.Cell
+	for _, c := range cells {
+		if c.IsBlock || c.Solution == "" {
+			continue
+		}
+		if inScope != nil && !inScope[fmt.Sprintf("%d,%d", c.X, c.Y)] {
+			continue
+		}
+		targets = append(targets, c)
+	}
+	return targets, nil
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAndReveal(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Checked", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	t.Run("needs a solution", func(t *testing.T) {
+		err := svc.CheckCells(ctx, p.ID, sess.ID, ScopePuzzle, 0, 0, DirectionAcross)
+		assert.Error(t, err)
+	})
+
+	// Top row is ABCDE, first column AFINQ
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+
+	mark := func(x, y int64) CellMark {
+		_, marks, err := svc.GetSolveState(ctx, p.ID, sess)
+		require.NoError(t, err)
+		return marks[fmt.Sprintf("%d,%d", x, y)]
+	}
+	letter := func(x, y int64) string {
+		cells, err := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		for _, c := range cells {
+			if c.X == x && c.Y == y {
+				return c.Char
+			}
+		}
+		return ""
+	}
+
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 0, "A", false))
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 1, 0, "X", false))
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 1, "Y", false))
+
+	t.Run("check letter only touches the cursor cell", func(t *testing.T) {
+		require.NoError(t, svc.CheckCells(ctx, p.ID, sess.ID, ScopeLetter, 0, 1, DirectionAcross))
+		assert.True(t, mark(0, 1).Wrong)
+		assert.False(t, mark(1, 0).Wrong)
+	})
+
+	t.Run("check word follows the direction", func(t *testing.T) {
+		require.NoError(t, svc.CheckCells(ctx, p.ID, sess.ID, ScopeWord, 0, 0, DirectionAcross))
+		assert.False(t, mark(0, 0).Wrong)
+		assert.True(t, mark(1, 0).Wrong)
+		assert.False(t, mark(2, 0).Wrong, "empty cells are not marked")
+	})
+
+	t.Run("retyping clears the wrong mark", func(t *testing.T) {
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 1, 0, "B", false))
+		assert.False(t, mark(1, 0).Wrong)
+	})
+
+	t.Run("pencil letters are checked and revealed in pen", func(t *testing.T) {
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 2, 0, "Q", true))
+		require.NoError(t, svc.CheckCells(ctx, p.ID, sess.ID, ScopeLetter, 2, 0, DirectionAcross))
+		assert.True(t, mark(2, 0).Wrong)
+
+		require.NoError(t, svc.RevealCells(ctx, p.ID, sess.ID, ScopeLetter, 2, 0, DirectionAcross))
+		cells, err := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "C", cells[2].Char)
+		assert.False(t, cells[2].IsPencil)
+	})
+
+	t.Run("reveal flags cells permanently", func(t *testing.T) {
+		require.NoError(t, svc.RevealCells(ctx, p.ID, sess.ID, ScopeWord, 0, 0, DirectionDown))
+		assert.Equal(t, "F", letter(0, 1))
+		assert.True(t, mark(0, 1).Revealed)
+		assert.False(t, mark(0, 1).Wrong)
+		assert.False(t, mark(0, 0).Revealed, "cells that were already right are not flagged")
+
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 1, "Z", false))
+		assert.Equal(t, "F", letter(0, 1), "revealed cells keep their letter")
+		assert.True(t, mark(0, 1).Revealed)
+	})
+
+	t.Run("reveal puzzle fills every cell", func(t *testing.T) {
+		require.NoError(t, svc.RevealCells(ctx, p.ID, sess.ID, ScopePuzzle, -1, -1, DirectionAcross))
+		assert.Equal(t, "U", letter(4, 4))
+		require.NoError(t, svc.CheckCells(ctx, p.ID, sess.ID, ScopePuzzle, -1, -1, DirectionAcross))
+		_, marks, _ := svc.GetSolveState(ctx, p.ID, sess)
+		for _, m := range marks {
+			assert.False(t, m.Wrong)
+		}
+	})
+
+	t.Run("rejects unknown scopes", func(t *testing.T) {
+		assert.Error(t, svc.CheckCells(ctx, p.ID, sess.ID, "row", 0, 0, DirectionAcross))
+	})
+}
+
+func TestAutocheck(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Autochecked", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+
+	sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 0, "A", false))
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 1, 0, "X", false))
+
+	_, marks, err := svc.GetSolveState(ctx, p.ID, sess)
+	require.NoError(t, err)
+	assert.Empty(t, marks, "nothing is marked while autocheck is off")
+
+	require.NoError(t, svc.SetSessionAutocheck(ctx, sess.ID, true))
+	sess, err = svc.Queries.GetSolveSession(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.True(t, sess.Autocheck, "the setting persists with the session")
+
+	_, marks, err = svc.GetSolveState(ctx, p.ID, sess)
+	require.NoError(t, err)
+	assert.True(t, marks["0,0"].Correct)
+	assert.True(t, marks["1,0"].Wrong)
+	assert.NotContains(t, marks, "2,0", "empty cells are not marked")
+
+	require.NoError(t, svc.SetSessionAutocheck(ctx, sess.ID, false))
+	sess, _ = svc.Queries.GetSolveSession(ctx, sess.ID)
+	_, marks, _ = svc.GetSolveState(ctx, p.ID, sess)
+	assert.Empty(t, marks)
+}
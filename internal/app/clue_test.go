@@ -73,3 +73,31 @@ func TestClueLogic(t *testing.T) {
 	}
 	assert.True(t, foundOrphan, "1-Across should be preserved as an orphan since it has text")
 }
+
+func TestAuthoredAnswers(t *testing.T) {
+	ctx := context.Background()
+	svc, queries, _ := SetupTestService(t)
+
+	user, _ := queries.CreateUser(ctx, db.CreateUserParams{ID: "u1", Username: "user1", PasswordHash: "hash"})
+	p, err := svc.CreatePuzzle(ctx, "Authored", user.ID, 5, 5)
+	require.NoError(t, err)
+
+	for x, ch := range []string{"c", "r", "o", "w", "heart"} {
+		require.NoError(t, svc.SetCellSolution(ctx, p.ID, int64(x), 0, ch))
+	}
+
+	cells, _ := queries.GetCells(ctx, p.ID)
+	clues := svc.DeriveClues(5, 5, cells)
+	require.NotEmpty(t, clues)
+	assert.Equal(t, DirectionAcross, clues[0].Direction)
+	assert.Equal(t, "CROWHEART", clues[0].Answer)
+	assert.Equal(t, "", cells[0].Char, "authoring never touches player letters")
+
+	// Blocking a square drops its answer, so unblocking it starts empty
+	require.NoError(t, queries.UpdateCell(ctx, db.UpdateCellParams{PuzzleID: p.ID, X: 1, Y: 0, IsBlock: true}))
+	require.NoError(t, svc.SetCellSolution(ctx, p.ID, 1, 0, "Z"))
+	require.NoError(t, queries.UpdateCell(ctx, db.UpdateCellParams{PuzzleID: p.ID, X: 1, Y: 0, IsBlock: false}))
+	cell, err := queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: 1, Y: 0})
+	require.NoError(t, err)
+	assert.Equal(t, "", cell.Solution)
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"share_word/internal/db"
+	"sort"
+	"strings"
+)
+
+// Contribution is one solver's share of a session.
+type Contribution struct {
+	UserID   string
+	Username string
+	Color    string
+	Letters  int // Letters of theirs on the grid now
+	Words    int // Words whose last correct letter they typed
+}
+
+// Contributions tallies who did what in a session, most words first. A word
+// goes to whoever typed the letter that completed it correctly; words with
+// revealed or pencilled squares don't count for anyone. Guests aren't listed.
+func (s *Service) Contributions(ctx context.Context, puzzleID, sessionID string) ([]Contribution, error) {
+	p, err := s.Queries.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := s.Queries.GetCells(ctx, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	sessionCells, err := s.Queries.GetSessionCells(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]db.SessionCell)
+	byUser := make(map[string]*Contribution)
+	tally := func(userID string) *Contribution {
+		c, ok := byUser[userID]
+		if !ok {
+			c = &Contribution{UserID: userID, Color: PresenceColor(userID)}
+			byUser[userID] = c
+		}
+		return c
+	}
+	for _, sc := range sessionCells {
+		entries[fmt.Sprintf("%d,%d", sc.X, sc.Y)] = sc
+		if sc.EnteredBy != "" && sc.Char != "" {
+			tally(sc.EnteredBy).Letters++
+		}
+	}
+
+	solutions := make(map[string]string)
+	for _, c := range cells {
+		solutions[fmt.Sprintf("%d,%d", c.X, c.Y)] = c.Solution
+	}
+
+	width, height := int(p.Width), int(p.Height)
+	for _, clue := range s.DeriveClues(width, height, cells) {
+		x, y, dir := s.getClueStart(width, height, cells, &clue)
+		var last db.SessionCell
+		complete := true
+		for coord := range s.GetActiveWordCells(width, height, cells, x, y, dir) {
+			e := entries[coord]
+			if e.Revealed || e.IsPencil || solutions[coord] == "" || !strings.EqualFold(e.Char, solutions[coord]) {
+				complete = false
+				break
+			}
+			if e.EnteredAt > last.EnteredAt {
+				last = e
+			}
+		}
+		if complete && last.EnteredBy != "" {
+			tally(last.EnteredBy).Words++
+		}
+	}
+
+	var out []Contribution
+	for id, c := range byUser {
+		u, err := s.GetUserByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		c.Username = u.Username
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Words != out[j].Words {
+			return out[i].Words > out[j].Words
+		}
+		if out[i].Letters != out[j].Letters {
+			return out[i].Letters > out[j].Letters
+		}
+		return out[i].Username < out[j].Username
+	})
+	return out, nil
+}
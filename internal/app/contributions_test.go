@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContributions(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	alice, err := svc.RegisterUser(ctx, "alice", "password123456")
+	require.NoError(t, err)
+	bob, err := svc.RegisterUser(ctx, "bob", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Teamwork", alice.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	room, err := svc.CreateSharedSession(ctx, p.ID, alice.ID)
+	require.NoError(t, err)
+
+	// Letters a millisecond apart so the order they were typed in is clear
+	enter := func(userID string, x, y int64, char string) {
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, svc.SetSessionCell(ctx, room.ID, userID, x, y, char, false))
+	}
+	tally := func() map[string]Contribution {
+		got, err := svc.Contributions(ctx, p.ID, room.ID)
+		require.NoError(t, err)
+		out := make(map[string]Contribution)
+		for _, c := range got {
+			out[c.Username] = c
+		}
+		return out
+	}
+
+	t.Run("letters are attributed to whoever typed them", func(t *testing.T) {
+		for x, ch := range "ABCDE" {
+			enter(alice.ID, int64(x), 0, string(ch))
+		}
+		_, marks, err := svc.GetSolveState(ctx, p.ID, room)
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, marks["0,0"].EnteredBy)
+		assert.Equal(t, "", marks["0,1"].EnteredBy)
+	})
+
+	t.Run("words go to whoever completed them", func(t *testing.T) {
+		for y, ch := range []string{"F", "I", "N", "Q"} {
+			enter(bob.ID, 0, int64(y+1), ch)
+		}
+		for x, ch := range "JKLM" {
+			enter(alice.ID, int64(x+1), 2, string(ch))
+		}
+
+		got := tally()
+		assert.Equal(t, Contribution{UserID: alice.ID, Username: "alice", Color: PresenceColor(alice.ID), Letters: 9, Words: 2}, got["alice"])
+		assert.Equal(t, Contribution{UserID: bob.ID, Username: "bob", Color: PresenceColor(bob.ID), Letters: 4, Words: 1}, got["bob"])
+	})
+
+	t.Run("wrong, pencilled and revealed words count for nobody", func(t *testing.T) {
+		enter(bob.ID, 4, 4, "X")
+		for x, ch := range "QRST" {
+			enter(bob.ID, int64(x), 4, string(ch))
+		}
+		assert.Equal(t, 1, tally()["bob"].Words, "5A has a wrong letter")
+
+		require.NoError(t, svc.SetSessionCell(ctx, room.ID, bob.ID, 4, 4, "U", true))
+		assert.Equal(t, 1, tally()["bob"].Words, "5A is only pencilled in")
+
+		enter(bob.ID, 4, 4, "X")
+		require.NoError(t, svc.RevealCells(ctx, p.ID, room.ID, ScopeLetter, 4, 4, DirectionAcross))
+		assert.Equal(t, 1, tally()["bob"].Words, "5A was finished by a reveal")
+		_, marks, err := svc.GetSolveState(ctx, p.ID, room)
+		require.NoError(t, err)
+		assert.Equal(t, "", marks["4,4"].EnteredBy)
+	})
+
+	t.Run("clearing a letter drops its attribution", func(t *testing.T) {
+		enter(alice.ID, 0, 0, "")
+		got := tally()
+		assert.Equal(t, 8, got["alice"].Letters)
+		assert.Equal(t, 1, got["alice"].Words)
+		assert.Equal(t, 0, got["bob"].Words, "1D is no longer complete")
+	})
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// EventStream is the JetStream stream that logs every event on the bus, so
+// streams that drop can catch up on what they missed.
+const EventStream = "PUZZLE_EVENTS"
+
+// EventRetention is how long logged events are kept.
+const EventRetention = 24 * time.Hour
+
+// EventLogMaxBytes caps the whole event log, and EventLogMaxPerSubject each
+// puzzle's and session's share of it, so a busy day of cursors and presence
+// can't fill the disk. The oldest events go first. The per-subject cap stays
+// well above MaxReplay: a subject can only lose events a stream missed once
+// it has more of them than a stream would replay anyway.
+const (
+	EventLogMaxBytes      = 1 << 30
+	EventLogMaxPerSubject = 20 * MaxReplay
+)
+
+// MaxReplay is the most events a reconnecting stream catches up on. Further
+// behind than that, rendering the grid again is cheaper.
+const MaxReplay = 500
+
+// ErrReplayUnavailable means the events since the one a stream last saw can't
+// be replayed: they have expired or been discarded, there are too many of
+// them, or the bus keeps no log.
+var ErrReplayUnavailable = errors.New("missed events are no longer available")
+
+// ensureEventStream creates the event log, or brings its settings up to date.
+func (s *Service) ensureEventStream(ctx context.Context, replicas int) error {
+	_, err := s.JS.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     EventStream,
+		Subjects: []string{"puzzles.>"},
+		Storage:  jetstream.FileStorage,
+		MaxAge:   EventRetention,
+		Replicas: replicas,
+
+		MaxBytes:          EventLogMaxBytes,
+		MaxMsgsPerSubject: EventLogMaxPerSubject,
+		Discard:           jetstream.DiscardOld,
+	})
+	return err
+}
+
+// logging reports whether events are being logged.
+func (s *Service) logging() bool {
+	return s.JS != nil && s.logReady.Load()
+}
+
+// LatestEventSeq is the sequence number of the newest logged event, 0 when
+// nothing is logged.
+func (s *Service) LatestEventSeq(ctx context.Context) (uint64, error) {
+	if !s.logging() {
+		return 0, nil
+	}
+	stream, err := s.JS.Stream(ctx, EventStream)
+	if err != nil {
+		return 0, err
+	}
+	return stream.CachedInfo().State.LastSeq, nil
+}
+
+// EventWatch is a running WatchEvents.
+type EventWatch struct {
+	// Missed is how many of the events handed over were logged before the
+	// watch started.
+	Missed uint64
+
+	stop func()
+}
+
+// Stop ends the watch.
+func (w *EventWatch) Stop() {
+	w.stop()
+}
+
+// WatchEvents calls handle, in order, with each event published on the given
+// subjects after the one numbered after, starting with any already logged.
+// seq is the event's number in the log, 0 when the bus keeps none; err is set
+// for events that couldn't be read. It returns ErrReplayUnavailable when the
+// events since after can't all be replayed.
+func (s *Service) WatchEvents(ctx context.Context, subjects []string, after uint64, handle func(seq uint64, e Event, err error)) (*EventWatch, error) {
+	if !s.logging() {
+		return s.watchLiveEvents(subjects, after, handle)
+	}
+
+	stream, err := s.JS.Stream(ctx, EventStream)
+	if err != nil {
+		return nil, err
+	}
+	state := stream.CachedInfo().State
+	if after > state.LastSeq || after+1 < state.FirstSeq {
+		return nil, ErrReplayUnavailable
+	}
+
+	cons, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		FilterSubjects: subjects,
+		DeliverPolicy:  jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:    after + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	missed := cons.CachedInfo().NumPending
+	if missed > MaxReplay {
+		_ = stream.DeleteConsumer(ctx, cons.CachedInfo().Name)
+		return nil, ErrReplayUnavailable
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		var seq uint64
+		if meta, err := msg.Metadata(); err == nil {
+			seq = meta.Sequence.Stream
+		}
+		e, err := ParseEvent(msg.Data())
+		handle(seq, e, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EventWatch{Missed: missed, stop: cc.Stop}, nil
+}
+
+// watchLiveEvents is WatchEvents on a bus without a log: only events
+// published from now on are seen.
+func (s *Service) watchLiveEvents(subjects []string, after uint64, handle func(seq uint64, e Event, err error)) (*EventWatch, error) {
+	if after > 0 {
+		return nil, ErrReplayUnavailable
+	}
+	var subs []*nats.Subscription
+	stop := func() {
+		for _, sub := range subs {
+			_ = sub.Unsubscribe()
+		}
+	}
+	for _, subject := range subjects {
+		sub, err := s.NC.Subscribe(subject, func(msg *nats.Msg) {
+			e, err := ParseEvent(msg.Data)
+			handle(0, e, err)
+		})
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return &EventWatch{stop: stop}, nil
+}
+
+// logEvent publishes an event's data, through the log when there is one.
+func (s *Service) logEvent(subject string, data []byte) {
+	if !s.logging() {
+		_ = s.NC.Publish(subject, data)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.JS.Publish(ctx, subject, data); err != nil {
+		log.Printf("Failed to log event on %s: %v", subject, err)
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLog(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+	require.NotNil(t, svc.JS, "the embedded server keeps a log")
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Logged", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	subjects := []string{"puzzles." + p.ID, SessionSubject(p.ID, room.ID)}
+
+	cursor := func(x, y int64) {
+		svc.Publish(Event{Type: EventCursorMoved, PuzzleID: p.ID, SessionID: room.ID, Cursor: &EventCursor{ClientID: "tab", X: x, Y: y, Direction: DirectionAcross}})
+	}
+
+	type logged struct {
+		seq   uint64
+		event Event
+	}
+	watch := func(after uint64) (*EventWatch, chan logged) {
+		events := make(chan logged, MaxReplay+16)
+		w, err := svc.WatchEvents(ctx, subjects, after, func(seq uint64, e Event, err error) {
+			assert.NoError(t, err)
+			events <- logged{seq, e}
+		})
+		require.NoError(t, err)
+		t.Cleanup(w.Stop)
+		return w, events
+	}
+	next := func(events chan logged) logged {
+		select {
+		case l := <-events:
+			return l
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+			return logged{}
+		}
+	}
+
+	cursor(0, 0)
+	seen, err := svc.LatestEventSeq(ctx)
+	require.NoError(t, err)
+
+	t.Run("missed events are replayed in order, then live ones", func(t *testing.T) {
+		cursor(1, 0)
+		svc.PublishCellsChanged(ctx, p.ID, owner.ID, []Point{{X: 4, Y: 4}})
+
+		w, events := watch(seen)
+		assert.Equal(t, uint64(2), w.Missed)
+		first, second := next(events), next(events)
+		assert.Equal(t, seen+1, first.seq)
+		assert.Equal(t, EventCursorMoved, first.event.Type)
+		assert.Equal(t, &EventCursor{ClientID: "tab", X: 0, Y: 0, Direction: DirectionAcross}, first.event.PrevCursor, "cursor moves say where the cursor was")
+		assert.Equal(t, EventCellsChanged, second.event.Type)
+		assert.Greater(t, second.seq, first.seq)
+
+		cursor(2, 0)
+		live := next(events)
+		assert.Greater(t, live.seq, second.seq)
+		assert.Equal(t, int64(2), live.event.Cursor.X)
+	})
+
+	t.Run("leaving clears the tab's cursor", func(t *testing.T) {
+		after, err := svc.LatestEventSeq(ctx)
+		require.NoError(t, err)
+		_, events := watch(after)
+		svc.BroadcastPresence(Presence{Key: "token:tab", PuzzleID: p.ID, SessionID: room.ID, Mode: "solve"}, false)
+		e := next(events).event
+		assert.Equal(t, EventPresenceChanged, e.Type)
+		assert.Equal(t, &EventCursor{ClientID: "tab", X: 2, Y: 0, Direction: DirectionAcross}, e.PrevCursor)
+		_, ok := svc.lastCursors.Load("tab")
+		assert.False(t, ok)
+	})
+
+	t.Run("events that can't be replayed are refused", func(t *testing.T) {
+		latest, err := svc.LatestEventSeq(ctx)
+		require.NoError(t, err)
+		_, err = svc.WatchEvents(ctx, subjects, latest+1, func(uint64, Event, error) {})
+		assert.ErrorIs(t, err, ErrReplayUnavailable, "ids from another log")
+
+		for i := 0; i <= MaxReplay; i++ {
+			cursor(int64(i%5), 1)
+		}
+		_, err = svc.WatchEvents(ctx, subjects, latest, func(uint64, Event, error) {})
+		assert.ErrorIs(t, err, ErrReplayUnavailable, "too far behind")
+	})
+
+	t.Run("the log is capped", func(t *testing.T) {
+		stream, err := svc.JS.Stream(ctx, EventStream)
+		require.NoError(t, err)
+		cfg := stream.CachedInfo().Config
+		assert.Equal(t, int64(EventLogMaxBytes), cfg.MaxBytes)
+		assert.Equal(t, int64(EventLogMaxPerSubject), cfg.MaxMsgsPerSubject)
+		assert.Equal(t, jetstream.DiscardOld, cfg.Discard)
+		assert.Greater(t, cfg.MaxMsgsPerSubject, int64(MaxReplay))
+	})
+}
@@ -0,0 +1,327 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"share_word/internal/db"
+	"sync"
+	"time"
+)
+
+// EventVersion is the version of the event schema. It goes up when an
+// existing field changes meaning; adding event types or fields doesn't
+// change it.
+const EventVersion = 1
+
+// EventType says what an event is about.
+type EventType string
+
+// Events about the puzzle itself are published on puzzles.<id>. Events about
+// one solve session are published on puzzles.<id>.sessions.<sessionID>.
+const (
+	EventCellsChanged    EventType = "cells.changed"   // Authored squares: blocks, answers, styles
+	EventClueChanged     EventType = "clue.changed"    // A clue's text
+	EventClueEditing     EventType = "clue.editing"    // Someone opened or closed a clue editor
+	EventResized         EventType = "puzzle.resized"  // The grid's dimensions
+	EventImported        EventType = "puzzle.imported" // The whole puzzle was replaced by an import
+	EventRestored        EventType = "puzzle.restored" // The whole puzzle was replaced by a saved version
+	EventUndone          EventType = "history.undone"  // An edit was undone
+	EventRedone          EventType = "history.redone"  // An edit was redone
+	EventAccessChanged   EventType = "access.changed"  // Visibility or membership
+	EventLettersChanged  EventType = "letters.changed" // Letters typed or cleared in a session
+	EventLettersChecked  EventType = "letters.checked" // Letters marked right or wrong
+	EventRevealed        EventType = "letters.revealed"
+	EventAutocheck       EventType = "session.autocheck"
+	EventCompleted       EventType = "session.completed"
+	EventCursorMoved     EventType = "cursor.moved"
+	EventPresenceChanged EventType = "presence.changed"
+	EventChatPosted      EventType = "chat.posted"
+)
+
+// changesContent reports whether events of this type change what the puzzle
+// or a session holds, rather than who is looking at it or talking about it.
+// Only these advance the puzzle's revision.
+func (t EventType) changesContent() bool {
+	switch t {
+	case EventClueEditing, EventCursorMoved, EventPresenceChanged, EventChatPosted:
+		return false
+	}
+	return true
+}
+
+// Structural reports whether events of this type can change the grid as a
+// whole, so views should be rebuilt rather than patched square by square.
+func (t EventType) Structural() bool {
+	switch t {
+	case EventResized, EventImported, EventRestored, EventUndone, EventRedone, EventAccessChanged:
+		return true
+	}
+	return false
+}
+
+// Event is one message on the real-time bus. Type decides which of the
+// optional fields are set.
+//
+// Revision counts the content changes to a puzzle and its sessions. Every
+// event that changes content takes the next revision; other events carry the
+// latest one. Events are published in revision order.
+type Event struct {
+	Version   int       `json:"v"`
+	Type      EventType `json:"type"`
+	PuzzleID  string    `json:"puzzle_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	Revision  int64     `json:"revision"`
+	Time      time.Time `json:"time"`
+	// The user behind the change; empty for guests and the system
+	UserID string `json:"user_id,omitempty"`
+
+	Cells     []EventCell   `json:"cells,omitempty"`
+	Letters   []EventLetter `json:"letters,omitempty"`
+	Clue      *EventClue    `json:"clue,omitempty"`
+	Width     int64         `json:"width,omitempty"`
+	Height    int64         `json:"height,omitempty"`
+	Scope     CheckScope    `json:"scope,omitempty"`
+	Autocheck *bool         `json:"autocheck,omitempty"`
+	ElapsedMs int64         `json:"elapsed_ms,omitempty"`
+	Cursor    *EventCursor  `json:"cursor,omitempty"`
+	// Where the tab's cursor was before this event, set on events that move
+	// or remove a cursor
+	PrevCursor *EventCursor   `json:"prev_cursor,omitempty"`
+	Presence   *EventPresence `json:"presence,omitempty"`
+	Chat       *EventChat     `json:"chat,omitempty"`
+}
+
+// EventCell is an authored square as it is after the change.
+type EventCell struct {
+	X        int64  `json:"x"`
+	Y        int64  `json:"y"`
+	IsBlock  bool   `json:"is_block"`
+	Solution string `json:"solution"`
+	Circled  bool   `json:"circled,omitempty"`
+	Shaded   bool   `json:"shaded,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Bars     string `json:"bars,omitempty"`
+}
+
+// EventLetter is a square in a session as it is after the change. An empty
+// Char means the square was cleared.
+type EventLetter struct {
+	X         int64  `json:"x"`
+	Y         int64  `json:"y"`
+	Char      string `json:"char"`
+	Pencil    bool   `json:"pencil,omitempty"`
+	Wrong     bool   `json:"wrong,omitempty"`
+	Revealed  bool   `json:"revealed,omitempty"`
+	EnteredBy string `json:"entered_by,omitempty"`
+}
+
+// EventClue is a clue as it is after the change. Editing is set on
+// clue.editing events while the editor is open.
+type EventClue struct {
+	Number    int64     `json:"number"`
+	Direction Direction `json:"direction"`
+	Text      string    `json:"text,omitempty"`
+	Editing   bool      `json:"editing,omitempty"`
+}
+
+// EventCursor is where one browser tab's cursor is.
+type EventCursor struct {
+	ClientID  string    `json:"client_id"`
+	X         int64     `json:"x"`
+	Y         int64     `json:"y"`
+	Direction Direction `json:"direction"`
+}
+
+// EventPresence is someone arriving on or leaving a grid.
+type EventPresence struct {
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Color    string `json:"color"`
+	Mode     string `json:"mode"`
+	Joined   bool   `json:"joined"`
+}
+
+// EventChat is a posted chat message.
+type EventChat struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Body     string `json:"body"`
+}
+
+// Subject is the NATS subject the event is published on.
+func (e Event) Subject() string {
+	if e.SessionID != "" {
+		return SessionSubject(e.PuzzleID, e.SessionID)
+	}
+	return fmt.Sprintf("puzzles.%s", e.PuzzleID)
+}
+
+// ParseEvent decodes an event from the bus. Events from a newer schema are
+// rejected rather than misread.
+func ParseEvent(data []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, err
+	}
+	if e.Version != EventVersion {
+		return Event{}, fmt.Errorf("unsupported event version %d", e.Version)
+	}
+	return e, nil
+}
+
+// Publish stamps an event with the schema version, the time and the puzzle's
+// revision and sends it on its subject. Content changes also mark the puzzle,
+// or the session they happened in, as updated.
+func (s *Service) Publish(e Event) {
+	if s.NC == nil {
+		log.Printf("Publish skipped: NATS connection is nil")
+		return
+	}
+	ctx := context.Background()
+
+	// Held until the event is sent so the bus sees revisions in order
+	unlock := s.lockPublish(e.PuzzleID)
+	defer unlock()
+
+	var err error
+	if e.Type.changesContent() {
+		e.Revision, err = s.Queries.BumpPuzzleRevision(ctx, e.PuzzleID)
+		if e.SessionID != "" {
+			_ = s.Queries.UpdateSolveSessionUpdatedAt(ctx, e.SessionID)
+		} else {
+			_ = s.Queries.UpdatePuzzleUpdatedAt(ctx, e.PuzzleID)
+		}
+	} else {
+		e.Revision, err = s.puzzleRevision(ctx, e.PuzzleID)
+	}
+	if err != nil {
+		log.Printf("Failed to read revision for %s: %v", e.PuzzleID, err)
+	}
+
+	s.trackCursor(&e)
+	e.Version = EventVersion
+	e.Time = time.Now().UTC()
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", e.Type, err)
+		return
+	}
+	log.Printf("Publishing to NATS: %s -> %s (revision %d)", e.Subject(), e.Type, e.Revision)
+	s.logEvent(e.Subject(), data)
+}
+
+// publishLock serializes one puzzle's events. refs counts the publishers
+// holding or waiting on it, guarded by Service.publishMu.
+type publishLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockPublish takes a puzzle's publish lock and returns its unlock. Locks no
+// one is waiting on are dropped.
+func (s *Service) lockPublish(puzzleID string) func() {
+	s.publishMu.Lock()
+	l := s.publishLocks[puzzleID]
+	if l == nil {
+		l = &publishLock{}
+		s.publishLocks[puzzleID] = l
+	}
+	l.refs++
+	s.publishMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.publishMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.publishLocks, puzzleID)
+		}
+		s.publishMu.Unlock()
+	}
+}
+
+// trackCursor sets PrevCursor to where the event's tab last announced its
+// cursor, so a stream that only sees this event can clear the old position.
+// A tab's cursor is forgotten when it leaves.
+func (s *Service) trackCursor(e *Event) {
+	var clientID string
+	switch {
+	case e.Cursor != nil:
+		clientID = e.Cursor.ClientID
+	case e.Presence != nil && !e.Presence.Joined:
+		clientID = e.Presence.ClientID
+	}
+	if clientID == "" {
+		return
+	}
+	if prev, ok := s.lastCursors.Load(clientID); ok {
+		c := prev.(EventCursor)
+		if e.Cursor == nil || c != *e.Cursor {
+			e.PrevCursor = &c
+		}
+	}
+	if e.Cursor != nil {
+		s.lastCursors.Store(clientID, *e.Cursor)
+	} else {
+		s.lastCursors.Delete(clientID)
+	}
+}
+
+// PublishCellsChanged publishes the given authored squares as they are now.
+func (s *Service) PublishCellsChanged(ctx context.Context, puzzleID, userID string, points []Point) {
+	e := Event{Type: EventCellsChanged, PuzzleID: puzzleID, UserID: userID}
+	for _, pt := range points {
+		c, err := s.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: puzzleID, X: pt.X, Y: pt.Y})
+		if err != nil {
+			continue
+		}
+		e.Cells = append(e.Cells, EventCell{
+			X:        c.X,
+			Y:        c.Y,
+			IsBlock:  c.IsBlock,
+			Solution: c.Solution,
+			Circled:  c.Circled,
+			Shaded:   c.Shaded,
+			Color:    c.Color,
+			Bars:     c.Bars,
+		})
+	}
+	s.Publish(e)
+}
+
+// PublishLetters publishes an event carrying the given squares of a session as
+// they are now. e.Letters is filled in; the rest of e is left as given.
+func (s *Service) PublishLetters(ctx context.Context, e Event, points []Point) {
+	letters := make(map[Point]db.SessionCell)
+	if sessionCells, err := s.Queries.GetSessionCells(ctx, e.SessionID); err == nil {
+		for _, c := range sessionCells {
+			letters[Point{X: c.X, Y: c.Y}] = c
+		}
+	}
+	for _, pt := range points {
+		c := letters[pt]
+		e.Letters = append(e.Letters, EventLetter{
+			X:         pt.X,
+			Y:         pt.Y,
+			Char:      c.Char,
+			Pencil:    c.IsPencil,
+			Wrong:     c.IsWrong,
+			Revealed:  c.Revealed,
+			EnteredBy: c.EnteredBy,
+		})
+	}
+	s.Publish(e)
+}
+
+// PublishGridReplaced publishes an event for a change that can touch any
+// square, such as a resize or an import, with the grid's new dimensions.
+// Subscribers reload the puzzle rather than patch it.
+func (s *Service) PublishGridReplaced(ctx context.Context, t EventType, puzzleID, userID string) {
+	e := Event{Type: t, PuzzleID: puzzleID, UserID: userID}
+	if p, err := s.Queries.GetPuzzle(ctx, puzzleID); err == nil {
+		e.Width, e.Height = p.Width, p.Height
+	}
+	s.Publish(e)
+}
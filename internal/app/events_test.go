@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Evented", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	events := make(chan *nats.Msg, 16)
+	sub, err := svc.NC.ChanSubscribe("puzzles."+p.ID+".>", events)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	puzzleSub, err := svc.NC.ChanSubscribe("puzzles."+p.ID, events)
+	require.NoError(t, err)
+	defer puzzleSub.Unsubscribe()
+	next := func() (Event, string) {
+		select {
+		case msg := <-events:
+			e, err := ParseEvent(msg.Data)
+			require.NoError(t, err)
+			return e, msg.Subject
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+			return Event{}, ""
+		}
+	}
+
+	t.Run("content changes take the next revision", func(t *testing.T) {
+		require.NoError(t, svc.SetBlocks(ctx, p.ID, []Point{{X: 0, Y: 0}}, true))
+		svc.PublishCellsChanged(ctx, p.ID, owner.ID, []Point{{X: 0, Y: 0}})
+		e, subject := next()
+		assert.Equal(t, "puzzles."+p.ID, subject)
+		assert.Equal(t, EventVersion, e.Version)
+		assert.Equal(t, EventCellsChanged, e.Type)
+		assert.Equal(t, int64(1), e.Revision)
+		assert.Equal(t, owner.ID, e.UserID)
+		assert.Equal(t, []EventCell{{X: 0, Y: 0, IsBlock: true}}, e.Cells)
+
+		require.NoError(t, svc.SetSessionCell(ctx, room.ID, owner.ID, 1, 0, "A", true))
+		svc.PublishLetters(ctx, Event{Type: EventLettersChanged, PuzzleID: p.ID, SessionID: room.ID}, []Point{{X: 1, Y: 0}, {X: 2, Y: 0}})
+		e, subject = next()
+		assert.Equal(t, SessionSubject(p.ID, room.ID), subject)
+		assert.Equal(t, int64(2), e.Revision, "sessions share the puzzle's revisions")
+		assert.Equal(t, []EventLetter{
+			{X: 1, Y: 0, Char: "A", Pencil: true, EnteredBy: owner.ID},
+			{X: 2, Y: 0},
+		}, e.Letters)
+	})
+
+	t.Run("other events carry the latest revision", func(t *testing.T) {
+		svc.Publish(Event{Type: EventCursorMoved, PuzzleID: p.ID, SessionID: room.ID, Cursor: &EventCursor{ClientID: "tab", X: 3, Y: 0, Direction: DirectionAcross}})
+		e, _ := next()
+		assert.Equal(t, int64(2), e.Revision)
+		assert.Equal(t, &EventCursor{ClientID: "tab", X: 3, Y: 0, Direction: DirectionAcross}, e.Cursor)
+
+		rev, err := svc.Queries.GetPuzzleRevision(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), rev)
+	})
+
+	t.Run("replacing the grid carries its new size", func(t *testing.T) {
+		require.NoError(t, svc.ResizePuzzle(ctx, p.ID, 7, 6))
+		svc.PublishGridReplaced(ctx, EventResized, p.ID, owner.ID)
+		e, _ := next()
+		assert.Equal(t, EventResized, e.Type)
+		assert.Equal(t, int64(3), e.Revision)
+		assert.Equal(t, [2]int64{7, 6}, [2]int64{e.Width, e.Height})
+	})
+
+	t.Run("a busy puzzle doesn't hold up others", func(t *testing.T) {
+		unlock := svc.lockPublish("elsewhere")
+		done := make(chan struct{})
+		go func() {
+			svc.Publish(Event{Type: EventCursorMoved, PuzzleID: p.ID, Cursor: &EventCursor{ClientID: "tab", X: 4, Y: 0}})
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("publish waited on another puzzle")
+		}
+		next()
+		unlock()
+
+		svc.publishMu.Lock()
+		defer svc.publishMu.Unlock()
+		assert.Empty(t, svc.publishLocks, "idle locks are dropped")
+	})
+
+	t.Run("unknown versions are rejected", func(t *testing.T) {
+		_, err := ParseEvent([]byte(`{"v":2,"type":"cells.changed"}`))
+		assert.Error(t, err)
+		_, err = ParseEvent([]byte(`signal`))
+		assert.Error(t, err)
+	})
+}
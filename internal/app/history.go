@@ -0,0 +1,291 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"share_word/internal/db"
+)
+
+// EditKind says what an undoable edit changed.
+type EditKind string
+
+const (
+	EditCells   EditKind = "cells"
+	EditClue    EditKind = "clue"
+	EditResize  EditKind = "resize"
+	EditImport  EditKind = "import"
+	EditRestore EditKind = "restore"
+)
+
+// MaxEditHistory is how many edits a puzzle keeps around to undo.
+const MaxEditHistory = 200
+
+// gridState is the part of a puzzle one edit touched, as it was on one side
+// of the edit. A whole state replaces the grid's name, size, cells and clues;
+// a partial one only rewrites the cells and clues it lists, and a listed clue
+// with no text is removed.
+type gridState struct {
+	Whole  bool      `json:"whole,omitempty"`
+	Name   string    `json:"name,omitempty"`
+	Width  int64     `json:"width,omitempty"`
+	Height int64     `json:"height,omitempty"`
+	Cells  []db.Cell `json:"cells,omitempty"`
+	Clues  []db.Clue `json:"clues,omitempty"`
+}
+
+// snapshotGrid captures a puzzle's whole grid and clues.
+func snapshotGrid(ctx context.Context, q *db.Queries, puzzleID string) (gridState, error) {
+	p, err := q.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return gridState{}, err
+	}
+	cells, err := q.GetCells(ctx, puzzleID)
+	if err != nil {
+		return gridState{}, err
+	}
+	clues, err := q.GetClues(ctx, puzzleID)
+	if err != nil {
+		return gridState{}, err
+	}
+	return gridState{Whole: true, Name: p.Name, Width: p.Width, Height: p.Height, Cells: cells, Clues: clues}, nil
+}
+
+// snapshotCells captures the given cells. Points off the grid are skipped.
+func snapshotCells(ctx context.Context, q *db.Queries, puzzleID string, points []Point) (gridState, error) {
+	var st gridState
+	for _, pt := range points {
+		cell, err := q.GetCell(ctx, db.GetCellParams{PuzzleID: puzzleID, X: pt.X, Y: pt.Y})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return gridState{}, err
+		}
+		st.Cells = append(st.Cells, cell)
+	}
+	return st, nil
+}
+
+// snapshotClue captures one clue, with empty text if it doesn't exist.
+func snapshotClue(ctx context.Context, q *db.Queries, puzzleID string, number int64, direction Direction) (gridState, error) {
+	clues, err := q.GetClues(ctx, puzzleID)
+	if err != nil {
+		return gridState{}, err
+	}
+	clue := db.Clue{PuzzleID: puzzleID, Number: number, Direction: string(direction)}
+	for _, c := range clues {
+		if c.Number == number && c.Direction == string(direction) {
+			clue = c
+		}
+	}
+	return gridState{Clues: []db.Clue{clue}}, nil
+}
+
+// clearChangedLetters clears solvers' letters in the squares whose block or
+// answer differs between two snapshots of the same squares, or of the whole
+// grid, since they no longer fit. Finished solves keep their letters.
+func clearChangedLetters(ctx context.Context, q *db.Queries, puzzleID string, before, after gridState) error {
+	old := make(map[Point]db.Cell, len(before.Cells))
+	for _, c := range before.Cells {
+		old[Point{X: c.X, Y: c.Y}] = c
+	}
+	for _, c := range after.Cells {
+		prev, ok := old[Point{X: c.X, Y: c.Y}]
+		if ok && prev.IsBlock == c.IsBlock && prev.Solution == c.Solution {
+			continue
+		}
+		err := q.DeleteOpenSessionCell(ctx, db.DeleteOpenSessionCellParams{
+			PuzzleID: puzzleID,
+			X:        c.X,
+			Y:        c.Y,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyGridState writes a captured state back over the puzzle.
+func applyGridState(ctx context.Context, q *db.Queries, puzzleID string, st gridState) error {
+	if st.Whole {
+		err := q.UpdatePuzzleDimensions(ctx, db.UpdatePuzzleDimensionsParams{
+			Width:  st.Width,
+			Height: st.Height,
+			ID:     puzzleID,
+		})
+		if err != nil {
+			return err
+		}
+		if st.Name != "" {
+			if err := q.UpdatePuzzleName(ctx, db.UpdatePuzzleNameParams{Name: st.Name, ID: puzzleID}); err != nil {
+				return err
+			}
+		}
+		if err := q.DeleteAllCells(ctx, puzzleID); err != nil {
+			return err
+		}
+		if err := q.DeleteAllClues(ctx, puzzleID); err != nil {
+			return err
+		}
+		err = q.DeleteSessionCellsOutside(ctx, db.DeleteSessionCellsOutsideParams{
+			PuzzleID: puzzleID,
+			X:        st.Width,
+			Y:        st.Height,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range st.Cells {
+		err := q.ImportCell(ctx, db.ImportCellParams{
+			PuzzleID: puzzleID,
+			X:        c.X,
+			Y:        c.Y,
+			Char:     c.Char,
+			Solution: c.Solution,
+			IsBlock:  c.IsBlock,
+			IsPencil: c.IsPencil,
+			Circled:  c.Circled,
+			Shaded:   c.Shaded,
+			Color:    c.Color,
+			Bars:     c.Bars,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range st.Clues {
+		var err error
+		if c.Text == "" && !st.Whole {
+			err = q.DeleteClue(ctx, db.DeleteClueParams{PuzzleID: puzzleID, Number: c.Number, Direction: c.Direction})
+		} else {
+			err = q.UpsertClue(ctx, db.UpsertClueParams{PuzzleID: puzzleID, Number: c.Number, Direction: c.Direction, Text: c.Text})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordEdit adds an edit to the puzzle's history. A new edit drops anything
+// that was undone before it, and edits that changed nothing aren't kept.
+func (s *Service) recordEdit(ctx context.Context, q *db.Queries, puzzleID string, kind EditKind, before, after gridState) error {
+	undo, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	redo, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	if string(undo) == string(redo) {
+		return nil
+	}
+
+	if err := q.DeleteUndonePuzzleEdits(ctx, puzzleID); err != nil {
+		return err
+	}
+	err = q.CreatePuzzleEdit(ctx, db.CreatePuzzleEditParams{
+		PuzzleID:  puzzleID,
+		Kind:      string(kind),
+		UndoState: string(undo),
+		RedoState: string(redo),
+	})
+	if err != nil {
+		return err
+	}
+	return q.TrimPuzzleEdits(ctx, db.TrimPuzzleEditsParams{PuzzleID: puzzleID, Keep: MaxEditHistory})
+}
+
+// editCells runs fn in a transaction and records the given cells, as they
+// were before and after it, as one edit.
+func (s *Service) editCells(ctx context.Context, puzzleID string, points []Point, fn func(q *db.Queries) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	before, err := snapshotCells(ctx, qtx, puzzleID, points)
+	if err != nil {
+		return err
+	}
+	if err := fn(qtx); err != nil {
+		return err
+	}
+	after, err := snapshotCells(ctx, qtx, puzzleID, points)
+	if err != nil {
+		return err
+	}
+	if err := s.recordEdit(ctx, qtx, puzzleID, EditCells, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Undo reverts the latest edit to a puzzle that is still applied. It reports
+// false when there is nothing to undo. Letters in solve sessions aren't part
+// of the history, so undoing an import doesn't bring back what solvers had
+// typed; like RestoreVersion, undo and redo clear unfinished solvers'
+// letters in squares whose block or answer changes.
+func (s *Service) Undo(ctx context.Context, puzzleID string) (bool, error) {
+	return s.stepHistory(ctx, puzzleID, true)
+}
+
+// Redo reapplies the earliest undone edit to a puzzle. It reports false when
+// there is nothing to redo.
+func (s *Service) Redo(ctx context.Context, puzzleID string) (bool, error) {
+	return s.stepHistory(ctx, puzzleID, false)
+}
+
+func (s *Service) stepHistory(ctx context.Context, puzzleID string, undo bool) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	var edit db.PuzzleEdit
+	if undo {
+		edit, err = qtx.GetLastAppliedPuzzleEdit(ctx, puzzleID)
+	} else {
+		edit, err = qtx.GetFirstUndonePuzzleEdit(ctx, puzzleID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	from, to := edit.UndoState, edit.RedoState
+	if undo {
+		from, to = to, from
+	}
+	var before, st gridState
+	if err := json.Unmarshal([]byte(from), &before); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(to), &st); err != nil {
+		return false, err
+	}
+
+	if err := applyGridState(ctx, qtx, puzzleID, st); err != nil {
+		return false, err
+	}
+	if err := clearChangedLetters(ctx, qtx, puzzleID, before, st); err != nil {
+		return false, err
+	}
+	if err := qtx.SetPuzzleEditUndone(ctx, db.SetPuzzleEditUndoneParams{Undone: undo, ID: edit.ID}); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
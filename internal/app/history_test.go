@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"os"
+	"share_word/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditHistory(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "History", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	cell := func(x, y int64) db.Cell {
+		c, err := svc.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: x, Y: y})
+		require.NoError(t, err)
+		return c
+	}
+	step := func(undo bool) bool {
+		var changed bool
+		if undo {
+			changed, err = svc.Undo(ctx, p.ID)
+		} else {
+			changed, err = svc.Redo(ctx, p.ID)
+		}
+		require.NoError(t, err)
+		return changed
+	}
+
+	t.Run("nothing to undo on a fresh puzzle", func(t *testing.T) {
+		assert.False(t, step(true))
+		assert.False(t, step(false))
+	})
+
+	t.Run("cell edits", func(t *testing.T) {
+		require.NoError(t, svc.SetCellSolution(ctx, p.ID, 0, 0, "A"))
+		require.NoError(t, svc.SetBlocks(ctx, p.ID, []Point{{0, 0}, {4, 4}}, true))
+		assert.True(t, cell(4, 4).IsBlock)
+
+		assert.True(t, step(true))
+		assert.False(t, cell(0, 0).IsBlock)
+		assert.False(t, cell(4, 4).IsBlock)
+		assert.Equal(t, "A", cell(0, 0).Solution, "undoing a block brings its answer back")
+
+		assert.True(t, step(false))
+		assert.True(t, cell(0, 0).IsBlock)
+		assert.True(t, cell(4, 4).IsBlock)
+	})
+
+	t.Run("a new edit clears redo", func(t *testing.T) {
+		assert.True(t, step(true))
+		require.NoError(t, svc.ToggleCellStyle(ctx, p.ID, 2, 2, StyleCircle, ""))
+		assert.False(t, step(false))
+		assert.False(t, cell(0, 0).IsBlock)
+
+		assert.True(t, step(true))
+		assert.False(t, cell(2, 2).Circled)
+	})
+
+	t.Run("clues", func(t *testing.T) {
+		require.NoError(t, svc.SaveClue(ctx, p.ID, 1, DirectionAcross, "First"))
+		require.NoError(t, svc.SaveClue(ctx, p.ID, 1, DirectionAcross, "Second"))
+
+		assert.True(t, step(true))
+		clues, _ := svc.Queries.GetClues(ctx, p.ID)
+		require.Len(t, clues, 1)
+		assert.Equal(t, "First", clues[0].Text)
+
+		assert.True(t, step(true))
+		clues, _ = svc.Queries.GetClues(ctx, p.ID)
+		assert.Empty(t, clues)
+	})
+
+	t.Run("import and resize", func(t *testing.T) {
+		require.NoError(t, svc.ResizePuzzle(ctx, p.ID, 7, 6))
+		data, err := os.ReadFile("testdata/sample.ipuz")
+		require.NoError(t, err)
+		require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+		assert.True(t, cell(1, 1).IsBlock)
+
+		assert.True(t, step(true))
+		got, _ := svc.Queries.GetPuzzle(ctx, p.ID)
+		assert.Equal(t, int64(7), got.Width)
+		assert.False(t, cell(1, 1).IsBlock)
+		cells, _ := svc.Queries.GetCells(ctx, p.ID)
+		assert.Len(t, cells, 42)
+
+		assert.True(t, step(true))
+		got, _ = svc.Queries.GetPuzzle(ctx, p.ID)
+		assert.Equal(t, int64(5), got.Width)
+		cells, _ = svc.Queries.GetCells(ctx, p.ID)
+		assert.Len(t, cells, 25)
+
+		assert.True(t, step(false))
+		assert.True(t, step(false))
+		assert.True(t, cell(1, 1).IsBlock)
+		assert.Equal(t, "A", cell(0, 0).Solution)
+	})
+
+	t.Run("undo clears letters that no longer fit", func(t *testing.T) {
+		sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 0, "A", false))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 2, 0, "C", false))
+		letter := func(x, y int64) string {
+			cells, err := svc.GetSolveCells(ctx, p.ID, sess.ID)
+			require.NoError(t, err)
+			for _, c := range cells {
+				if c.X == x && c.Y == y {
+					return c.Char
+				}
+			}
+			return ""
+		}
+
+		require.NoError(t, svc.SetBlocks(ctx, p.ID, []Point{{2, 0}}, true))
+		assert.True(t, step(true))
+		assert.Equal(t, "", letter(2, 0), "the square was a block a moment ago")
+		assert.Equal(t, "A", letter(0, 0), "untouched squares keep their letters")
+	})
+
+	t.Run("history is capped", func(t *testing.T) {
+		for i := 0; i < MaxEditHistory+5; i++ {
+			require.NoError(t, svc.ToggleCellStyle(ctx, p.ID, 0, 0, StyleShade, ""))
+		}
+		undone := 0
+		for step(true) {
+			undone++
+		}
+		assert.Equal(t, MaxEditHistory, undone)
+	})
+}
@@ -2,10 +2,12 @@ package app
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"io"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -26,6 +28,10 @@ type ParsedCell struct {
 	Y       int
 	Char    string
 	IsBlock bool
+	Circled bool
+	Shaded  bool
+	Color   string // "#rrggbb", or empty
+	Bars    string // Thick edges, any of "T", "R", "B", "L"
 }
 
 type ParsedClue struct {
@@ -70,7 +76,7 @@ func ParsePuz(data []byte) (*ParsedPuzzle, error) {
 
 	width := int(header[0x2C])
 	height := int(header[0x2D])
-	// numClues := binary.LittleEndian.Uint16(header[0x2E:0x30])
+	numClues := int(binary.LittleEndian.Uint16(header[0x2E:0x30]))
 
 	numCells := width * height
 
@@ -153,6 +159,34 @@ func ParsePuz(data []byte) (*ParsedPuzzle, error) {
 		}
 	}
 
+	// Skip clues the grid walk didn't claim so the notes line up
+	for i := len(clues); i < numClues; i++ {
+		_ = readString()
+	}
+	_ = readString() // notes
+
+	sections := readPuzSections(r)
+
+	// GEXT holds a flag byte per cell; 0x80 marks a circle
+	if gext, ok := sections["GEXT"]; ok && len(gext) == numCells {
+		for i, flags := range gext {
+			cells[i].Circled = flags&0x80 != 0
+		}
+	}
+
+	// Rebus squares: GRBS maps each cell to an RTBL entry, off by one
+	if grbs, ok := sections["GRBS"]; ok && len(grbs) == numCells {
+		table := parseRebusTable(sections["RTBL"])
+		for i, key := range grbs {
+			if key == 0 || cells[i].IsBlock {
+				continue
+			}
+			if word, ok := table[int(key)-1]; ok {
+				cells[i].Char = word
+			}
+		}
+	}
+
 	return &ParsedPuzzle{
 		Title:  title,
 		Author: author,
@@ -163,6 +197,42 @@ func ParsePuz(data []byte) (*ParsedPuzzle, error) {
 	}, nil
 }
 
+// readPuzSections reads the extra sections that follow the strings in a .puz
+// file, keyed by name. Each is a 4 byte name, a little-endian length, a
+// checksum, the data and a trailing NUL. Anything malformed ends the scan.
+func readPuzSections(r *bytes.Reader) map[string][]byte {
+	sections := make(map[string][]byte)
+	for {
+		head := make([]byte, 8)
+		if _, err := io.ReadFull(r, head); err != nil {
+			return sections
+		}
+		body := make([]byte, binary.LittleEndian.Uint16(head[4:6]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return sections
+		}
+		sections[string(head[:4])] = body
+		_, _ = r.ReadByte()
+	}
+}
+
+// parseRebusTable reads an RTBL section, entries like " 1:HEART;".
+func parseRebusTable(data []byte) map[int]string {
+	table := make(map[int]string)
+	for _, entry := range strings.Split(string(data), ";") {
+		key, word, ok := strings.Cut(entry, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil {
+			continue
+		}
+		table[n] = strings.ToUpper(strings.TrimSpace(word))
+	}
+	return table
+}
+
 // ipuz parser remains same, but I'll make it more tolerant
 type ipuzFile struct {
 	Version    string   `json:"version"`
@@ -176,6 +246,56 @@ type ipuzFile struct {
 	Title    string                 `json:"title"`
 	Author   string                 `json:"author"`
 	Clues    map[string]interface{} `json:"clues"`
+	Styles   map[string]interface{} `json:"styles"`
+}
+
+// applyStyle copies the parts of an ipuz style we draw onto cell. A style
+// is either an object or the name of one in the file's styles table.
+func (f *ipuzFile) applyStyle(cell *ParsedCell, style interface{}) {
+	if name, ok := style.(string); ok {
+		style = f.Styles[name]
+	}
+	st, ok := style.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if shape, _ := st["shapebg"].(string); shape == "circle" {
+		cell.Circled = true
+	}
+	if highlight, _ := st["highlight"].(bool); highlight {
+		cell.Shaded = true
+	}
+	if color, ok := st["color"].(string); ok {
+		cell.Color = normalizeColor(color)
+	}
+	if barred, ok := st["barred"].(string); ok {
+		cell.Bars = normalizeBars(barred)
+	}
+}
+
+var hexColor = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// normalizeColor turns "RRGGBB" or "#rrggbb" into "#rrggbb". Anything else,
+// like ipuz's numbered palette colors, is dropped.
+func normalizeColor(color string) string {
+	if !hexColor.MatchString(color) {
+		return ""
+	}
+	return "#" + strings.ToLower(strings.TrimPrefix(color, "#"))
+}
+
+// normalizeBars keeps the edge letters we know, uppercased, once each, in
+// "TRBL" order.
+func normalizeBars(bars string) string {
+	bars = strings.ToUpper(bars)
+	var out strings.Builder
+	for _, edge := range "TRBL" {
+		if strings.ContainsRune(bars, edge) {
+			out.WriteRune(edge)
+		}
+	}
+	return out.String()
 }
 
 func ParseIpuz(data []byte) (*ParsedPuzzle, error) {
@@ -221,9 +341,11 @@ func ParseIpuz(data []byte) (*ParsedPuzzle, error) {
 					char = v
 				}
 			case map[string]interface{}:
-				if c, ok := v["cell"]; ok {
-					if s, ok := c.(string); ok {
+				// Solutions use "value"; older files put the answer in "cell"
+				for _, k := range []string{"value", "cell"} {
+					if s, ok := v[k].(string); ok {
 						char = s
+						break
 					}
 				}
 			case nil:
@@ -239,12 +361,21 @@ func ParseIpuz(data []byte) (*ParsedPuzzle, error) {
 				char = ""
 			}
 
-			cells = append(cells, ParsedCell{
+			cell := ParsedCell{
 				X:       x,
 				Y:       y,
 				Char:    strings.ToUpper(char),
 				IsBlock: isBlock,
-			})
+			}
+			// Styles usually sit on the puzzle grid, but allow them on the solution too
+			for _, grid := range [][][]interface{}{f.Puzzle, f.Solution} {
+				if y < len(grid) && x < len(grid[y]) {
+					if m, ok := grid[y][x].(map[string]interface{}); ok {
+						f.applyStyle(&cell, m["style"])
+					}
+				}
+			}
+			cells = append(cells, cell)
 		}
 	}
 
@@ -1,9 +1,12 @@
 package app
 
 import (
+	"encoding/binary"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseIpuz_StringClueNumbers(t *testing.T) {
@@ -36,3 +39,80 @@ func TestParseIpuz_StringClueNumbers(t *testing.T) {
 	assert.Equal(t, "Clue Text", parsed.Clues[0].Text)
 	assert.Equal(t, DirectionAcross, parsed.Clues[0].Direction)
 }
+
+func TestParsePuz_Rebus(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.puz")
+	require.NoError(t, err)
+
+	section := func(name string, body []byte) []byte {
+		head := []byte(name)
+		head = binary.LittleEndian.AppendUint16(head, uint16(len(body)))
+		head = append(head, 0, 0) // checksum, not verified
+		return append(append(head, body...), 0)
+	}
+	grbs := make([]byte, 25)
+	grbs[0] = 1  // RTBL key 0
+	grbs[24] = 3 // RTBL key 2
+	data = append(data, section("GRBS", grbs)...)
+	data = append(data, section("RTBL", []byte(" 0:HEART; 2:star;"))...)
+
+	parsed, err := ParsePuz(data)
+	require.NoError(t, err)
+	assert.Equal(t, "HEART", parsed.Cells[0].Char)
+	assert.Equal(t, "STAR", parsed.Cells[24].Char)
+	assert.Len(t, parsed.Cells[1].Char, 1)
+}
+
+func TestParseIpuz_Rebus(t *testing.T) {
+	jsonData := `{
+		"dimensions": {"width": 2, "height": 1},
+		"puzzle": [[1, 2]],
+		"solution": [["Heart", {"value": "OR"}]]
+	}`
+
+	parsed, err := ParseIpuz([]byte(jsonData))
+	require.NoError(t, err)
+	assert.Equal(t, "HEART", parsed.Cells[0].Char)
+	assert.Equal(t, "OR", parsed.Cells[1].Char)
+}
+
+func TestParseIpuz_Styles(t *testing.T) {
+	jsonData := `{
+		"dimensions": {"width": 4, "height": 1},
+		"styles": {"theme": {"highlight": true, "color": "3366CC"}},
+		"puzzle": [[
+			{"cell": 1, "style": {"shapebg": "circle"}},
+			{"cell": 2, "style": {"barred": "RB"}},
+			{"cell": 3, "style": "theme"},
+			{"cell": 4, "style": {"color": "not a color"}}
+		]],
+		"solution": [["A", "B", "C", "D"]]
+	}`
+
+	parsed, err := ParseIpuz([]byte(jsonData))
+	require.NoError(t, err)
+	assert.True(t, parsed.Cells[0].Circled)
+	assert.Equal(t, "RB", parsed.Cells[1].Bars)
+	assert.True(t, parsed.Cells[2].Shaded)
+	assert.Equal(t, "#3366cc", parsed.Cells[2].Color)
+	assert.Equal(t, "", parsed.Cells[3].Color)
+}
+
+func TestParsePuz_Circles(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.puz")
+	require.NoError(t, err)
+
+	gext := make([]byte, 25)
+	gext[2] = 0x80
+	gext[6] = 0x80 | 0x10
+	data = append(data, "GEXT"...)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(gext)))
+	data = append(data, 0, 0)
+	data = append(append(data, gext...), 0)
+
+	parsed, err := ParsePuz(data)
+	require.NoError(t, err)
+	assert.True(t, parsed.Cells[2].Circled)
+	assert.True(t, parsed.Cells[6].Circled)
+	assert.False(t, parsed.Cells[0].Circled)
+}
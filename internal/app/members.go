@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"share_word/internal/db"
+)
+
+// AddPuzzleMember invites username to the puzzle with the given role, or
+// changes their role if they are already a member. Only the owner may do this.
+func (s *Service) AddPuzzleMember(ctx context.Context, puzzleID, actorID, username string, role Role) error {
+	if _, err := s.AuthorizePuzzle(ctx, puzzleID, actorID, RoleOwner); err != nil {
+		return err
+	}
+
+	if !role.IsAssignable() {
+		return errors.New("invalid role")
+	}
+
+	user, err := s.Queries.GetUserByUsername(ctx, username)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.ID == actorID {
+		return errors.New("the owner cannot be invited")
+	}
+
+	return s.Queries.UpsertPuzzleMember(ctx, db.UpsertPuzzleMemberParams{
+		PuzzleID: puzzleID,
+		UserID:   user.ID,
+		Role:     string(role),
+	})
+}
+
+// RemovePuzzleMember revokes a member's access. Only the owner may do this.
+func (s *Service) RemovePuzzleMember(ctx context.Context, puzzleID, actorID, userID string) error {
+	if _, err := s.AuthorizePuzzle(ctx, puzzleID, actorID, RoleOwner); err != nil {
+		return err
+	}
+
+	return s.Queries.DeletePuzzleMember(ctx, db.DeletePuzzleMemberParams{
+		PuzzleID: puzzleID,
+		UserID:   userID,
+	})
+}
+
+func (s *Service) GetPuzzleMembers(ctx context.Context, puzzleID string) ([]db.GetPuzzleMembersRow, error) {
+	return s.Queries.GetPuzzleMembers(ctx, puzzleID)
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuzzleMembers(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, _ := svc.RegisterUser(ctx, "owner", "password123456")
+	friend, _ := svc.RegisterUser(ctx, "friend", "password123456")
+	p, err := svc.CreatePuzzle(ctx, "Shared", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	t.Run("owner invites a solver", func(t *testing.T) {
+		require.NoError(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "friend", RoleSolver))
+
+		role, err := svc.PuzzleRole(ctx, p.ID, friend.ID)
+		require.NoError(t, err)
+		assert.Equal(t, RoleSolver, role)
+
+		members, err := svc.GetPuzzleMembers(ctx, p.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, "friend", members[0].Username)
+	})
+
+	t.Run("re-inviting changes the role", func(t *testing.T) {
+		require.NoError(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "friend", RoleCoConstructor))
+		role, _ := svc.PuzzleRole(ctx, p.ID, friend.ID)
+		assert.Equal(t, RoleCoConstructor, role)
+	})
+
+	t.Run("rejects invalid invites", func(t *testing.T) {
+		assert.Error(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "nobody", RoleSolver))
+		assert.Error(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "friend", RoleOwner))
+		assert.Error(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "owner", RoleSolver))
+	})
+
+	t.Run("only the owner manages members", func(t *testing.T) {
+		err := svc.AddPuzzleMember(ctx, p.ID, friend.ID, "owner", RoleViewer)
+		assert.ErrorIs(t, err, ErrForbidden)
+		err = svc.RemovePuzzleMember(ctx, p.ID, friend.ID, friend.ID)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("owner removes a member", func(t *testing.T) {
+		require.NoError(t, svc.RemovePuzzleMember(ctx, p.ID, owner.ID, friend.ID))
+		role, _ := svc.PuzzleRole(ctx, p.ID, friend.ID)
+		assert.Equal(t, RoleNone, role)
+	})
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Config is how a Service is deployed. The zero Config runs a private NATS
+// server for a single instance; replicas share events by clustering their
+// embedded servers or by connecting to the same external one.
+type Config struct {
+	// An external NATS server to connect to instead of embedding one
+	NatsURL string
+
+	// Where the embedded server keeps its event log. Empty means a temporary
+	// directory, removed on Shutdown.
+	NatsStoreDir string
+
+	// Set to join the embedded server to a cluster: its name, unique in the
+	// cluster, the cluster's name, the host:port other nodes reach it on,
+	// and the nats-route:// URLs of the other nodes
+	NatsServerName    string
+	NatsCluster       string
+	NatsClusterListen string
+	NatsRoutes        []string
+
+	// How many copies of the event log the servers keep; 1 when unset
+	EventReplicas int
+}
+
+var errNatsNotReady = errors.New("NATS server did not become ready")
+
+// clustered reports whether the embedded server joins a cluster.
+func (c Config) clustered() bool {
+	return c.NatsCluster != ""
+}
+
+func (s *Service) startNats(cfg Config) {
+	url := cfg.NatsURL
+	if url == "" {
+		ns, err := s.startEmbeddedNats(cfg)
+		if err != nil {
+			log.Printf("Failed to start NATS server: %v", err)
+			return
+		}
+		s.NatsServer = ns
+		url = ns.ClientURL()
+	}
+
+	// Stay connected through restarts of an external server
+	nc, err := nats.Connect(url, nats.Name("shareword"), nats.MaxReconnects(-1))
+	if err != nil {
+		log.Printf("NATS client failed to connect to %s: %v", url, err)
+		return
+	}
+	log.Printf("NATS client connected to %s", url)
+	s.NC = nc
+	s.startEventLog(cfg)
+}
+
+// startEmbeddedNats runs the in-process NATS server, on its own or as a node
+// of the configured cluster.
+func (s *Service) startEmbeddedNats(cfg Config) (*server.Server, error) {
+	storeDir := cfg.NatsStoreDir
+	if storeDir == "" {
+		dir, err := os.MkdirTemp("", "shareword-nats-")
+		if err != nil {
+			return nil, err
+		}
+		storeDir = dir
+		s.tempStoreDir = dir
+	}
+
+	opts := &server.Options{
+		ServerName: cfg.NatsServerName,
+		Port:       -1,
+		NoLog:      true,
+		JetStream:  true,
+		StoreDir:   storeDir,
+	}
+	if cfg.clustered() {
+		host, port, err := net.SplitHostPort(cfg.NatsClusterListen)
+		if err != nil {
+			return nil, err
+		}
+		opts.Cluster.Name = cfg.NatsCluster
+		opts.Cluster.Host = host
+		if opts.Cluster.Port, err = strconv.Atoi(port); err != nil {
+			return nil, err
+		}
+		opts.Routes = server.RoutesFromStr(strings.Join(cfg.NatsRoutes, ","))
+	}
+
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		return nil, err
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		ns.Shutdown()
+		return nil, errNatsNotReady
+	}
+	log.Printf("NATS server ready at %s", ns.ClientURL())
+	return ns, nil
+}
+
+// startEventLog sets up the JetStream event log. Without it events still go
+// out, but streams that drop can't catch up. Until the log is ready it keeps
+// trying in the background.
+func (s *Service) startEventLog(cfg Config) {
+	js, err := jetstream.New(s.NC)
+	if err != nil {
+		log.Printf("JetStream unavailable: %v", err)
+		return
+	}
+	s.JS = js
+
+	// A cluster node can't create the log until it has found its peers
+	replicas := max(cfg.EventReplicas, 1)
+	if !cfg.clustered() {
+		if err = s.tryEventStream(replicas); err == nil {
+			return
+		}
+		log.Printf("Event log not ready, retrying in the background: %v", err)
+	}
+	go func() {
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(2 * time.Second):
+			}
+			if s.tryEventStream(replicas) == nil {
+				return
+			}
+		}
+	}()
+}
+
+// tryEventStream creates the event log's stream and marks the log ready.
+func (s *Service) tryEventStream(replicas int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.ensureEventStream(ctx, replicas); err != nil {
+		return err
+	}
+	s.logReady.Store(true)
+	log.Printf("Event log ready")
+	return nil
+}
@@ -0,0 +1,345 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"share_word/internal/db"
+	"sort"
+	"strings"
+	"time"
+)
+
+// presenceColors are the cursor colors handed out to participants.
+var presenceColors = []string{
+	"#e11d48", // rose
+	"#2563eb", // blue
+	"#16a34a", // green
+	"#d97706", // amber
+	"#7c3aed", // violet
+	"#0891b2", // cyan
+	"#db2777", // pink
+	"#65a30d", // lime
+}
+
+// PresenceColor picks a stable color for a user, or for an anonymous tab.
+func PresenceColor(id string) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return presenceColors[h.Sum32()%uint32(len(presenceColors))]
+}
+
+// Presence is one open tab on a puzzle. Key is the tab's
+// SessionToken:ClientID, the same key its cursor is stored under.
+type Presence struct {
+	Key       string
+	PuzzleID  string
+	SessionID string
+	Mode      string
+	UserID    string
+	Username  string
+	Color     string
+}
+
+// presenceEntry is a tab in the registry. A tab can briefly have two
+// streams open while the browser reconnects, so it stays until the last one
+// closes or it stops sending heartbeats.
+type presenceEntry struct {
+	Presence
+	streams  int
+	lastSeen time.Time
+}
+
+// Join records a tab as present when one of its streams opens. Only a tab's
+// first stream is announced, on this instance or any other.
+func (s *Service) Join(p Presence) {
+	if p.Color == "" {
+		id := p.UserID
+		if id == "" {
+			id = p.Key
+		}
+		p.Color = PresenceColor(id)
+	}
+	if p.Username == "" {
+		p.Username = "Guest"
+	}
+
+	s.presenceMu.Lock()
+	e, ok := s.presence[p.Key]
+	if !ok {
+		e = &presenceEntry{}
+		s.presence[p.Key] = e
+	}
+	e.Presence = p
+	e.streams++
+	e.lastSeen = time.Now()
+	s.presenceMu.Unlock()
+
+	announce := !ok && !s.openElsewhere(p.Key)
+	err := s.Queries.JoinTab(context.Background(), db.JoinTabParams{
+		TabKey:     p.Key,
+		InstanceID: s.instanceID,
+		PuzzleID:   p.PuzzleID,
+		SessionID:  p.SessionID,
+		Mode:       p.Mode,
+		UserID:     p.UserID,
+		Username:   p.Username,
+		Color:      p.Color,
+		SeenAt:     e.lastSeen.UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Failed to record presence of %s: %v", p.Key, err)
+	}
+	if announce {
+		s.BroadcastPresence(p, true)
+	}
+}
+
+// openElsewhere reports whether another instance still has a tab open, as
+// when the tab reconnected to it.
+func (s *Service) openElsewhere(key string) bool {
+	n, err := s.Queries.CountTabInstances(context.Background(), db.CountTabInstancesParams{
+		TabKey:    key,
+		SeenSince: time.Now().Add(-s.PresenceTimeout).UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Failed to check presence of %s: %v", key, err)
+		return false
+	}
+	return n > 0
+}
+
+// Heartbeat marks a tab as still connected. It reports false if the tab has
+// already been dropped, in which case its stream should join again.
+func (s *Service) Heartbeat(key string) bool {
+	s.presenceMu.Lock()
+	defer s.presenceMu.Unlock()
+	e, ok := s.presence[key]
+	if ok {
+		e.lastSeen = time.Now()
+	}
+	return ok
+}
+
+// Leave closes one of a tab's streams. Once none are left on any instance the
+// tab is dropped, along with its cursor and clue editor, and the others on
+// its grid are told.
+func (s *Service) Leave(key string) {
+	s.presenceMu.Lock()
+	e, ok := s.presence[key]
+	if ok {
+		e.streams--
+		if e.streams > 0 {
+			ok = false
+		} else {
+			delete(s.presence, key)
+		}
+	}
+	s.presenceMu.Unlock()
+
+	if ok {
+		s.dropTab(e.Presence)
+	}
+}
+
+// dropTab takes a tab this instance has closed off the shared registry. If
+// no other instance has it open it is gone: its state is forgotten and its
+// grid is told.
+func (s *Service) dropTab(p Presence) {
+	if err := s.Queries.LeaveTab(context.Background(), db.LeaveTabParams{TabKey: p.Key, InstanceID: s.instanceID}); err != nil {
+		log.Printf("Failed to record %s leaving: %v", p.Key, err)
+	}
+	if s.openElsewhere(p.Key) {
+		return
+	}
+	s.forgetTab(p.Key)
+	s.BroadcastPresence(p, false)
+}
+
+// forgetTab drops the per-tab state kept for a key.
+func (s *Service) forgetTab(key string) {
+	if err := s.Queries.DeleteTabStates(context.Background(), key); err != nil {
+		log.Printf("Failed to forget tab %s: %v", key, err)
+	}
+}
+
+// sweepLoop drops silent tabs and idle cached grids, and keeps solve timers
+// in step with other instances, until the service shuts down.
+func (s *Service) sweepLoop() {
+	ticker := time.NewTicker(s.PresenceTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.sweepPresence(now)
+			s.states.expire(now)
+			s.sweepSolveStreams(now)
+		}
+	}
+}
+
+// sweepPresence drops tabs that haven't sent a heartbeat within
+// PresenceTimeout, as if they had left, and keeps this instance's other tabs
+// fresh in the shared registry. Tabs another instance stopped refreshing,
+// e.g. because it crashed, are dropped too. Per-tab state can also outlive
+// its tab, e.g. when a request races the stream closing, so state with no
+// tab behind it is dropped once it has gone a sweep without changing.
+func (s *Service) sweepPresence(now time.Time) {
+	ctx := context.Background()
+	var gone []Presence
+	s.presenceMu.Lock()
+	for key, e := range s.presence {
+		if now.Sub(e.lastSeen) > s.PresenceTimeout {
+			delete(s.presence, key)
+			gone = append(gone, e.Presence)
+		}
+	}
+	s.presenceMu.Unlock()
+
+	for _, p := range gone {
+		log.Printf("Presence: dropping %s after %s without a heartbeat", p.Key, s.PresenceTimeout)
+		s.dropTab(p)
+	}
+
+	if err := s.Queries.TouchTabs(ctx, db.TouchTabsParams{SeenAt: now.UnixMilli(), InstanceID: s.instanceID}); err != nil {
+		log.Printf("Failed to refresh presence: %v", err)
+	}
+	stale, err := s.Queries.DeleteStaleTabs(ctx, now.Add(-s.PresenceTimeout).UnixMilli())
+	if err != nil {
+		log.Printf("Failed to sweep presence: %v", err)
+	}
+	for _, row := range stale {
+		if s.openElsewhere(row.TabKey) {
+			continue
+		}
+		log.Printf("Presence: dropping %s, which its instance stopped refreshing", row.TabKey)
+		s.forgetTab(row.TabKey)
+		s.BroadcastPresence(presenceOf(row), false)
+	}
+
+	if err := s.Queries.DeleteStrayTabStates(ctx, now.Add(-s.PresenceTimeout/3).UnixMilli()); err != nil {
+		log.Printf("Failed to sweep tab state: %v", err)
+	}
+}
+
+// presenceOf is a tab as the shared registry records it.
+func presenceOf(row db.TabPresence) Presence {
+	return Presence{
+		Key:       row.TabKey,
+		PuzzleID:  row.PuzzleID,
+		SessionID: row.SessionID,
+		Mode:      row.Mode,
+		UserID:    row.UserID,
+		Username:  row.Username,
+		Color:     row.Color,
+	}
+}
+
+// ClientID is the tab's part of its key.
+func (p Presence) ClientID() string {
+	return p.Key[strings.LastIndex(p.Key, ":")+1:]
+}
+
+// BroadcastPresence tells the streams on a tab's grid that it joined or
+// left.
+func (s *Service) BroadcastPresence(p Presence, joined bool) {
+	s.Publish(Event{
+		Type:      EventPresenceChanged,
+		PuzzleID:  p.PuzzleID,
+		SessionID: p.SessionID,
+		UserID:    p.UserID,
+		Presence:  &EventPresence{ClientID: p.ClientID(), Username: p.Username, Color: p.Color, Mode: p.Mode, Joined: joined},
+	})
+}
+
+// PresentOn lists the tabs on any instance looking at the same grid as the
+// given view, ordered by username.
+func (s *Service) PresentOn(puzzleID, mode, sessionID string) []Presence {
+	rows, err := s.Queries.ListTabsOn(context.Background(), db.ListTabsOnParams{
+		PuzzleID:  puzzleID,
+		Mode:      mode,
+		SessionID: sessionID,
+		SeenSince: time.Now().Add(-s.PresenceTimeout).UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Failed to list presence on %s: %v", puzzleID, err)
+	}
+	var present []Presence
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		// A reconnecting tab can briefly be on two instances
+		if !seen[row.TabKey] {
+			seen[row.TabKey] = true
+			present = append(present, presenceOf(row))
+		}
+	}
+	sort.Slice(present, func(i, j int) bool {
+		if present[i].Username != present[j].Username {
+			return present[i].Username < present[j].Username
+		}
+		return present[i].Key < present[j].Key
+	})
+	return present
+}
+
+// Roster is who is on a grid, one entry per user however many tabs they
+// have open.
+func Roster(present []Presence) []Presence {
+	seen := make(map[string]bool)
+	var roster []Presence
+	for _, p := range present {
+		id := p.UserID
+		if id == "" {
+			id = p.Key
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		roster = append(roster, p)
+	}
+	return roster
+}
+
+// PeerMark is how other participants show up on one square: their cursor,
+// or the word they are in.
+type PeerMark struct {
+	Color    string
+	Username string
+	Focused  bool
+}
+
+// PeerMarks places the cursors and active words of the given tabs on the
+// grid. A cursor wins over a word on the same square.
+func (s *Service) PeerMarks(width, height int, cells []db.Cell, peers []Presence) map[string]PeerMark {
+	marks := make(map[string]PeerMark)
+	for _, p := range peers {
+		v, ok := s.FocusedCells.Load(p.Key)
+		if !ok {
+			continue
+		}
+		var x, y int64
+		if _, err := fmt.Sscanf(v.(string), "%d,%d", &x, &y); err != nil {
+			log.Printf("Bad focus %q for %s", v, p.Key)
+			continue
+		}
+		dir := DirectionAcross
+		if d, ok := s.CurrentDirections.Load(p.Key); ok {
+			dir = d.(Direction)
+		}
+
+		for coord := range s.GetActiveWordCells(width, height, cells, x, y, dir) {
+			if _, taken := marks[coord]; !taken {
+				marks[coord] = PeerMark{Color: p.Color, Username: p.Username}
+			}
+		}
+		coord := fmt.Sprintf("%d,%d", x, y)
+		if !marks[coord].Focused {
+			marks[coord] = PeerMark{Color: p.Color, Username: p.Username, Focused: true}
+		}
+	}
+	return marks
+}
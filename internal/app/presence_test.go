@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresence(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Company", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	keys := func(present []Presence) []string {
+		var out []string
+		for _, p := range present {
+			out = append(out, p.Key)
+		}
+		return out
+	}
+
+	t.Run("joining announces the tab to its grid", func(t *testing.T) {
+		events := make(chan *nats.Msg, 4)
+		sub, err := svc.NC.ChanSubscribe(SessionSubject(p.ID, room.ID), events)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+
+		svc.Join(Presence{Key: "a:1", PuzzleID: p.ID, SessionID: room.ID, Mode: "solve", UserID: owner.ID, Username: "owner"})
+		select {
+		case msg := <-events:
+			event, err := ParseEvent(msg.Data)
+			require.NoError(t, err)
+			assert.Equal(t, EventPresenceChanged, event.Type)
+			assert.Equal(t, owner.ID, event.UserID)
+			assert.Equal(t, &EventPresence{ClientID: "1", Username: "owner", Color: PresenceColor(owner.ID), Mode: "solve", Joined: true}, event.Presence)
+		case <-time.After(time.Second):
+			t.Fatal("no presence event")
+		}
+	})
+
+	t.Run("tabs only see others on the same grid", func(t *testing.T) {
+		svc.Join(Presence{Key: "a:2", PuzzleID: p.ID, SessionID: room.ID, Mode: "solve", UserID: owner.ID, Username: "owner"})
+		svc.Join(Presence{Key: "b:1", PuzzleID: p.ID, SessionID: room.ID, Mode: "solve"})
+		svc.Join(Presence{Key: "c:1", PuzzleID: p.ID, SessionID: "", Mode: "solve"})
+		svc.Join(Presence{Key: "d:1", PuzzleID: p.ID, SessionID: "", Mode: "edit"})
+
+		present := svc.PresentOn(p.ID, "solve", room.ID)
+		assert.Equal(t, []string{"b:1", "a:1", "a:2"}, keys(present), "guests sort before owner")
+		assert.Equal(t, "Guest", present[0].Username)
+		assert.Equal(t, PresenceColor(owner.ID), present[1].Color)
+
+		assert.Equal(t, []string{"d:1"}, keys(svc.PresentOn(p.ID, "edit", "")))
+		assert.Equal(t, []string{"b:1", "a:1"}, keys(Roster(present)), "one roster entry per user")
+	})
+
+	t.Run("cursors and words are marked in the peer's color", func(t *testing.T) {
+		svc.FocusedCells.Store("a:1", "1,0")
+		svc.CurrentDirections.Store("a:1", DirectionAcross)
+		defer svc.FocusedCells.Delete("a:1")
+		defer svc.CurrentDirections.Delete("a:1")
+
+		cells, err := svc.Queries.GetCells(ctx, p.ID)
+		require.NoError(t, err)
+		marks := svc.PeerMarks(5, 5, cells, svc.PresentOn(p.ID, "solve", room.ID))
+
+		color := PresenceColor(owner.ID)
+		assert.Equal(t, PeerMark{Color: color, Username: "owner", Focused: true}, marks["1,0"])
+		for x := 0; x < 5; x++ {
+			if x != 1 {
+				assert.Equal(t, PeerMark{Color: color, Username: "owner"}, marks[fmt.Sprintf("%d,0", x)])
+			}
+		}
+		assert.NotContains(t, marks, "0,1")
+	})
+
+	t.Run("leaving drops the tab", func(t *testing.T) {
+		svc.Leave("b:1")
+		svc.Leave("b:1")
+		assert.Equal(t, []string{"a:1", "a:2"}, keys(svc.PresentOn(p.ID, "solve", room.ID)))
+	})
+}
+
+func TestPresenceLifecycle(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Comings", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	events := make(chan *nats.Msg, 16)
+	sub, err := svc.NC.ChanSubscribe("puzzles."+p.ID, events)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	announced := func() bool {
+		select {
+		case msg := <-events:
+			event, err := ParseEvent(msg.Data)
+			return err == nil && event.Type == EventPresenceChanged
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+	present := func() int { return len(svc.PresentOn(p.ID, "solve", "")) }
+	tab := Presence{Key: "tok:1", PuzzleID: p.ID, Mode: "solve"}
+	setState := func(key string) {
+		svc.EditingClues.Store(key, "1-across")
+		svc.FocusedCells.Store(key, "0,0")
+		svc.CurrentDirections.Store(key, DirectionAcross)
+	}
+	hasState := func(key string) bool {
+		_, a := svc.EditingClues.Load(key)
+		_, b := svc.FocusedCells.Load(key)
+		_, c := svc.CurrentDirections.Load(key)
+		return a || b || c
+	}
+
+	t.Run("a reconnecting tab stays until its last stream closes", func(t *testing.T) {
+		svc.Join(tab)
+		assert.True(t, announced())
+		svc.Join(tab)
+		assert.False(t, announced(), "a second stream isn't a new arrival")
+		setState(tab.Key)
+
+		svc.Leave(tab.Key)
+		assert.Equal(t, 1, present())
+		assert.True(t, hasState(tab.Key))
+
+		svc.Leave(tab.Key)
+		assert.Equal(t, 0, present())
+		assert.False(t, hasState(tab.Key), "leaving clears the tab's cursor and clue editor")
+		assert.True(t, announced(), "others are told when someone leaves")
+	})
+
+	t.Run("silent tabs time out", func(t *testing.T) {
+		svc.Join(tab)
+		require.True(t, announced())
+		setState(tab.Key)
+
+		now := time.Now()
+		svc.sweepPresence(now)
+		assert.True(t, svc.Heartbeat(tab.Key))
+		assert.Equal(t, 1, present())
+
+		svc.sweepPresence(now.Add(svc.PresenceTimeout + time.Second))
+		assert.Equal(t, 0, present())
+		assert.False(t, hasState(tab.Key))
+		assert.True(t, announced())
+		assert.False(t, svc.Heartbeat(tab.Key), "the stream is told to join again")
+
+		svc.Leave(tab.Key)
+		assert.False(t, announced(), "a late close of a dropped tab is ignored")
+	})
+
+	t.Run("state without a tab is dropped once it goes a sweep unchanged", func(t *testing.T) {
+		svc.Join(tab)
+		defer svc.Leave(tab.Key)
+		setState(tab.Key)
+		setState("stray:1")
+
+		now := time.Now()
+		svc.sweepPresence(now)
+		assert.True(t, hasState("stray:1"))
+
+		svc.sweepPresence(now.Add(svc.PresenceTimeout / 2))
+		assert.False(t, hasState("stray:1"))
+		assert.True(t, hasState(tab.Key))
+	})
+}
+
+func TestPresenceAcrossInstances(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+	other := NewService(svc.Queries, svc.db)
+	defer other.Shutdown()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Elsewhere", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	events := make(chan *nats.Msg, 16)
+	sub, err := svc.NC.ChanSubscribe("puzzles."+p.ID, events)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	announced := func() bool {
+		select {
+		case msg := <-events:
+			event, err := ParseEvent(msg.Data)
+			return err == nil && event.Type == EventPresenceChanged
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+	tab := Presence{Key: "tok:1", PuzzleID: p.ID, Mode: "solve", UserID: owner.ID, Username: "owner"}
+
+	t.Run("tabs and cursors on one instance show on another", func(t *testing.T) {
+		// Each has its own bus here, so only this instance's events are seen
+		other.Join(tab)
+		other.FocusedCells.Store(tab.Key, "2,0")
+
+		present := svc.PresentOn(p.ID, "solve", "")
+		require.Len(t, present, 1)
+		assert.Equal(t, tab.Key, present[0].Key)
+		focus, ok := svc.FocusedCells.Load(tab.Key)
+		require.True(t, ok)
+		assert.Equal(t, "2,0", focus)
+
+		cells, err := svc.Queries.GetCells(ctx, p.ID)
+		require.NoError(t, err)
+		assert.True(t, svc.PeerMarks(5, 5, cells, present)["2,0"].Focused)
+	})
+
+	t.Run("a tab reconnecting to another instance keeps its cursor", func(t *testing.T) {
+		svc.Join(tab)
+		assert.False(t, announced(), "it was already here")
+		other.Leave(tab.Key)
+		assert.False(t, announced())
+		_, ok := svc.FocusedCells.Load(tab.Key)
+		assert.True(t, ok)
+		assert.Len(t, svc.PresentOn(p.ID, "solve", ""), 1)
+	})
+
+	t.Run("tabs of an instance that goes quiet are dropped", func(t *testing.T) {
+		other.Join(tab)
+		svc.Leave(tab.Key)
+		require.False(t, announced())
+
+		// It crashed, so it never lets go
+		other.presenceMu.Lock()
+		delete(other.presence, tab.Key)
+		other.presenceMu.Unlock()
+
+		svc.sweepPresence(time.Now())
+		assert.Len(t, svc.PresentOn(p.ID, "solve", ""), 1)
+		svc.sweepPresence(time.Now().Add(svc.PresenceTimeout + time.Second))
+		assert.Empty(t, svc.PresentOn(p.ID, "solve", ""))
+		_, ok := svc.FocusedCells.Load(tab.Key)
+		assert.False(t, ok)
+		assert.True(t, announced(), "its grid is told it left")
+	})
+}
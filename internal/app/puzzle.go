@@ -266,6 +266,159 @@ func (s *Service) CreatePuzzle(ctx context.Context, name string, ownerID string,
 	return &puzzle, nil
 }
 
+// SetPuzzleVisibility changes who can find and watch a puzzle. Only the owner may do this.
+func (s *Service) SetPuzzleVisibility(ctx context.Context, puzzleID, actorID string, visibility Visibility) error {
+	if _, err := s.AuthorizePuzzle(ctx, puzzleID, actorID, RoleOwner); err != nil {
+		return err
+	}
+
+	if !visibility.IsValid() {
+		return errors.New("invalid visibility")
+	}
+
+	return s.Queries.UpdatePuzzleVisibility(ctx, db.UpdatePuzzleVisibilityParams{
+		Visibility: string(visibility),
+		ID:         puzzleID,
+	})
+}
+
+// CellStyle is a mark a constructor can toggle on a cell in edit mode.
+type CellStyle string
+
+const (
+	StyleCircle    CellStyle = "circle"
+	StyleShade     CellStyle = "shade"
+	StyleColor     CellStyle = "color"
+	StyleBarTop    CellStyle = "bar-top"
+	StyleBarRight  CellStyle = "bar-right"
+	StyleBarBottom CellStyle = "bar-bottom"
+	StyleBarLeft   CellStyle = "bar-left"
+)
+
+var barEdges = map[CellStyle]string{
+	StyleBarTop:    "T",
+	StyleBarRight:  "R",
+	StyleBarBottom: "B",
+	StyleBarLeft:   "L",
+}
+
+// SetCellSolution writes a cell's answer, normalized like a rebus entry so
+// a square can hold several letters. An empty solution clears the cell.
+// Blocks have no answer and are left alone.
+func (s *Service) SetCellSolution(ctx context.Context, puzzleID string, x, y int64, solution string) error {
+	return s.editCells(ctx, puzzleID, []Point{{x, y}}, func(q *db.Queries) error {
+		return q.UpdateCellSolution(ctx, db.UpdateCellSolutionParams{
+			Solution: NormalizeRebus(solution),
+			PuzzleID: puzzleID,
+			X:        x,
+			Y:        y,
+		})
+	})
+}
+
+// SetBlocks makes the given cells blocks or open squares. Cells made blocks
+// lose their answers; cells opened keep theirs, so a former block comes back
+// without one.
+func (s *Service) SetBlocks(ctx context.Context, puzzleID string, points []Point, isBlock bool) error {
+	return s.editCells(ctx, puzzleID, points, func(q *db.Queries) error {
+		for _, pt := range points {
+			// UpdateCell clears the answer of a square made a block and
+			// keeps it otherwise, so no Solution is passed
+			err := q.UpdateCell(ctx, db.UpdateCellParams{
+				PuzzleID: puzzleID,
+				X:        pt.X,
+				Y:        pt.Y,
+				Char:     "",
+				IsBlock:  isBlock,
+				IsPencil: false,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveClue sets a clue's text.
+func (s *Service) SaveClue(ctx context.Context, puzzleID string, number int64, direction Direction, text string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	before, err := snapshotClue(ctx, qtx, puzzleID, number, direction)
+	if err != nil {
+		return err
+	}
+	err = qtx.UpsertClue(ctx, db.UpsertClueParams{
+		PuzzleID:  puzzleID,
+		Number:    number,
+		Direction: string(direction),
+		Text:      text,
+	})
+	if err != nil {
+		return err
+	}
+	after, err := snapshotClue(ctx, qtx, puzzleID, number, direction)
+	if err != nil {
+		return err
+	}
+	if err := s.recordEdit(ctx, qtx, puzzleID, EditClue, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ToggleCellStyle flips one style on a cell. With StyleColor the cell takes
+// color, or loses it if it already has that color.
+func (s *Service) ToggleCellStyle(ctx context.Context, puzzleID string, x, y int64, style CellStyle, color string) error {
+	cell, err := s.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: puzzleID, X: x, Y: y})
+	if err != nil {
+		return err
+	}
+
+	switch style {
+	case StyleCircle:
+		cell.Circled = !cell.Circled
+	case StyleShade:
+		cell.Shaded = !cell.Shaded
+	case StyleColor:
+		color = normalizeColor(color)
+		if color == "" {
+			return errors.New("invalid color")
+		}
+		if cell.Color == color {
+			color = ""
+		}
+		cell.Color = color
+	default:
+		edge, ok := barEdges[style]
+		if !ok {
+			return errors.New("invalid style")
+		}
+		if strings.Contains(cell.Bars, edge) {
+			cell.Bars = strings.ReplaceAll(cell.Bars, edge, "")
+		} else {
+			cell.Bars = normalizeBars(cell.Bars + edge)
+		}
+	}
+
+	return s.editCells(ctx, puzzleID, []Point{{x, y}}, func(q *db.Queries) error {
+		return q.UpdateCellStyle(ctx, db.UpdateCellStyleParams{
+			Circled:  cell.Circled,
+			Shaded:   cell.Shaded,
+			Color:    cell.Color,
+			Bars:     cell.Bars,
+			PuzzleID: puzzleID,
+			X:        x,
+			Y:        y,
+		})
+	})
+}
+
 func (s *Service) ResizePuzzle(ctx context.Context, puzzleID string, newWidth, newHeight int64) error {
 	if newWidth < 2 || newHeight < 2 {
 		return errors.New("grid must be at least 2x2")
@@ -285,6 +438,10 @@ func (s *Service) ResizePuzzle(ctx context.Context, puzzleID string, newWidth, n
 	if err != nil {
 		return err
 	}
+	before, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
 
 	oldWidth := p.Width
 	oldHeight := p.Height
@@ -307,6 +464,14 @@ func (s *Service) ResizePuzzle(ctx context.Context, puzzleID string, newWidth, n
 		if err != nil {
 			return err
 		}
+		err = qtx.DeleteSessionCellsOutside(ctx, db.DeleteSessionCellsOutsideParams{
+			PuzzleID: puzzleID,
+			X:        newWidth,
+			Y:        newHeight,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	// Only insert newly created cells
@@ -329,6 +494,14 @@ func (s *Service) ResizePuzzle(ctx context.Context, puzzleID string, newWidth, n
 		}
 	}
 
+	after, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
+	if err := s.recordEdit(ctx, qtx, puzzleID, EditResize, before, after); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -350,6 +523,11 @@ func (s *Service) ImportPuzzle(ctx context.Context, puzzleID string, data []byte
 	defer tx.Rollback()
 	qtx := s.Queries.WithTx(tx)
 
+	before, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
+
 	// Update Dimensions
 	err = qtx.UpdatePuzzleDimensions(ctx, db.UpdatePuzzleDimensionsParams{
 		Width:  int64(parsed.Width),
@@ -367,17 +545,20 @@ func (s *Service) ImportPuzzle(ctx context.Context, puzzleID string, data []byte
 	if err := qtx.DeleteAllClues(ctx, puzzleID); err != nil {
 		return err
 	}
-
 	// Insert Cells
 	for _, cell := range parsed.Cells {
 		err = qtx.ImportCell(ctx, db.ImportCellParams{
 			PuzzleID: puzzleID,
 			X:        int64(cell.X),
 			Y:        int64(cell.Y),
-			Char:     "",        // User state starts empty
+			Char:     "",        // Player state lives in solve sessions
 			Solution: cell.Char, // Correct answer
 			IsBlock:  cell.IsBlock,
 			IsPencil: false,
+			Circled:  cell.Circled,
+			Shaded:   cell.Shaded,
+			Color:    cell.Color,
+			Bars:     cell.Bars,
 		})
 		if err != nil {
 			return err
@@ -397,6 +578,26 @@ func (s *Service) ImportPuzzle(ctx context.Context, puzzleID string, data []byte
 		}
 	}
 
+	after, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
+	// Letters typed against squares that changed no longer line up
+	err = qtx.DeleteSessionCellsOutside(ctx, db.DeleteSessionCellsOutsideParams{
+		PuzzleID: puzzleID,
+		X:        after.Width,
+		Y:        after.Height,
+	})
+	if err != nil {
+		return err
+	}
+	if err := clearChangedLetters(ctx, qtx, puzzleID, before, after); err != nil {
+		return err
+	}
+	if err := s.recordEdit(ctx, qtx, puzzleID, EditImport, before, after); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -517,6 +718,24 @@ func (s *Service) GetClueJumpTarget(ctx context.Context, pID string, cells []db.
 	return s.getClueStart(width, height, cells, &targetClue)
 }
 
+// GetClueFocusTarget finds the square a jump to the given clue lands on, the
+// same way GetClueJumpTarget lands on the next clue. It reports false if the
+// puzzle has no such clue.
+func (s *Service) GetClueFocusTarget(ctx context.Context, pID string, cells []db.Cell, number int, dir Direction) (int64, int64, bool) {
+	p, err := s.Queries.GetPuzzle(ctx, pID)
+	if err != nil {
+		return 0, 0, false
+	}
+	clues := s.DeriveClues(int(p.Width), int(p.Height), cells)
+	for i := range clues {
+		if clues[i].Number == number && clues[i].Direction == dir {
+			x, y, _ := s.getClueStart(int(p.Width), int(p.Height), cells, &clues[i])
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
 func (s *Service) getClueStart(width, height int, cells []db.Cell, targetClue *Clue) (int64, int64, Direction) {
 	annotated := s.CalculateNumbers(width, height, cells)
 	for _, ac := range annotated {
@@ -257,3 +257,69 @@ func TestSymmetryLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestToggleCellStyle(t *testing.T) {
+	service, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	user, err := service.RegisterUser(ctx, "styler", "password123456")
+	assert.NoError(t, err)
+	p, err := service.CreatePuzzle(ctx, "Styled", user.ID, 5, 5)
+	assert.NoError(t, err)
+
+	cell := func() db.Cell {
+		c, err := service.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: 1, Y: 1})
+		assert.NoError(t, err)
+		return c
+	}
+
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleCircle, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleShade, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleBarRight, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleBarTop, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleColor, "#FDE68A"))
+	got := cell()
+	assert.True(t, got.Circled)
+	assert.True(t, got.Shaded)
+	assert.Equal(t, "TR", got.Bars)
+	assert.Equal(t, "#fde68a", got.Color)
+
+	// Toggling again clears each mark
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleCircle, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleBarTop, ""))
+	assert.NoError(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleColor, "#fde68a"))
+	got = cell()
+	assert.False(t, got.Circled)
+	assert.Equal(t, "R", got.Bars)
+	assert.Equal(t, "", got.Color)
+
+	assert.Error(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, "sparkle", ""))
+	assert.Error(t, service.ToggleCellStyle(ctx, p.ID, 1, 1, StyleColor, "red"))
+}
+
+func TestSetBlocksSolutions(t *testing.T) {
+	service, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	user, err := service.RegisterUser(ctx, "blocker", "password123456")
+	assert.NoError(t, err)
+	p, err := service.CreatePuzzle(ctx, "Blocked", user.ID, 5, 5)
+	assert.NoError(t, err)
+
+	cell := func(x, y int64) db.Cell {
+		c, err := service.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: x, Y: y})
+		assert.NoError(t, err)
+		return c
+	}
+	assert.NoError(t, service.SetCellSolution(ctx, p.ID, 0, 0, "A"))
+	assert.NoError(t, service.SetCellSolution(ctx, p.ID, 1, 0, "B"))
+
+	assert.NoError(t, service.SetBlocks(ctx, p.ID, []Point{{X: 0, Y: 0}}, true))
+	assert.True(t, cell(0, 0).IsBlock)
+	assert.Equal(t, "", cell(0, 0).Solution, "blocking clears the answer")
+
+	assert.NoError(t, service.SetBlocks(ctx, p.ID, []Point{{X: 0, Y: 0}, {X: 1, Y: 0}}, false))
+	assert.False(t, cell(0, 0).IsBlock)
+	assert.Equal(t, "", cell(0, 0).Solution, "a former block opens without an answer")
+	assert.Equal(t, "B", cell(1, 0).Solution, "an open square keeps its answer")
+}
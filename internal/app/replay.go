@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"share_word/internal/db"
+	"time"
+)
+
+// Replay is a solve session's letters in the order they went in, for playing
+// the solve back.
+type Replay struct {
+	Session db.SolveSession
+	Entries []db.SolveEntry
+	// Length is the solve clock at the last entry
+	Length time.Duration
+}
+
+// OpenReplay loads the solve session to play back, checking that userID may
+// watch it. Anyone who can see the puzzle may watch a room, or a personal
+// solve once it is finished; an unfinished personal solve is its owner's
+// alone. An empty sessionID means the caller's personal session.
+func (s *Service) OpenReplay(ctx context.Context, puzzleID, sessionID, userID string) (Replay, error) {
+	var sess db.SolveSession
+	var err error
+	if sessionID == "" {
+		sess, err = s.OpenSolveSession(ctx, puzzleID, "", userID, RoleViewer)
+	} else if _, err = s.AuthorizePuzzle(ctx, puzzleID, userID, RoleViewer); err == nil {
+		sess, err = s.Queries.GetSolveSession(ctx, sessionID)
+		if err == nil && sess.PuzzleID != puzzleID {
+			err = sql.ErrNoRows
+		} else if err == nil && SessionKind(sess.Kind) == SessionPersonal && sess.OwnerID != userID && !sess.CompletedAt.Valid {
+			err = ErrForbidden
+		}
+	}
+	if err != nil {
+		return Replay{}, err
+	}
+
+	entries, err := s.Queries.GetSolveEntries(ctx, sess.ID)
+	if err != nil {
+		return Replay{}, err
+	}
+	r := Replay{Session: sess, Entries: entries}
+	if len(entries) > 0 {
+		r.Length = time.Duration(entries[len(entries)-1].ElapsedMs) * time.Millisecond
+	}
+	return r, nil
+}
+
+// At is the grid as it stood at the given point on the solve clock: a copy of
+// the puzzle's cells with the letters in place then, and their marks keyed by
+// "x,y". Entries for squares that are now blocks or outside the grid are
+// skipped.
+func (r Replay) At(cells []db.Cell, at time.Duration) ([]db.Cell, map[string]CellMark) {
+	grid := make([]db.Cell, len(cells))
+	index := make(map[string]int, len(cells))
+	for i, c := range cells {
+		c.Char = ""
+		c.IsPencil = false
+		grid[i] = c
+		index[fmt.Sprintf("%d,%d", c.X, c.Y)] = i
+	}
+
+	marks := make(map[string]CellMark)
+	for _, e := range r.Entries {
+		// Entries are stamped in order, so the rest come later
+		if time.Duration(e.ElapsedMs)*time.Millisecond > at {
+			break
+		}
+		key := fmt.Sprintf("%d,%d", e.X, e.Y)
+		i, ok := index[key]
+		if !ok || grid[i].IsBlock {
+			continue
+		}
+		grid[i].Char = e.Char
+		grid[i].IsPencil = e.IsPencil
+		switch {
+		case e.Revealed:
+			marks[key] = CellMark{Revealed: true}
+		case e.Char != "" && e.UserID != "":
+			marks[key] = CellMark{EnteredBy: e.UserID}
+		default:
+			delete(marks, key)
+		}
+	}
+	return grid, marks
+}
+
+// logEntry adds a letter to a session's replay, stamped with the solve clock.
+// q may be bound to a transaction.
+func logEntry(ctx context.Context, q *db.Queries, sessionID, userID string, x, y int64, char string, pencil, revealed bool) error {
+	now := time.Now()
+	var elapsed time.Duration
+	if sess, err := q.GetSolveSession(ctx, sessionID); err == nil {
+		elapsed = SolveElapsed(sess, now)
+	}
+	return q.CreateSolveEntry(ctx, db.CreateSolveEntryParams{
+		SessionID: sessionID,
+		X:         x,
+		Y:         y,
+		Char:      char,
+		IsPencil:  pencil,
+		Revealed:  revealed,
+		UserID:    userID,
+		ElapsedMs: elapsed.Milliseconds(),
+		EnteredAt: now.UnixMilli(),
+	})
+}
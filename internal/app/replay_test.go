@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"share_word/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolveReplay(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	watcher, err := svc.RegisterUser(ctx, "watcher", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Replayed", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, VisibilityPublic))
+	sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	cells, err := svc.Queries.GetCells(ctx, p.ID)
+	require.NoError(t, err)
+
+	t.Run("letters, clears and reveals are logged", func(t *testing.T) {
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, owner.ID, 0, 0, "X", false))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, owner.ID, 0, 0, "", false))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, owner.ID, 2, 0, "Y", true))
+		require.NoError(t, svc.RevealCells(ctx, p.ID, sess.ID, ScopeLetter, 1, 0, DirectionAcross))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, owner.ID, 1, 0, "Z", false))
+
+		entries, err := svc.Queries.GetSolveEntries(ctx, sess.ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 4, "typing over a revealed square changes nothing")
+		assert.Equal(t, [3]any{"X", false, owner.ID}, [3]any{entries[0].Char, entries[0].IsPencil, entries[0].UserID})
+		assert.Equal(t, [3]any{"", false, ""}, [3]any{entries[1].Char, entries[1].IsPencil, entries[1].UserID})
+		assert.Equal(t, [3]any{"Y", true, owner.ID}, [3]any{entries[2].Char, entries[2].IsPencil, entries[2].UserID})
+		assert.True(t, entries[3].Revealed)
+		assert.Equal(t, cells[1].Solution, entries[3].Char)
+		for _, e := range entries {
+			assert.NotZero(t, e.EnteredAt)
+		}
+	})
+
+	t.Run("the grid at a point on the solve clock", func(t *testing.T) {
+		r := Replay{Entries: []db.SolveEntry{
+			{X: 0, Y: 0, Char: "A", UserID: owner.ID, ElapsedMs: 0},
+			{X: 2, Y: 0, Char: "Q", IsPencil: true, UserID: watcher.ID, ElapsedMs: 1000},
+			{X: 0, Y: 0, Char: "", ElapsedMs: 2000},
+			{X: 1, Y: 0, Char: "B", Revealed: true, ElapsedMs: 3000},
+			{X: 9, Y: 9, Char: "C", ElapsedMs: 3000},
+		}}
+		at := func(ms int) ([]db.Cell, map[string]CellMark) {
+			return r.At(cells, time.Duration(ms)*time.Millisecond)
+		}
+
+		grid, marks := at(0)
+		assert.Equal(t, "A", grid[0].Char)
+		assert.Equal(t, CellMark{EnteredBy: owner.ID}, marks["0,0"])
+		assert.Equal(t, "", grid[2].Char)
+
+		grid, marks = at(2500)
+		assert.Equal(t, "", grid[0].Char)
+		assert.NotContains(t, marks, "0,0")
+		assert.Equal(t, "Q", grid[2].Char)
+		assert.True(t, grid[2].IsPencil)
+
+		grid, marks = at(3000)
+		assert.Equal(t, "B", grid[1].Char)
+		assert.Equal(t, CellMark{Revealed: true}, marks["1,0"])
+		assert.Len(t, grid, len(cells), "squares outside the grid are skipped")
+		assert.Equal(t, "", cells[0].Char, "the puzzle's cells are left alone")
+	})
+
+	t.Run("who may watch", func(t *testing.T) {
+		r, err := svc.OpenReplay(ctx, p.ID, "", owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sess.ID, r.Session.ID)
+		assert.Len(t, r.Entries, 4)
+
+		_, err = svc.OpenReplay(ctx, p.ID, sess.ID, watcher.ID)
+		assert.ErrorIs(t, err, ErrForbidden, "an unfinished personal solve is private")
+
+		require.NoError(t, svc.Queries.CompleteSolveSession(ctx, db.CompleteSolveSessionParams{
+			ElapsedMs:   60000,
+			CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:          sess.ID,
+		}))
+		_, err = svc.OpenReplay(ctx, p.ID, sess.ID, watcher.ID)
+		assert.NoError(t, err, "finished solves can be shared")
+
+		room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		_, err = svc.OpenReplay(ctx, p.ID, room.ID, watcher.ID)
+		assert.NoError(t, err)
+
+		other, err := svc.CreatePuzzle(ctx, "Other", watcher.ID, 5, 5)
+		require.NoError(t, err)
+		_, err = svc.OpenReplay(ctx, other.ID, room.ID, watcher.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows, "sessions belong to their puzzle")
+	})
+}
@@ -1,22 +1,27 @@
 package app
 
 import (
-	"context"
 	"database/sql"
 
-	"fmt"
-
 		"log"
 
+		"os"
+
 		"share_word/internal/db"
 
 		"sync"
 
+		"sync/atomic"
+
 		"time"
 
+	"github.com/google/uuid"
+
 	"github.com/nats-io/nats-server/v2/server"
 
 	"github.com/nats-io/nats.go"
+
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 type Service struct {
@@ -30,86 +35,116 @@ type Service struct {
 
 	NC *nats.Conn
 
+	// The event log; nil when the bus keeps none
+	JS jetstream.JetStream
+
+	// Set once the log's stream exists; until then events aren't logged
+	logReady atomic.Bool
+
+	// Temporary event log directory to remove on Shutdown
+	tempStoreDir string
+
 	StartTime int64
 
 	// SessionToken:ClientID -> ClueID
-	EditingClues sync.Map
+	EditingClues TabState
 
 	// SessionToken:ClientID -> X,Y
-	FocusedCells sync.Map
+	FocusedCells TabState
 
 	// SessionToken:ClientID -> Direction
-	CurrentDirections sync.Map
-}
+	CurrentDirections TabState
 
-func NewService(queries *db.Queries, dbConn *sql.DB) *Service {
+	// This instance's tabs: SessionToken:ClientID -> *presenceEntry, guarded
+	// by presenceMu
+	presence   map[string]*presenceEntry
+	presenceMu sync.Mutex
 
-	s := &Service{
+	// How long a tab may go without a heartbeat before it is dropped
+	PresenceTimeout time.Duration
 
-		Queries: queries,
+	// How long a solve timer keeps running after its last stream closes
+	TimerIdleGrace time.Duration
 
-		db: dbConn,
+	// SessionID -> *solveStreams
+	solveStreams sync.Map
 
-		StartTime: time.Now().UnixMilli(),
-	}
+	// Tells this instance apart from others sharing the database
+	instanceID string
 
-	s.startNats()
+	// PuzzleID -> *publishLock, guarded by publishMu. Each puzzle's events
+	// go out in revision order without waiting on other puzzles'.
+	publishLocks map[string]*publishLock
+	publishMu    sync.Mutex
 
-	return s
+	// Grids shared by the streams showing them
+	states stateCache
+
+	// ClientID -> EventCursor, the last cursor published for each tab
+	lastCursors sync.Map
 
+	stop chan struct{}
 }
 
-func (s *Service) startNats() {
+func NewService(queries *db.Queries, dbConn *sql.DB) *Service {
 
-	opts := &server.Options{
+	return NewServiceWithConfig(queries, dbConn, Config{})
 
-		Port: -1,
+}
 
-		NoLog: true,
-	}
+func NewServiceWithConfig(queries *db.Queries, dbConn *sql.DB, cfg Config) *Service {
+
+	s := &Service{
+
+		Queries: queries,
 
-	ns, err := server.NewServer(opts)
+		db: dbConn,
 
-	if err != nil {
+		StartTime: time.Now().UnixMilli(),
 
-		log.Printf("Failed to create NATS server: %v", err)
+		TimerIdleGrace: 2 * time.Minute,
 
-		return
+		PresenceTimeout: 90 * time.Second,
 
-	}
+		EditingClues: newTabState(queries, "editing_clue", nil),
 
-	go ns.Start()
+		FocusedCells: newTabState(queries, "focused_cell", nil),
 
-	if ns.ReadyForConnections(2 * time.Second) {
+		CurrentDirections: newTabState(queries, "direction", func(v string) any { return Direction(v) }),
 
-		log.Printf("NATS server ready at %s", ns.ClientURL())
+		presence: make(map[string]*presenceEntry),
 
-		s.NatsServer = ns
+		publishLocks: make(map[string]*publishLock),
 
-		nc, err := nats.Connect(ns.ClientURL())
+		instanceID: uuid.NewString(),
 
-		if err == nil {
+		stop: make(chan struct{}),
+	}
 
-			log.Printf("NATS client connected")
+	s.startNats(cfg)
 
-			s.NC = nc
+	if s.NC != nil {
 
-		} else {
+		if _, err := s.NC.Subscribe("puzzles.>", s.invalidateStates); err != nil {
 
-			log.Printf("NATS client failed to connect: %v", err)
+			log.Printf("Failed to watch puzzle events: %v", err)
 
 		}
 
-	} else {
+	}
 
-		log.Printf("NATS server failed to become ready")
+	go s.sweepLoop()
 
-	}
+	return s
 
 }
 
 func (s *Service) Shutdown() {
 
+	close(s.stop)
+
+	s.pauseSolveTimers()
+
 	if s.NC != nil {
 
 		s.NC.Close()
@@ -124,31 +159,10 @@ func (s *Service) Shutdown() {
 
 	}
 
-}
+	if s.tempStoreDir != "" {
 
-func (s *Service) BroadcastUpdate(puzzleID string, structural bool) {
-
-	if s.NC == nil {
-
-		log.Printf("Broadcast skipped: NATS connection is nil")
-
-		return
+		os.RemoveAll(s.tempStoreDir)
 
 	}
 
-	subject := fmt.Sprintf("puzzles.%s", puzzleID)
-
-	msg := "signal"
-
-	if structural {
-
-		msg = "structural"
-
-	}
-
-	log.Printf("Publishing to NATS: %s -> %s", subject, msg)
-
-	_ = s.Queries.UpdatePuzzleUpdatedAt(context.Background(), puzzleID)
-
-	_ = s.NC.Publish(subject, []byte(msg))
 }
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"share_word/internal/db"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoSession is returned when a solve request has no session to act on,
+// e.g. an anonymous visitor watching a puzzle without a room link.
+var ErrNoSession = errors.New("no solve session")
+
+// SessionKind tells personal solves apart from shared rooms.
+type SessionKind string
+
+const (
+	// SessionPersonal is a user's own solve of a puzzle. Only they can see it.
+	SessionPersonal SessionKind = "personal"
+	// SessionShared is a room anyone who can view the puzzle may join by link.
+	SessionShared SessionKind = "shared"
+)
+
+// PersonalSession returns userID's own solve of a puzzle, creating it on first use.
+func (s *Service) PersonalSession(ctx context.Context, puzzleID, userID string) (db.SolveSession, error) {
+	params := db.GetPersonalSolveSessionParams{PuzzleID: puzzleID, OwnerID: userID}
+	sess, err := s.Queries.GetPersonalSolveSession(ctx, params)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return sess, err
+	}
+
+	sess, err = s.Queries.CreateSolveSession(ctx, db.CreateSolveSessionParams{
+		ID:       uuid.New().String(),
+		PuzzleID: puzzleID,
+		OwnerID:  userID,
+		Kind:     string(SessionPersonal),
+	})
+	if err != nil {
+		// Another tab may have created it first
+		return s.Queries.GetPersonalSolveSession(ctx, params)
+	}
+	return sess, nil
+}
+
+// CreateSharedSession opens a new room on a puzzle. Starting one needs the solver role.
+func (s *Service) CreateSharedSession(ctx context.Context, puzzleID, userID string) (db.SolveSession, error) {
+	if _, err := s.AuthorizePuzzle(ctx, puzzleID, userID, RoleSolver); err != nil {
+		return db.SolveSession{}, err
+	}
+
+	return s.Queries.CreateSolveSession(ctx, db.CreateSolveSessionParams{
+		ID:       uuid.New().String(),
+		PuzzleID: puzzleID,
+		OwnerID:  userID,
+		Kind:     string(SessionShared),
+	})
+}
+
+// OpenSolveSession resolves the session a request acts on and checks that
+// userID may use it. An empty sessionID means the caller's personal session.
+// Personal sessions are only open to their owner, who needs no more than
+// RoleViewer on the puzzle; rooms need at least min.
+func (s *Service) OpenSolveSession(ctx context.Context, puzzleID, sessionID, userID string, min Role) (db.SolveSession, error) {
+	role, err := s.AuthorizePuzzle(ctx, puzzleID, userID, RoleViewer)
+	if err != nil {
+		return db.SolveSession{}, err
+	}
+
+	if sessionID == "" {
+		if userID == "" {
+			return db.SolveSession{}, ErrNoSession
+		}
+		return s.PersonalSession(ctx, puzzleID, userID)
+	}
+
+	sess, err := s.Queries.GetSolveSession(ctx, sessionID)
+	if err != nil {
+		return db.SolveSession{}, err
+	}
+	if sess.PuzzleID != puzzleID {
+		return db.SolveSession{}, sql.ErrNoRows
+	}
+	if SessionKind(sess.Kind) == SessionPersonal {
+		if sess.OwnerID != userID {
+			return db.SolveSession{}, ErrForbidden
+		}
+		return sess, nil
+	}
+	if !role.AtLeast(min) {
+		return db.SolveSession{}, ErrForbidden
+	}
+	return sess, nil
+}
+
+// SessionRole is the role userID solves sess with. In their own personal
+// session anyone who can see the puzzle solves as RoleSolver; elsewhere it
+// is their role on the puzzle.
+func SessionRole(role Role, sess db.SolveSession, userID string) Role {
+	if userID != "" && sess.OwnerID == userID && SessionKind(sess.Kind) == SessionPersonal && role.CanView() && !role.CanSolve() {
+		return RoleSolver
+	}
+	return role
+}
+
+// CellMark is what a session knows about a cell beyond its letter.
+type CellMark struct {
+	Wrong    bool // Checked and found not to match the solution
+	Revealed bool // Filled in from the solution; stays flagged for good
+	Correct  bool // Matches the solution; only shown with autocheck on
+
+	EnteredBy string // User who typed the letter; empty for guests and reveals
+
+	Heat float64 // 0 to 1, how much trouble solvers had here; heatmaps only
+}
+
+// GetSolveCells returns the puzzle's cells with Char and IsPencil filled in
+// from the session's letters. With an empty sessionID every cell is blank.
+func (s *Service) GetSolveCells(ctx context.Context, puzzleID, sessionID string) ([]db.Cell, error) {
+	cells, _, err := s.GetSolveState(ctx, puzzleID, db.SolveSession{ID: sessionID})
+	return cells, err
+}
+
+// GetSolveState is GetSolveCells plus the session's marks, keyed by "x,y".
+// With autocheck on, every filled cell is marked right or wrong.
+func (s *Service) GetSolveState(ctx context.Context, puzzleID string, sess db.SolveSession) ([]db.Cell, map[string]CellMark, error) {
+	cells, err := s.Queries.GetCells(ctx, puzzleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	letters := make(map[string]db.SessionCell)
+	marks := make(map[string]CellMark)
+	if sess.ID != "" {
+		sessionCells, err := s.Queries.GetSessionCells(ctx, sess.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range sessionCells {
+			key := fmt.Sprintf("%d,%d", c.X, c.Y)
+			letters[key] = c
+			if c.IsWrong || c.Revealed || c.EnteredBy != "" {
+				marks[key] = CellMark{Wrong: c.IsWrong, Revealed: c.Revealed, EnteredBy: c.EnteredBy}
+			}
+		}
+	}
+
+	for i := range cells {
+		c := &cells[i]
+		c.Char = ""
+		c.IsPencil = false
+		if c.IsBlock {
+			continue
+		}
+		key := fmt.Sprintf("%d,%d", c.X, c.Y)
+		c.Char = letters[key].Char
+		c.IsPencil = letters[key].IsPencil
+
+		if sess.Autocheck && c.Char != "" && c.Solution != "" {
+			m := marks[key]
+			m.Correct = strings.EqualFold(c.Char, c.Solution)
+			m.Wrong = !m.Correct
+			marks[key] = m
+		}
+	}
+	return cells, marks, nil
+}
+
+// SetSessionAutocheck turns autocheck on or off for everyone in a session.
+func (s *Service) SetSessionAutocheck(ctx context.Context, sessionID string, on bool) error {
+	return s.Queries.UpdateSolveSessionAutocheck(ctx, db.UpdateSolveSessionAutocheckParams{
+		Autocheck: on,
+		ID:        sessionID,
+	})
+}
+
+// SetSessionCell records a letter in a session, in pencil or in pen, as
+// entered by userID (empty for a guest). Writing over a pencilled letter in
+// pen inks it in. An empty char clears the cell. Revealed cells keep their
+// letter. Each change goes into the session's replay. The letter that
+// completes the grid stops the session's timer.
+func (s *Service) SetSessionCell(ctx context.Context, sessionID, userID string, x, y int64, char string, pencil bool) error {
+	if char == "" {
+		userID = ""
+	}
+	pencil = pencil && char != ""
+	changed, err := s.Queries.UpsertSessionCell(ctx, db.UpsertSessionCellParams{
+		SessionID: sessionID,
+		X:         x,
+		Y:         y,
+		Char:      char,
+		IsPencil:  pencil,
+		EnteredBy: userID,
+		EnteredAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	if changed > 0 {
+		if err := logEntry(ctx, s.Queries, sessionID, userID, x, y, char, pencil, false); err != nil {
+			return err
+		}
+	}
+	if char == "" {
+		return nil
+	}
+	return s.finishIfSolved(ctx, sessionID)
+}
+
+// MaxRebusLength caps how many characters one rebus cell holds.
+const MaxRebusLength = 10
+
+// NormalizeRebus uppercases a rebus entry and drops anything but letters and
+// digits, keeping at most MaxRebusLength characters.
+func NormalizeRebus(entry string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range strings.ToUpper(entry) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if n == MaxRebusLength {
+			break
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+// SessionSubject is the NATS subject for letter changes in one session.
+func SessionSubject(puzzleID, sessionID string) string {
+	return fmt.Sprintf("puzzles.%s.sessions.%s", puzzleID, sessionID)
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolveSessions(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	svc.SkipCooldown = true
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	friend, err := svc.RegisterUser(ctx, "friend", "password123456")
+	require.NoError(t, err)
+
+	p, err := svc.CreatePuzzle(ctx, "Sessions", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, svc.AddPuzzleMember(ctx, p.ID, owner.ID, "friend", RoleSolver))
+
+	t.Run("personal session is created once per user", func(t *testing.T) {
+		first, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		again, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, again.ID)
+		assert.Equal(t, string(SessionPersonal), first.Kind)
+
+		opened, err := svc.OpenSolveSession(ctx, p.ID, "", owner.ID, RoleSolver)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, opened.ID)
+	})
+
+	t.Run("letters are overlaid on the authored grid", func(t *testing.T) {
+		sess, _ := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 1, 0, "Q", false))
+
+		cells, err := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Q", cells[1].Char)
+
+		blank, err := svc.GetSolveCells(ctx, p.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, "", blank[1].Char)
+	})
+
+	t.Run("pencil letters are inked in by pen", func(t *testing.T) {
+		sess, _ := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 2, 0, "P", true))
+
+		cells, err := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "P", cells[2].Char)
+		assert.True(t, cells[2].IsPencil)
+
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 2, 0, "R", false))
+		cells, err = svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "R", cells[2].Char)
+		assert.False(t, cells[2].IsPencil)
+
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 2, 0, "", true))
+		cells, err = svc.GetSolveCells(ctx, p.ID, sess.ID)
+		require.NoError(t, err)
+		assert.False(t, cells[2].IsPencil, "an empty cell is never pencilled")
+	})
+
+	t.Run("access rules", func(t *testing.T) {
+		ownerSess, _ := svc.PersonalSession(ctx, p.ID, owner.ID)
+		_, err := svc.OpenSolveSession(ctx, p.ID, ownerSess.ID, friend.ID, RoleSolver)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, owner.ID, VisibilityPublic))
+		_, err = svc.OpenSolveSession(ctx, p.ID, "", "", RoleViewer)
+		assert.ErrorIs(t, err, ErrNoSession, "anonymous visitors watch without a session")
+
+		room, err := svc.CreateSharedSession(ctx, p.ID, friend.ID)
+		require.NoError(t, err)
+		opened, err := svc.OpenSolveSession(ctx, p.ID, room.ID, owner.ID, RoleSolver)
+		require.NoError(t, err)
+		assert.Equal(t, room.ID, opened.ID)
+
+		_, err = svc.CreateSharedSession(ctx, p.ID, "")
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		viewer, err := svc.RegisterUser(ctx, "viewer", "password123456")
+		require.NoError(t, err)
+		mine, err := svc.OpenSolveSession(ctx, p.ID, "", viewer.ID, RoleSolver)
+		require.NoError(t, err, "anyone who can see a puzzle may solve it alone")
+		assert.Equal(t, viewer.ID, mine.OwnerID)
+		_, err = svc.OpenSolveSession(ctx, p.ID, mine.ID, viewer.ID, RoleSolver)
+		assert.NoError(t, err)
+		assert.Equal(t, RoleSolver, SessionRole(RoleViewer, mine, viewer.ID))
+
+		_, err = svc.OpenSolveSession(ctx, p.ID, room.ID, viewer.ID, RoleSolver)
+		assert.ErrorIs(t, err, ErrForbidden, "typing in a room still takes RoleSolver")
+		_, err = svc.OpenSolveSession(ctx, p.ID, room.ID, viewer.ID, RoleViewer)
+		assert.NoError(t, err, "but viewers may watch it")
+		assert.Equal(t, RoleViewer, SessionRole(RoleViewer, room, viewer.ID))
+		assert.Equal(t, RoleViewer, SessionRole(RoleViewer, ownerSess, viewer.ID))
+	})
+
+	t.Run("resize and import drop stale letters", func(t *testing.T) {
+		sess, _ := svc.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 4, 4, "Z", false))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 0, "A", false))
+
+		require.NoError(t, svc.ResizePuzzle(ctx, p.ID, 4, 4))
+		require.NoError(t, svc.ResizePuzzle(ctx, p.ID, 5, 5))
+		cells, _ := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		for _, c := range cells {
+			if c.X == 4 && c.Y == 4 {
+				assert.Equal(t, "", c.Char)
+			}
+		}
+		assert.Equal(t, "A", cells[0].Char)
+
+		data, err := os.ReadFile("testdata/sample.ipuz")
+		require.NoError(t, err)
+		require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+		cells, _ = svc.GetSolveCells(ctx, p.ID, sess.ID)
+		assert.Equal(t, "", cells[0].Char)
+	})
+}
+
+func TestNormalizeRebus(t *testing.T) {
+	assert.Equal(t, "HEART", NormalizeRebus("heart"))
+	assert.Equal(t, "A1", NormalizeRebus(" a-1 "))
+	assert.Equal(t, "ABCDEFGHIJ", NormalizeRebus("abcdefghijklmnop"))
+	assert.Equal(t, "", NormalizeRebus("  "))
+}
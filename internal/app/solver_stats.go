@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"share_word/internal/db"
+	"sort"
+	"time"
+)
+
+// SizeStats is a solver's times on grids of one size.
+type SizeStats struct {
+	Width, Height int
+	Solves        int
+	Average, Best time.Duration
+}
+
+// SolverStats sums up the puzzles a user has finished, on their own or in a
+// room they typed in. Each puzzle counts once, as first finished.
+type SolverStats struct {
+	Completed int
+	BySize    []SizeStats // Smallest grids first
+
+	// Streaks count days in a row, in UTC, with at least one finished solve.
+	// The current streak survives until a whole day goes by without one.
+	CurrentStreak int
+	LongestStreak int
+
+	Checked  int // Solves that checked any square
+	Revealed int // Solves that revealed any square
+}
+
+// GetSolverStats works out a user's solving stats. Like GetCompletedSolves,
+// solves of non-public puzzles only count when the solver is looking.
+func (s *Service) GetSolverStats(ctx context.Context, userID, viewerID string) (SolverStats, error) {
+	history, err := s.Queries.GetSolveHistory(ctx, db.GetSolveHistoryParams{
+		UserID:   userID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return SolverStats{}, err
+	}
+	return solverStats(history, time.Now()), nil
+}
+
+// solverStats is GetSolverStats on a loaded history, oldest solve first.
+// Solving a puzzle again, say in a room after solving it alone, doesn't
+// count.
+func solverStats(history []db.GetSolveHistoryRow, now time.Time) SolverStats {
+	var st SolverStats
+	type size struct{ w, h int64 }
+	sizes := make(map[size]*SizeStats)
+	total := make(map[size]time.Duration)
+
+	solved := make(map[string]bool)
+	var days []int64
+	for _, h := range history {
+		if solved[h.PuzzleID] {
+			continue
+		}
+		solved[h.PuzzleID] = true
+		st.Completed++
+		if h.Checked != 0 {
+			st.Checked++
+		}
+		if h.Revealed != 0 {
+			st.Revealed++
+		}
+
+		elapsed := time.Duration(h.ElapsedMs) * time.Millisecond
+		key := size{h.Width, h.Height}
+		ss := sizes[key]
+		if ss == nil {
+			ss = &SizeStats{Width: int(h.Width), Height: int(h.Height), Best: elapsed}
+			sizes[key] = ss
+		}
+		ss.Solves++
+		ss.Best = min(ss.Best, elapsed)
+		total[key] += elapsed
+
+		if h.CompletedAt.Valid {
+			if day := dayNumber(h.CompletedAt.Time); len(days) == 0 || days[len(days)-1] != day {
+				days = append(days, day)
+			}
+		}
+	}
+
+	for key, ss := range sizes {
+		ss.Average = total[key] / time.Duration(ss.Solves)
+		st.BySize = append(st.BySize, *ss)
+	}
+	sort.Slice(st.BySize, func(i, j int) bool {
+		a, b := st.BySize[i], st.BySize[j]
+		if a.Width*a.Height != b.Width*b.Height {
+			return a.Width*a.Height < b.Width*b.Height
+		}
+		return a.Width < b.Width
+	})
+
+	run := 0
+	for i, day := range days {
+		if i > 0 && days[i-1] == day-1 {
+			run++
+		} else {
+			run = 1
+		}
+		st.LongestStreak = max(st.LongestStreak, run)
+	}
+	if len(days) > 0 && days[len(days)-1] >= dayNumber(now)-1 {
+		st.CurrentStreak = run
+	}
+	return st
+}
+
+// dayNumber counts UTC days since the Unix epoch.
+func dayNumber(t time.Time) int64 {
+	return t.UTC().Unix() / int64(24*time.Hour/time.Second)
+}
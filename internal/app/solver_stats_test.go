@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"share_word/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolverStats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	puzzles := 0
+	solve := func(daysAgo int, w, h int64, secs int64, checked, revealed bool) db.GetSolveHistoryRow {
+		puzzles++
+		row := db.GetSolveHistoryRow{
+			PuzzleID:    fmt.Sprintf("puzzle-%d", puzzles),
+			ElapsedMs:   secs * 1000,
+			CompletedAt: sql.NullTime{Time: now.AddDate(0, 0, -daysAgo), Valid: true},
+			Width:       w,
+			Height:      h,
+		}
+		if checked {
+			row.Checked = 1
+		}
+		if revealed {
+			row.Revealed = 1
+		}
+		return row
+	}
+
+	t.Run("times by size, help used and streaks", func(t *testing.T) {
+		st := solverStats([]db.GetSolveHistoryRow{
+			solve(10, 15, 15, 900, true, false),
+			solve(9, 15, 15, 600, false, false),
+			solve(8, 5, 5, 60, false, true),
+			solve(3, 15, 15, 300, true, true),
+			solve(2, 5, 5, 40, false, false),
+			solve(2, 5, 5, 50, false, false),
+			solve(1, 15, 15, 1200, false, false),
+		}, now)
+
+		assert.Equal(t, 7, st.Completed)
+		assert.Equal(t, 2, st.Checked)
+		assert.Equal(t, 2, st.Revealed)
+		assert.Equal(t, []SizeStats{
+			{Width: 5, Height: 5, Solves: 3, Average: 50 * time.Second, Best: 40 * time.Second},
+			{Width: 15, Height: 15, Solves: 4, Average: 750 * time.Second, Best: 300 * time.Second},
+		}, st.BySize)
+		assert.Equal(t, 3, st.LongestStreak, "two solves on one day count once")
+		assert.Equal(t, 3, st.CurrentStreak, "yesterday's solve keeps the streak going today")
+	})
+
+	t.Run("a missed day ends the current streak", func(t *testing.T) {
+		st := solverStats([]db.GetSolveHistoryRow{
+			solve(4, 5, 5, 30, false, false),
+			solve(3, 5, 5, 30, false, false),
+			solve(2, 5, 5, 30, false, false),
+		}, now)
+		assert.Equal(t, 3, st.LongestStreak)
+		assert.Zero(t, st.CurrentStreak)
+	})
+
+	t.Run("a puzzle solved again counts once", func(t *testing.T) {
+		first := solve(3, 5, 5, 30, false, false)
+		again := solve(2, 5, 5, 10, false, false)
+		again.PuzzleID = first.PuzzleID
+		st := solverStats([]db.GetSolveHistoryRow{first, again}, now)
+		assert.Equal(t, 1, st.Completed)
+		assert.Equal(t, []SizeStats{{Width: 5, Height: 5, Solves: 1, Average: 30 * time.Second, Best: 30 * time.Second}}, st.BySize)
+		assert.Equal(t, 1, st.LongestStreak, "solving it again the next day doesn't make a streak")
+	})
+
+	t.Run("no solves", func(t *testing.T) {
+		assert.Equal(t, SolverStats{}, solverStats(nil, now))
+	})
+}
+
+func TestGetSolverStats(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	solver, err := svc.RegisterUser(ctx, "solver", "password123456")
+	require.NoError(t, err)
+	other, err := svc.RegisterUser(ctx, "other", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Draft", solver.ID, 5, 5)
+	require.NoError(t, err)
+	sess, err := svc.PersonalSession(ctx, p.ID, solver.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.SetSessionCell(ctx, sess.ID, solver.ID, 0, 0, "A", false))
+	require.NoError(t, svc.Queries.CompleteSolveSession(ctx, db.CompleteSolveSessionParams{
+		ElapsedMs:   95_000,
+		CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:          sess.ID,
+	}))
+
+	st, err := svc.GetSolverStats(ctx, solver.ID, solver.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.Completed)
+	assert.Equal(t, 1, st.CurrentStreak)
+	assert.Equal(t, []SizeStats{{Width: 5, Height: 5, Solves: 1, Average: 95 * time.Second, Best: 95 * time.Second}}, st.BySize)
+
+	st, err = svc.GetSolverStats(ctx, solver.ID, other.ID)
+	require.NoError(t, err)
+	assert.Zero(t, st.Completed, "solves of private puzzles only count for the solver")
+
+	// Finishing the puzzle again in a room credits whoever typed in it, and
+	// doesn't count twice for the solver
+	require.NoError(t, svc.SetPuzzleVisibility(ctx, p.ID, solver.ID, "public"))
+	require.NoError(t, svc.AddPuzzleMember(ctx, p.ID, solver.ID, "other", "solver"))
+	room, err := svc.CreateSharedSession(ctx, p.ID, solver.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.SetSessionCell(ctx, room.ID, other.ID, 0, 0, "A", false))
+	require.NoError(t, svc.Queries.CompleteSolveSession(ctx, db.CompleteSolveSessionParams{
+		ElapsedMs:   60_000,
+		CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:          room.ID,
+	}))
+
+	st, err = svc.GetSolverStats(ctx, other.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.Completed, "room members get credit")
+	assert.Equal(t, 1, st.CurrentStreak)
+
+	st, err = svc.GetSolverStats(ctx, solver.ID, solver.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.Completed)
+	assert.Equal(t, 95*time.Second, st.BySize[0].Best, "the first solve is the one that counts")
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"share_word/internal/db"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// stateIdle is how long a cached puzzle is kept after its last use.
+const stateIdle = 10 * time.Minute
+
+// PuzzleState is a grid as everyone looking at it sees it: the puzzle, the
+// session's letters and marks, the numbering and the clues. It is shared
+// between streams, so it must not be modified.
+type PuzzleState struct {
+	Revision  int64
+	Puzzle    db.GetPuzzleRow
+	Session   db.SolveSession
+	Cells     []db.Cell
+	Marks     map[string]CellMark
+	Annotated []AnnotatedCell
+	Clues     []Clue
+}
+
+// stateKey identifies a grid: a puzzle in edit mode or without a session has
+// an empty sessionID.
+type stateKey struct {
+	puzzleID  string
+	sessionID string
+}
+
+// stateEntry is one cached grid. mu is held while it loads, so streams that
+// ask at the same time share one load.
+type stateEntry struct {
+	mu       sync.Mutex
+	state    *PuzzleState
+	lastUsed time.Time
+}
+
+// stateCache holds the grids streams are showing.
+type stateCache struct {
+	mu      sync.Mutex
+	entries map[stateKey]*stateEntry
+}
+
+func (c *stateCache) entry(key stateKey) *stateEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[stateKey]*stateEntry)
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &stateEntry{}
+		c.entries[key] = e
+	}
+	e.lastUsed = time.Now()
+	return e
+}
+
+// drop forgets a puzzle's grids: one session's, or all of them when
+// sessionID is empty.
+func (c *stateCache) drop(puzzleID, sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.puzzleID == puzzleID && (sessionID == "" || key.sessionID == sessionID) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// dropSession forgets the grids showing a session, wherever its puzzle. Its
+// clock starts and stops without an event to invalidate them.
+func (c *stateCache) dropSession(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.sessionID == sessionID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// expire forgets grids nobody has asked for in a while.
+func (c *stateCache) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if now.Sub(e.lastUsed) > stateIdle {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// PuzzleState returns a grid as of at least the given revision, loading it
+// only if the cached copy is older. Streams pass the revision of the event
+// that woke them; a negative revision means the puzzle's current one.
+func (s *Service) PuzzleState(ctx context.Context, puzzleID, sessionID string, revision int64) (*PuzzleState, error) {
+	if revision < 0 {
+		var err error
+		if revision, err = s.puzzleRevision(ctx, puzzleID); err != nil {
+			return nil, err
+		}
+	}
+
+	e := s.states.entry(stateKey{puzzleID, sessionID})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != nil && e.state.Revision >= revision {
+		return e.state, nil
+	}
+
+	// Read before loading, so the grid is at least as new as the revision
+	// it is cached under
+	current, err := s.puzzleRevision(ctx, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	state := &PuzzleState{Revision: current}
+	if state.Puzzle, err = s.Queries.GetPuzzle(ctx, puzzleID); err != nil {
+		return nil, err
+	}
+	if sessionID != "" {
+		if state.Session, err = s.Queries.GetSolveSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	}
+	if state.Cells, state.Marks, err = s.GetSolveState(ctx, puzzleID, state.Session); err != nil {
+		return nil, err
+	}
+	state.Annotated = s.CalculateNumbers(int(state.Puzzle.Width), int(state.Puzzle.Height), state.Cells)
+	if state.Clues, err = s.GetFullClues(ctx, puzzleID, state.Cells); err != nil {
+		return nil, err
+	}
+	e.state = state
+	return state, nil
+}
+
+// puzzleRevision is the puzzle's latest revision, 0 before its first change.
+func (s *Service) puzzleRevision(ctx context.Context, puzzleID string) (int64, error) {
+	rev, err := s.Queries.GetPuzzleRevision(ctx, puzzleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return rev, err
+}
+
+// invalidateStates drops cached grids as content changes go out on the bus.
+// Readers still check revisions, since a stream can hear of a change before
+// this does.
+func (s *Service) invalidateStates(msg *nats.Msg) {
+	e, err := ParseEvent(msg.Data)
+	if err != nil {
+		log.Printf("State cache: unreadable event on %s: %v", msg.Subject, err)
+		return
+	}
+	if e.Type.changesContent() {
+		s.states.drop(e.PuzzleID, e.SessionID)
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuzzleStateCache(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Cached", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := svc.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	cached := func(sessionID string) bool {
+		svc.states.mu.Lock()
+		defer svc.states.mu.Unlock()
+		_, ok := svc.states.entries[stateKey{p.ID, sessionID}]
+		return ok
+	}
+
+	t.Run("spectators share one load", func(t *testing.T) {
+		// At a known revision, as streams woken by an event ask
+		rev, err := svc.puzzleRevision(ctx, p.ID)
+		require.NoError(t, err)
+		states := make([]*PuzzleState, 30)
+		var wg sync.WaitGroup
+		for i := range states {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				states[i], _ = svc.PuzzleState(ctx, p.ID, room.ID, rev)
+			}()
+		}
+		wg.Wait()
+		require.NotNil(t, states[0])
+		for _, st := range states {
+			assert.Same(t, states[0], st)
+		}
+		assert.Len(t, states[0].Annotated, 25)
+		assert.Len(t, states[0].Clues, 10)
+	})
+
+	t.Run("a change is loaded once for its revision", func(t *testing.T) {
+		before, err := svc.PuzzleState(ctx, p.ID, room.ID, -1)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.SetSessionCell(ctx, room.ID, owner.ID, 0, 0, "A", false))
+		svc.PublishLetters(ctx, Event{Type: EventLettersChanged, PuzzleID: p.ID, SessionID: room.ID}, []Point{{X: 0, Y: 0}})
+		rev, err := svc.puzzleRevision(ctx, p.ID)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return !cached(room.ID) }, time.Second, 5*time.Millisecond)
+
+		after, err := svc.PuzzleState(ctx, p.ID, room.ID, rev)
+		require.NoError(t, err)
+		assert.NotSame(t, before, after)
+		assert.Equal(t, rev, after.Revision)
+		assert.Equal(t, "A", after.Cells[0].Char)
+		assert.Equal(t, "", before.Cells[0].Char, "earlier states are left alone")
+
+		again, err := svc.PuzzleState(ctx, p.ID, room.ID, rev)
+		require.NoError(t, err)
+		assert.Same(t, after, again)
+	})
+
+	t.Run("changes on the bus drop cached grids", func(t *testing.T) {
+		_, err := svc.PuzzleState(ctx, p.ID, "", -1)
+		require.NoError(t, err)
+		require.True(t, cached(""))
+
+		svc.Publish(Event{Type: EventCursorMoved, PuzzleID: p.ID})
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, cached(""), "cursors don't change the grid")
+
+		svc.PublishCellsChanged(ctx, p.ID, owner.ID, []Point{{X: 1, Y: 1}})
+		assert.Eventually(t, func() bool { return !cached("") }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("the solve clock shows without an event", func(t *testing.T) {
+		before, err := svc.PuzzleState(ctx, p.ID, room.ID, -1)
+		require.NoError(t, err)
+		require.False(t, before.Session.RunningSince.Valid)
+
+		require.NoError(t, svc.SessionStreamOpened(ctx, room.ID))
+		defer svc.SessionStreamClosed(room.ID)
+		after, err := svc.PuzzleState(ctx, p.ID, room.ID, -1)
+		require.NoError(t, err)
+		assert.True(t, after.Session.RunningSince.Valid)
+	})
+
+	t.Run("idle grids expire", func(t *testing.T) {
+		_, err := svc.PuzzleState(ctx, p.ID, "", -1)
+		require.NoError(t, err)
+		svc.states.expire(time.Now())
+		assert.True(t, cached(""))
+		svc.states.expire(time.Now().Add(stateIdle + time.Second))
+		assert.False(t, cached(""))
+	})
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"share_word/internal/db"
+	"time"
+)
+
+// TabState is one piece of per-tab state, such as where a tab's cursor is,
+// keyed by SessionToken:ClientID. It lives in the database so that whichever
+// instance serves a tab's next request sees what the last one stored.
+type TabState struct {
+	queries *db.Queries
+	field   string
+	// Turns a stored value back into the type it was stored as
+	decode func(string) any
+}
+
+func newTabState(queries *db.Queries, field string, decode func(string) any) TabState {
+	if decode == nil {
+		decode = func(v string) any { return v }
+	}
+	return TabState{queries: queries, field: field, decode: decode}
+}
+
+// Load returns the value stored for a tab, if any.
+func (t *TabState) Load(key string) (any, bool) {
+	v, err := t.queries.GetTabState(context.Background(), db.GetTabStateParams{TabKey: key, Field: t.field})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to load %s for %s: %v", t.field, key, err)
+		}
+		return nil, false
+	}
+	return t.decode(v), true
+}
+
+// Store sets a tab's value.
+func (t *TabState) Store(key string, value any) {
+	err := t.queries.SetTabState(context.Background(), db.SetTabStateParams{
+		TabKey:    key,
+		Field:     t.field,
+		Value:     fmt.Sprint(value),
+		UpdatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Failed to store %s for %s: %v", t.field, key, err)
+	}
+}
+
+// Delete drops a tab's value.
+func (t *TabState) Delete(key string) {
+	if err := t.queries.DeleteTabState(context.Background(), db.DeleteTabStateParams{TabKey: key, Field: t.field}); err != nil {
+		log.Printf("Failed to delete %s for %s: %v", t.field, key, err)
+	}
+}
+
+// Range calls f for each tab with a value, stopping if f returns false.
+func (t *TabState) Range(f func(key string, value any) bool) {
+	rows, err := t.queries.ListTabState(context.Background(), t.field)
+	if err != nil {
+		log.Printf("Failed to list %s: %v", t.field, err)
+		return
+	}
+	for _, r := range rows {
+		if !f(r.TabKey, t.decode(r.Value)) {
+			return
+		}
+	}
+}
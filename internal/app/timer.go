@@ -0,0 +1,294 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"share_word/internal/db"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SolveElapsed is how long a session has been solved for as of now,
+// including the current stretch if its timer is running.
+func SolveElapsed(sess db.SolveSession, now time.Time) time.Duration {
+	d := time.Duration(sess.ElapsedMs) * time.Millisecond
+	if sess.RunningSince.Valid && now.After(sess.RunningSince.Time) {
+		d += now.Sub(sess.RunningSince.Time)
+	}
+	return d
+}
+
+// FormatSolveTime renders a solve time as m:ss, or h:mm:ss past the hour.
+func FormatSolveTime(d time.Duration) string {
+	t := int64(d / time.Second)
+	h, m, sec := t/3600, t/60%60, t%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
+// IsSolved reports whether every non-block cell matches its solution. A grid
+// with any cell missing a solution can never be solved.
+func IsSolved(cells []db.Cell) bool {
+	open := 0
+	for _, c := range cells {
+		if c.IsBlock {
+			continue
+		}
+		if c.Solution == "" || !strings.EqualFold(c.Char, c.Solution) {
+			return false
+		}
+		open++
+	}
+	return open > 0
+}
+
+// solveStreams counts the streams open on one session. mu also serializes
+// the session's timer writes.
+type solveStreams struct {
+	mu    sync.Mutex
+	open  int
+	pause *time.Timer
+	gone  bool // Dropped from Service.solveStreams; look it up again
+}
+
+// lockStreams finds a session's stream count and locks it.
+func (s *Service) lockStreams(sessionID string) *solveStreams {
+	for {
+		v, _ := s.solveStreams.LoadOrStore(sessionID, &solveStreams{})
+		st := v.(*solveStreams)
+		st.mu.Lock()
+		if !st.gone {
+			return st
+		}
+		st.mu.Unlock()
+	}
+}
+
+// unlockStreams unlocks a session's stream count, dropping it once nothing
+// is open or waiting to pause so the map doesn't keep every session ever
+// solved.
+func (s *Service) unlockStreams(sessionID string, st *solveStreams) {
+	if st.open == 0 && st.pause == nil {
+		st.gone = true
+		s.solveStreams.Delete(sessionID)
+	}
+	st.mu.Unlock()
+}
+
+// SessionStreamOpened starts or resumes a session's timer when someone opens
+// it. Finished sessions stay stopped.
+func (s *Service) SessionStreamOpened(ctx context.Context, sessionID string) error {
+	st := s.lockStreams(sessionID)
+	defer s.unlockStreams(sessionID, st)
+
+	st.open++
+	if st.pause != nil {
+		st.pause.Stop()
+		st.pause = nil
+	}
+	return s.startTimer(ctx, sessionID, time.Now())
+}
+
+// SessionStreamClosed pauses a session's timer once it has gone
+// TimerIdleGrace without any open streams, so a reload or a dropped
+// connection doesn't stop the clock.
+func (s *Service) SessionStreamClosed(sessionID string) {
+	st := s.lockStreams(sessionID)
+	defer s.unlockStreams(sessionID, st)
+
+	st.open--
+	if st.open > 0 || st.pause != nil {
+		return
+	}
+
+	var pause *time.Timer
+	pause = time.AfterFunc(s.TimerIdleGrace, func() {
+		st.mu.Lock()
+		if st.pause != pause {
+			// Stopped too late; a stream opened or the server shut down
+			st.mu.Unlock()
+			return
+		}
+		st.pause = nil
+		if err := s.releaseTimer(context.Background(), sessionID); err != nil {
+			log.Printf("Failed to pause timer for session %s: %v", sessionID, err)
+		}
+		s.unlockStreams(sessionID, st)
+	})
+	st.pause = pause
+}
+
+// startTimer records that this instance has a session open and starts its
+// timer if it is stopped. The caller holds the session's lock.
+func (s *Service) startTimer(ctx context.Context, sessionID string, now time.Time) error {
+	err := s.Queries.TouchSessionStream(ctx, db.TouchSessionStreamParams{
+		SessionID:  sessionID,
+		InstanceID: s.instanceID,
+		SeenAt:     now.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	started, err := s.Queries.StartSolveSessionTimer(ctx, db.StartSolveSessionTimerParams{
+		RunningSince: sql.NullTime{Time: now, Valid: true},
+		ID:           sessionID,
+	})
+	if started > 0 {
+		s.states.dropSession(sessionID)
+	}
+	return err
+}
+
+// releaseTimer records that this instance no longer has a session open, and
+// pauses its timer unless another instance still does. The caller holds the
+// session's lock.
+func (s *Service) releaseTimer(ctx context.Context, sessionID string) error {
+	err := s.Queries.DeleteSessionStream(ctx, db.DeleteSessionStreamParams{
+		SessionID:  sessionID,
+		InstanceID: s.instanceID,
+	})
+	if err != nil {
+		return err
+	}
+	others, err := s.Queries.CountOtherSessionStreams(ctx, db.CountOtherSessionStreamsParams{
+		SessionID:  sessionID,
+		InstanceID: s.instanceID,
+		SeenSince:  time.Now().Add(-s.PresenceTimeout).UnixMilli(),
+	})
+	if err != nil || others > 0 {
+		return err
+	}
+	return s.pauseTimer(ctx, sessionID)
+}
+
+// sweepSolveStreams runs with each presence sweep. It tells the other
+// instances which sessions this one still has open, resuming any timer one
+// of them paused as a stream opened here, and pauses the timers of sessions
+// whose instances stopped saying so, e.g. because they crashed.
+func (s *Service) sweepSolveStreams(now time.Time) {
+	ctx := context.Background()
+	s.solveStreams.Range(func(key, value any) bool {
+		st := value.(*solveStreams)
+		st.mu.Lock()
+		if !st.gone && st.open > 0 {
+			if err := s.startTimer(ctx, key.(string), now); err != nil {
+				log.Printf("Failed to keep timer running for session %s: %v", key, err)
+			}
+		}
+		st.mu.Unlock()
+		return true
+	})
+
+	stale, err := s.Queries.DeleteStaleSessionStreams(ctx, now.Add(-s.PresenceTimeout).UnixMilli())
+	if err != nil {
+		log.Printf("Failed to sweep solve streams: %v", err)
+		return
+	}
+	for _, sessionID := range stale {
+		st := s.lockStreams(sessionID)
+		if st.open == 0 && st.pause == nil {
+			if err := s.releaseTimer(ctx, sessionID); err != nil {
+				log.Printf("Failed to pause timer for session %s: %v", sessionID, err)
+			}
+		}
+		s.unlockStreams(sessionID, st)
+	}
+}
+
+// pauseSolveTimers lets go of every session this instance has open, e.g. on
+// shutdown, pausing the timers no other instance is keeping running. The
+// next stream to open a session resumes it.
+func (s *Service) pauseSolveTimers() {
+	s.solveStreams.Range(func(key, value any) bool {
+		st := value.(*solveStreams)
+		st.mu.Lock()
+		if st.gone {
+			st.mu.Unlock()
+			return true
+		}
+		if st.pause != nil {
+			st.pause.Stop()
+			st.pause = nil
+		}
+		if err := s.releaseTimer(context.Background(), key.(string)); err != nil {
+			log.Printf("Failed to pause timer for session %s: %v", key, err)
+		}
+		s.unlockStreams(key.(string), st)
+		return true
+	})
+}
+
+// pauseTimer banks the running stretch of a session's timer. The caller
+// holds the session's lock.
+func (s *Service) pauseTimer(ctx context.Context, sessionID string) error {
+	sess, err := s.Queries.GetSolveSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if !sess.RunningSince.Valid {
+		return nil
+	}
+	defer s.states.dropSession(sessionID)
+	return s.Queries.PauseSolveSessionTimer(ctx, db.PauseSolveSessionTimerParams{
+		ElapsedMs: SolveElapsed(sess, time.Now()).Milliseconds(),
+		ID:        sessionID,
+	})
+}
+
+// finishIfSolved stops a session's timer for good once its grid matches the
+// solution and tells the session's streams. Already finished sessions keep
+// their original time.
+func (s *Service) finishIfSolved(ctx context.Context, sessionID string) error {
+	st := s.lockStreams(sessionID)
+	defer s.unlockStreams(sessionID, st)
+
+	sess, err := s.Queries.GetSolveSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess.CompletedAt.Valid {
+		return nil
+	}
+
+	cells, err := s.GetSolveCells(ctx, sess.PuzzleID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !IsSolved(cells) {
+		return nil
+	}
+
+	now := time.Now()
+	err = s.Queries.CompleteSolveSession(ctx, db.CompleteSolveSessionParams{
+		ElapsedMs:   SolveElapsed(sess, now).Milliseconds(),
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+		ID:          sessionID,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Publish(Event{
+		Type:      EventCompleted,
+		PuzzleID:  sess.PuzzleID,
+		SessionID: sessionID,
+		ElapsedMs: SolveElapsed(sess, now).Milliseconds(),
+	})
+	return nil
+}
+
+// GetCompletedSolves lists the solves a user has finished, newest first.
+// Solves of non-public puzzles are only listed for the solver themselves.
+func (s *Service) GetCompletedSolves(ctx context.Context, userID, viewerID string, limit, offset int64) ([]db.GetCompletedSolvesByOwnerRow, error) {
+	return s.Queries.GetCompletedSolvesByOwner(ctx, db.GetCompletedSolvesByOwnerParams{
+		OwnerID:  userID,
+		ViewerID: viewerID,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
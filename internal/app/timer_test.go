@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"os"
+	"share_word/internal/db"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSolveTime(t *testing.T) {
+	assert.Equal(t, "0:00", FormatSolveTime(0))
+	assert.Equal(t, "0:09", FormatSolveTime(9500*time.Millisecond))
+	assert.Equal(t, "4:32", FormatSolveTime(4*time.Minute+32*time.Second))
+	assert.Equal(t, "1:02:03", FormatSolveTime(time.Hour+2*time.Minute+3*time.Second))
+}
+
+func TestSolveTimer(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+	svc.TimerIdleGrace = 20 * time.Millisecond
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Timed", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+
+	sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	load := func() db.SolveSession {
+		got, err := svc.Queries.GetSolveSession(ctx, sess.ID)
+		require.NoError(t, err)
+		return got
+	}
+
+	t.Run("runs while a stream is open", func(t *testing.T) {
+		assert.False(t, load().RunningSince.Valid)
+		require.NoError(t, svc.SessionStreamOpened(ctx, sess.ID))
+		assert.True(t, load().RunningSince.Valid)
+	})
+
+	t.Run("survives a quick reconnect", func(t *testing.T) {
+		svc.SessionStreamClosed(sess.ID)
+		require.NoError(t, svc.SessionStreamOpened(ctx, sess.ID))
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, load().RunningSince.Valid)
+	})
+
+	t.Run("pauses once nobody is connected", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		svc.SessionStreamClosed(sess.ID)
+		require.Eventually(t, func() bool { return !load().RunningSince.Valid }, time.Second, 5*time.Millisecond)
+		assert.Greater(t, load().ElapsedMs, int64(0))
+
+		require.Eventually(t, func() bool {
+			_, ok := svc.solveStreams.Load(sess.ID)
+			return !ok
+		}, time.Second, 5*time.Millisecond, "idle sessions don't stay in memory")
+	})
+
+	t.Run("keeps running while another instance has it open", func(t *testing.T) {
+		other := NewService(svc.Queries, svc.db)
+		defer other.Shutdown()
+		other.TimerIdleGrace = svc.TimerIdleGrace
+
+		require.NoError(t, other.SessionStreamOpened(ctx, sess.ID))
+		require.NoError(t, svc.SessionStreamOpened(ctx, sess.ID))
+		svc.SessionStreamClosed(sess.ID)
+		require.Eventually(t, func() bool {
+			_, ok := svc.solveStreams.Load(sess.ID)
+			return !ok
+		}, time.Second, 5*time.Millisecond)
+		assert.True(t, load().RunningSince.Valid, "the other instance still shows it")
+
+		// One instance pausing as a stream opens elsewhere is caught up on
+		require.NoError(t, svc.pauseTimer(ctx, sess.ID))
+		other.sweepSolveStreams(time.Now())
+		assert.True(t, load().RunningSince.Valid)
+
+		other.SessionStreamClosed(sess.ID)
+		require.Eventually(t, func() bool { return !load().RunningSince.Valid }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("pauses once the instance showing it goes quiet", func(t *testing.T) {
+		other := NewService(svc.Queries, svc.db)
+		require.NoError(t, other.SessionStreamOpened(ctx, sess.ID))
+		defer func() {
+			// It crashed, so it never lets go
+			other.solveStreams.Range(func(key, _ any) bool {
+				other.solveStreams.Delete(key)
+				return true
+			})
+			other.Shutdown()
+		}()
+
+		svc.sweepSolveStreams(time.Now())
+		assert.True(t, load().RunningSince.Valid)
+		svc.sweepSolveStreams(time.Now().Add(svc.PresenceTimeout + time.Second))
+		assert.False(t, load().RunningSince.Valid)
+	})
+
+	t.Run("stops when the grid matches the solution", func(t *testing.T) {
+		events := make(chan *nats.Msg, 4)
+		sub, err := svc.NC.ChanSubscribe(SessionSubject(p.ID, sess.ID), events)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+
+		require.NoError(t, svc.SessionStreamOpened(ctx, sess.ID))
+		defer svc.SessionStreamClosed(sess.ID)
+
+		rows := []string{"ABCDE", "F#G#H", "IJKLM", "N#O#P", "QRSTU"}
+		for y, row := range rows {
+			for x, ch := range row {
+				if ch == '#' || (x == 4 && y == 4) {
+					continue
+				}
+				require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", int64(x), int64(y), string(ch), false))
+			}
+		}
+		assert.False(t, load().CompletedAt.Valid)
+
+		// A wrong last letter doesn't count
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 4, 4, "X", false))
+		assert.False(t, load().CompletedAt.Valid)
+
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 4, 4, "u", false))
+		final := load()
+		assert.True(t, final.CompletedAt.Valid)
+		assert.False(t, final.RunningSince.Valid)
+
+		select {
+		case msg := <-events:
+			event, err := ParseEvent(msg.Data)
+			require.NoError(t, err)
+			assert.Equal(t, EventCompleted, event.Type)
+			assert.Equal(t, final.ElapsedMs, event.ElapsedMs)
+		case <-time.After(time.Second):
+			t.Fatal("no completion event")
+		}
+
+		// Reconnecting or editing afterwards leaves the result alone
+		require.NoError(t, svc.SessionStreamOpened(ctx, sess.ID))
+		svc.SessionStreamClosed(sess.ID)
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 4, 4, "U", false))
+		after := load()
+		assert.Equal(t, final.ElapsedMs, after.ElapsedMs)
+		assert.True(t, final.CompletedAt.Time.Equal(after.CompletedAt.Time))
+
+		solves, err := svc.GetCompletedSolves(ctx, owner.ID, owner.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, solves, 1)
+		assert.Equal(t, "Timed", solves[0].PuzzleName)
+
+		solves, err = svc.GetCompletedSolves(ctx, owner.ID, "", 10, 0)
+		require.NoError(t, err)
+		assert.Empty(t, solves, "private puzzles stay off other people's view of the profile")
+	})
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"share_word/internal/db"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// CurrentVersion stands for the puzzle as it is now when comparing versions.
+const CurrentVersion = "current"
+
+// MaxVersionName is the longest name a saved version may have.
+const MaxVersionName = 80
+
+// CellChange is a square that differs between two versions. Each side is
+// the square's answer, "#" for a block, or "" for an empty square, and its
+// style as squareStyle describes it.
+type CellChange struct {
+	X, Y               int64
+	From, To           string
+	FromStyle, ToStyle string
+}
+
+// ClueChange is a clue whose text differs between two versions.
+type ClueChange struct {
+	Number    int64
+	Direction Direction
+	From, To  string
+}
+
+// VersionDiff is what changed going from one version of a puzzle to another.
+type VersionDiff struct {
+	FromLabel, ToLabel    string
+	FromName, ToName      string
+	FromWidth, FromHeight int64
+	ToWidth, ToHeight     int64
+	Cells                 []CellChange
+	Clues                 []ClueChange
+}
+
+// Resized reports whether the two versions have different dimensions.
+func (d VersionDiff) Resized() bool {
+	return d.FromWidth != d.ToWidth || d.FromHeight != d.ToHeight
+}
+
+// Empty reports whether the two versions are the same.
+func (d VersionDiff) Empty() bool {
+	return !d.Resized() && d.FromName == d.ToName && len(d.Cells) == 0 && len(d.Clues) == 0
+}
+
+// SaveVersion stores the puzzle as it is now under a name.
+func (s *Service) SaveVersion(ctx context.Context, puzzleID, userID, name string) (db.PuzzleVersion, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.PuzzleVersion{}, errors.New("version name is required")
+	}
+	if utf8.RuneCountInString(name) > MaxVersionName {
+		return db.PuzzleVersion{}, fmt.Errorf("version name must be at most %d characters", MaxVersionName)
+	}
+
+	st, err := snapshotGrid(ctx, s.Queries, puzzleID)
+	if err != nil {
+		return db.PuzzleVersion{}, err
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return db.PuzzleVersion{}, err
+	}
+
+	return s.Queries.CreatePuzzleVersion(ctx, db.CreatePuzzleVersionParams{
+		ID:        uuid.New().String(),
+		PuzzleID:  puzzleID,
+		Name:      name,
+		CreatedBy: userID,
+		State:     string(data),
+	})
+}
+
+// GetVersions lists a puzzle's saved versions, newest first.
+func (s *Service) GetVersions(ctx context.Context, puzzleID string) ([]db.GetPuzzleVersionsRow, error) {
+	return s.Queries.GetPuzzleVersions(ctx, puzzleID)
+}
+
+// loadVersion reads a saved version of a puzzle, or the puzzle as it is now
+// for CurrentVersion. It returns the version's label along with its state.
+func loadVersion(ctx context.Context, q *db.Queries, puzzleID, versionID string) (string, gridState, error) {
+	if versionID == CurrentVersion {
+		st, err := snapshotGrid(ctx, q, puzzleID)
+		return "Current", st, err
+	}
+
+	v, err := q.GetPuzzleVersion(ctx, db.GetPuzzleVersionParams{ID: versionID, PuzzleID: puzzleID})
+	if err != nil {
+		return "", gridState{}, err
+	}
+	var st gridState
+	if err := json.Unmarshal([]byte(v.State), &st); err != nil {
+		return "", gridState{}, err
+	}
+	return v.Name, st, nil
+}
+
+// RestoreVersion replaces a puzzle's grid, clues and name with a saved
+// version in one transaction. As with an import, letters solvers typed in
+// squares whose block or answer changed are cleared, except in finished
+// solves. The restore can be undone, but cleared letters stay cleared.
+func (s *Service) RestoreVersion(ctx context.Context, puzzleID, versionID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.Queries.WithTx(tx)
+
+	_, st, err := loadVersion(ctx, qtx, puzzleID, versionID)
+	if err != nil {
+		return err
+	}
+	before, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
+
+	if err := applyGridState(ctx, qtx, puzzleID, st); err != nil {
+		return err
+	}
+
+	after, err := snapshotGrid(ctx, qtx, puzzleID)
+	if err != nil {
+		return err
+	}
+	if err := clearChangedLetters(ctx, qtx, puzzleID, before, after); err != nil {
+		return err
+	}
+	if err := s.recordEdit(ctx, qtx, puzzleID, EditRestore, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DiffVersions compares two versions of a puzzle. Either may be
+// CurrentVersion.
+func (s *Service) DiffVersions(ctx context.Context, puzzleID, fromID, toID string) (VersionDiff, error) {
+	fromLabel, from, err := loadVersion(ctx, s.Queries, puzzleID, fromID)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	toLabel, to, err := loadVersion(ctx, s.Queries, puzzleID, toID)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+
+	d := diffGridStates(from, to)
+	d.FromLabel, d.ToLabel = fromLabel, toLabel
+	return d, nil
+}
+
+// squareStyle describes how a square is drawn beyond its answer, e.g.
+// "circled, shaded, #ffcc00, bars TL"; "" for a plain square.
+func squareStyle(c db.Cell) string {
+	var parts []string
+	if c.Circled {
+		parts = append(parts, "circled")
+	}
+	if c.Shaded {
+		parts = append(parts, "shaded")
+	}
+	if c.Color != "" {
+		parts = append(parts, c.Color)
+	}
+	if c.Bars != "" {
+		parts = append(parts, "bars "+c.Bars)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func diffGridStates(from, to gridState) VersionDiff {
+	d := VersionDiff{
+		FromName:   from.Name,
+		ToName:     to.Name,
+		FromWidth:  from.Width,
+		FromHeight: from.Height,
+		ToWidth:    to.Width,
+		ToHeight:   to.Height,
+	}
+
+	type square struct{ value, style string }
+	squares := func(st gridState) map[string]square {
+		m := make(map[string]square)
+		for _, c := range st.Cells {
+			if c.X >= st.Width || c.Y >= st.Height {
+				continue
+			}
+			v := c.Solution
+			if c.IsBlock {
+				v = "#"
+			}
+			m[fmt.Sprintf("%d,%d", c.X, c.Y)] = square{v, squareStyle(c)}
+		}
+		return m
+	}
+	fromSquares, toSquares := squares(from), squares(to)
+	for y := int64(0); y < max(from.Height, to.Height); y++ {
+		for x := int64(0); x < max(from.Width, to.Width); x++ {
+			key := fmt.Sprintf("%d,%d", x, y)
+			a, b := fromSquares[key], toSquares[key]
+			if a != b {
+				d.Cells = append(d.Cells, CellChange{X: x, Y: y, From: a.value, To: b.value, FromStyle: a.style, ToStyle: b.style})
+			}
+		}
+	}
+
+	clues := func(st gridState) map[string]db.Clue {
+		m := make(map[string]db.Clue)
+		for _, c := range st.Clues {
+			m[fmt.Sprintf("%d-%s", c.Number, c.Direction)] = c
+		}
+		return m
+	}
+	fromClues, toClues := clues(from), clues(to)
+	seen := make(map[string]bool)
+	for _, m := range []map[string]db.Clue{fromClues, toClues} {
+		for key, c := range m {
+			if seen[key] || fromClues[key].Text == toClues[key].Text {
+				continue
+			}
+			seen[key] = true
+			d.Clues = append(d.Clues, ClueChange{
+				Number:    c.Number,
+				Direction: Direction(c.Direction),
+				From:      fromClues[key].Text,
+				To:        toClues[key].Text,
+			})
+		}
+	}
+	sort.Slice(d.Clues, func(i, j int) bool {
+		if d.Clues[i].Direction != d.Clues[j].Direction {
+			return d.Clues[i].Direction == DirectionAcross
+		}
+		return d.Clues[i].Number < d.Clues[j].Number
+	})
+
+	return d
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"share_word/internal/db"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuzzleVersions(t *testing.T) {
+	svc, _, _ := SetupTestService(t)
+	ctx := context.Background()
+	svc.SkipCooldown = true
+
+	owner, err := svc.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := svc.CreatePuzzle(ctx, "Fills", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, svc.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+
+	sess, err := svc.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	_, err = svc.SaveVersion(ctx, p.ID, owner.ID, "  ")
+	assert.Error(t, err)
+
+	first, err := svc.SaveVersion(ctx, p.ID, owner.ID, "First fill")
+	require.NoError(t, err)
+
+	// Work on an alternate fill
+	require.NoError(t, svc.SetCellSolution(ctx, p.ID, 0, 0, "Z"))
+	require.NoError(t, svc.SaveClue(ctx, p.ID, 1, DirectionAcross, "New hint"))
+	require.NoError(t, svc.ResizePuzzle(ctx, p.ID, 6, 5))
+	second, err := svc.SaveVersion(ctx, p.ID, owner.ID, "Second fill")
+	require.NoError(t, err)
+
+	versions, err := svc.GetVersions(ctx, p.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "Second fill", versions[0].Name)
+	assert.Equal(t, "owner", versions[0].CreatedByUsername)
+
+	t.Run("diff", func(t *testing.T) {
+		d, err := svc.DiffVersions(ctx, p.ID, first.ID, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "First fill", d.FromLabel)
+		assert.True(t, d.Resized())
+		assert.Equal(t, CellChange{X: 0, Y: 0, From: "A", To: "Z"}, d.Cells[0])
+		require.Len(t, d.Clues, 1)
+		assert.Equal(t, "New hint", d.Clues[0].To)
+
+		d, err = svc.DiffVersions(ctx, p.ID, second.ID, CurrentVersion)
+		require.NoError(t, err)
+		assert.True(t, d.Empty())
+
+		// Styles are part of a square
+		plain := gridState{Width: 5, Height: 5, Cells: []db.Cell{{X: 2, Y: 0, Solution: "C"}}}
+		styled := gridState{Width: 5, Height: 5, Cells: []db.Cell{{X: 2, Y: 0, Solution: "C", Circled: true, Bars: "TL"}}}
+		d = diffGridStates(plain, styled)
+		require.Len(t, d.Cells, 1)
+		assert.Equal(t, CellChange{X: 2, Y: 0, From: "C", To: "C", ToStyle: "circled, bars TL"}, d.Cells[0])
+		assert.False(t, d.Empty())
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 0, 0, "Z", false))
+		require.NoError(t, svc.SetSessionCell(ctx, sess.ID, "", 1, 0, "B", false))
+		finisher, err := svc.RegisterUser(ctx, "finisher", "password123456")
+		require.NoError(t, err)
+		done, err := svc.PersonalSession(ctx, p.ID, finisher.ID)
+		require.NoError(t, err)
+		require.NoError(t, svc.SetSessionCell(ctx, done.ID, "", 0, 0, "Z", false))
+		require.NoError(t, svc.Queries.CompleteSolveSession(ctx, db.CompleteSolveSessionParams{
+			ElapsedMs:   1000,
+			CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:          done.ID,
+		}))
+		require.NoError(t, svc.RestoreVersion(ctx, p.ID, first.ID))
+
+		got, _ := svc.Queries.GetPuzzle(ctx, p.ID)
+		assert.Equal(t, int64(5), got.Width)
+		cell, _ := svc.Queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: 0, Y: 0})
+		assert.Equal(t, "A", cell.Solution)
+		d, err := svc.DiffVersions(ctx, p.ID, first.ID, CurrentVersion)
+		require.NoError(t, err)
+		assert.True(t, d.Empty())
+
+		cells, _ := svc.GetSolveCells(ctx, p.ID, sess.ID)
+		assert.Equal(t, "", cells[0].Char, "letters in squares whose answer changed are cleared")
+		assert.Equal(t, "B", cells[1].Char, "the rest still fit")
+		cells, _ = svc.GetSolveCells(ctx, p.ID, done.ID)
+		assert.Equal(t, "Z", cells[0].Char, "finished solves are left alone")
+
+		changed, err := svc.Undo(ctx, p.ID)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		got, _ = svc.Queries.GetPuzzle(ctx, p.ID)
+		assert.Equal(t, int64(6), got.Width)
+	})
+
+	t.Run("versions belong to their puzzle", func(t *testing.T) {
+		other, err := svc.CreatePuzzle(ctx, "Other", owner.ID, 5, 5)
+		require.NoError(t, err)
+		assert.ErrorIs(t, svc.RestoreVersion(ctx, other.ID, first.ID), sql.ErrNoRows)
+	})
+}
@@ -17,6 +17,19 @@ type Cell struct {
 	IsBlock  bool
 	IsPencil bool
 	Solution string
+	Circled  bool
+	Shaded   bool
+	Color    string
+	Bars     string
+}
+
+type ChatMessage struct {
+	ID        string
+	PuzzleID  string
+	SessionID string
+	UserID    string
+	Body      string
+	CreatedAt time.Time
 }
 
 type Clue struct {
@@ -33,13 +46,45 @@ type Follow struct {
 }
 
 type Puzzle struct {
+	ID         string
+	OwnerID    string
+	Name       string
+	Width      int64
+	Height     int64
+	CreatedAt  time.Time
+	UpdatedAt  sql.NullTime
+	Visibility string
+}
+
+type PuzzleEdit struct {
+	ID        int64
+	PuzzleID  string
+	Kind      string
+	UndoState string
+	RedoState string
+	Undone    bool
+	CreatedAt time.Time
+}
+
+type PuzzleMember struct {
+	PuzzleID  string
+	UserID    string
+	Role      string
+	CreatedAt time.Time
+}
+
+type PuzzleRevision struct {
+	PuzzleID string
+	Revision int64
+}
+
+type PuzzleVersion struct {
 	ID        string
-	OwnerID   string
+	PuzzleID  string
 	Name      string
-	Width     int64
-	Height    int64
+	CreatedBy string
+	State     string
 	CreatedAt time.Time
-	UpdatedAt sql.NullTime
 }
 
 type Session struct {
@@ -48,6 +93,79 @@ type Session struct {
 	Expiry float64
 }
 
+type SessionCell struct {
+	SessionID string
+	X         int64
+	Y         int64
+	Char      string
+	IsWrong   bool
+	Revealed  bool
+	IsPencil  bool
+	EnteredBy string
+	EnteredAt int64
+}
+
+type SolveCheck struct {
+	ID        int64
+	SessionID string
+	X         int64
+	Y         int64
+	Wrong     bool
+	ElapsedMs int64
+	CheckedAt int64
+}
+
+type SolveEntry struct {
+	ID        int64
+	SessionID string
+	X         int64
+	Y         int64
+	Char      string
+	IsPencil  bool
+	Revealed  bool
+	UserID    string
+	ElapsedMs int64
+	EnteredAt int64
+}
+
+type SolveSession struct {
+	ID           string
+	PuzzleID     string
+	OwnerID      string
+	Kind         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Autocheck    bool
+	ElapsedMs    int64
+	RunningSince sql.NullTime
+	CompletedAt  sql.NullTime
+}
+
+type SolveSessionStream struct {
+	SessionID  string
+	InstanceID string
+	SeenAt     int64
+}
+
+type TabPresence struct {
+	TabKey     string
+	InstanceID string
+	PuzzleID   string
+	SessionID  string
+	Mode       string
+	UserID     string
+	Username   string
+	Color      string
+	SeenAt     int64
+}
+
+type TabState struct {
+	TabKey    string
+	Field     string
+	Value     string
+	UpdatedAt int64
+}
+
 type User struct {
 	ID           string
 	Username     string
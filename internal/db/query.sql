@@ -45,9 +45,15 @@ RETURNING *;
 -- name: UpdatePuzzleDimensions :exec
 UPDATE puzzles SET width = ?, height = ? WHERE id = ?;
 
+-- name: UpdatePuzzleName :exec
+UPDATE puzzles SET name = ? WHERE id = ?;
+
 -- name: UpdatePuzzleUpdatedAt :exec
 UPDATE puzzles SET updated_at = CURRENT_TIMESTAMP WHERE id = ?;
 
+-- name: UpdatePuzzleVisibility :exec
+UPDATE puzzles SET visibility = ? WHERE id = ?;
+
 -- name: GetClues :many
 SELECT * FROM clues WHERE puzzle_id = ?;
 
@@ -71,25 +77,41 @@ VALUES (?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(puzzle_id, x, y) DO UPDATE SET
     char = excluded.char,
     is_block = excluded.is_block,
-    is_pencil = excluded.is_pencil;
+    is_pencil = excluded.is_pencil,
+    solution = CASE WHEN excluded.is_block THEN '' ELSE cells.solution END;
 
 -- name: ImportCell :exec
-INSERT INTO cells (puzzle_id, x, y, char, is_block, is_pencil, solution)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO cells (puzzle_id, x, y, char, is_block, is_pencil, solution, circled, shaded, color, bars)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(puzzle_id, x, y) DO UPDATE SET
     char = excluded.char,
     solution = excluded.solution,
     is_block = excluded.is_block,
-    is_pencil = excluded.is_pencil;
+    is_pencil = excluded.is_pencil,
+    circled = excluded.circled,
+    shaded = excluded.shaded,
+    color = excluded.color,
+    bars = excluded.bars;
+
+-- name: UpdateCellSolution :exec
+UPDATE cells SET solution = ?
+WHERE puzzle_id = ? AND x = ? AND y = ? AND is_block = FALSE;
+
+-- name: UpdateCellStyle :exec
+UPDATE cells SET circled = ?, shaded = ?, color = ?, bars = ?
+WHERE puzzle_id = ? AND x = ? AND y = ?;
 
 -- name: ToggleBlock :exec
 UPDATE cells 
-SET is_block = NOT is_block, char = '' 
+SET is_block = NOT is_block, char = '', solution = ''
 WHERE puzzle_id = ? AND x = ? AND y = ?;
 
 -- name: GetCells :many
 SELECT * FROM cells WHERE puzzle_id = ? ORDER BY y, x;
 
+-- name: GetCell :one
+SELECT * FROM cells WHERE puzzle_id = ? AND x = ? AND y = ? LIMIT 1;
+
 -- name: DeleteCellsOutside :exec
 DELETE FROM cells WHERE puzzle_id = ? AND (x >= ? OR y >= ?);
 
@@ -99,7 +121,20 @@ DELETE FROM cells WHERE puzzle_id = ?;
 -- name: GetPuzzlesByOwner :many
 SELECT p.*, u.username as owner_username FROM puzzles p
 JOIN users u ON u.id = p.owner_id
-WHERE p.owner_id = ? ORDER BY p.created_at DESC LIMIT ? OFFSET ?;
+WHERE p.owner_id = sqlc.arg(owner_id)
+  AND (
+    p.owner_id = sqlc.arg(viewer_id)
+    OR p.visibility = 'public'
+    OR (p.visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id) AND f.followed_id = p.owner_id
+    ))
+    OR EXISTS (
+        SELECT 1 FROM puzzle_members m
+        WHERE m.puzzle_id = p.id AND m.user_id = sqlc.arg(viewer_id)
+    )
+  )
+ORDER BY p.created_at DESC LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: GetPuzzlesFromFollowing :many
 SELECT p.* FROM puzzles p
@@ -111,11 +146,278 @@ ORDER BY p.created_at DESC LIMIT ? OFFSET ?;
 SELECT p.*, u.username as owner_username FROM puzzles p
 JOIN follows f ON f.followed_id = p.owner_id
 JOIN users u ON u.id = p.owner_id
-WHERE f.follower_id = ?
+WHERE f.follower_id = ? AND p.visibility IN ('public', 'followers')
 ORDER BY p.created_at DESC LIMIT ? OFFSET ?;
 
 -- name: GetLastPuzzleByOwner :one
 SELECT * FROM puzzles
 WHERE owner_id = ?
 ORDER BY created_at DESC
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: UpsertPuzzleMember :exec
+INSERT INTO puzzle_members (puzzle_id, user_id, role)
+VALUES (?, ?, ?)
+ON CONFLICT(puzzle_id, user_id) DO UPDATE SET
+    role = excluded.role;
+
+-- name: DeletePuzzleMember :exec
+DELETE FROM puzzle_members WHERE puzzle_id = ? AND user_id = ?;
+
+-- name: GetPuzzleMember :one
+SELECT * FROM puzzle_members WHERE puzzle_id = ? AND user_id = ? LIMIT 1;
+
+-- name: GetPuzzleMembers :many
+SELECT m.*, u.username FROM puzzle_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.puzzle_id = ?
+ORDER BY m.created_at, u.username;
+
+-- name: CreateSolveSession :one
+INSERT INTO solve_sessions (id, puzzle_id, owner_id, kind)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetSolveSession :one
+SELECT * FROM solve_sessions WHERE id = ? LIMIT 1;
+
+-- name: GetPersonalSolveSession :one
+SELECT * FROM solve_sessions
+WHERE puzzle_id = ? AND owner_id = ? AND kind = 'personal'
+LIMIT 1;
+
+-- name: UpdateSolveSessionAutocheck :exec
+UPDATE solve_sessions SET autocheck = ? WHERE id = ?;
+
+-- name: UpdateSolveSessionUpdatedAt :exec
+UPDATE solve_sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = ?;
+
+-- name: StartSolveSessionTimer :execrows
+UPDATE solve_sessions SET running_since = ?
+WHERE id = ? AND running_since IS NULL AND completed_at IS NULL;
+
+-- name: PauseSolveSessionTimer :exec
+UPDATE solve_sessions SET elapsed_ms = ?, running_since = NULL
+WHERE id = ? AND running_since IS NOT NULL;
+
+-- name: TouchSessionStream :exec
+INSERT INTO solve_session_streams (session_id, instance_id, seen_at)
+VALUES (?, ?, ?)
+ON CONFLICT (session_id, instance_id) DO UPDATE SET seen_at = excluded.seen_at;
+
+-- name: DeleteSessionStream :exec
+DELETE FROM solve_session_streams WHERE session_id = ? AND instance_id = ?;
+
+-- name: CountOtherSessionStreams :one
+SELECT COUNT(*) FROM solve_session_streams
+WHERE session_id = sqlc.arg(session_id) AND instance_id != sqlc.arg(instance_id)
+  AND seen_at > sqlc.arg(seen_since);
+
+-- name: DeleteStaleSessionStreams :many
+DELETE FROM solve_session_streams WHERE seen_at <= ?
+RETURNING session_id;
+
+-- name: JoinTab :exec
+INSERT INTO tab_presence (tab_key, instance_id, puzzle_id, session_id, mode, user_id, username, color, seen_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (tab_key, instance_id) DO UPDATE SET
+    puzzle_id = excluded.puzzle_id,
+    session_id = excluded.session_id,
+    mode = excluded.mode,
+    user_id = excluded.user_id,
+    username = excluded.username,
+    color = excluded.color,
+    seen_at = excluded.seen_at;
+
+-- name: LeaveTab :exec
+DELETE FROM tab_presence WHERE tab_key = ? AND instance_id = ?;
+
+-- name: CountTabInstances :one
+SELECT COUNT(*) FROM tab_presence
+WHERE tab_key = sqlc.arg(tab_key) AND seen_at > sqlc.arg(seen_since);
+
+-- name: TouchTabs :exec
+UPDATE tab_presence SET seen_at = ? WHERE instance_id = ?;
+
+-- name: DeleteStaleTabs :many
+DELETE FROM tab_presence WHERE seen_at <= ?
+RETURNING *;
+
+-- name: ListTabsOn :many
+SELECT * FROM tab_presence
+WHERE puzzle_id = sqlc.arg(puzzle_id) AND mode = sqlc.arg(mode) AND session_id = sqlc.arg(session_id)
+  AND seen_at > sqlc.arg(seen_since);
+
+-- name: SetTabState :exec
+INSERT INTO tab_state (tab_key, field, value, updated_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (tab_key, field) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at;
+
+-- name: GetTabState :one
+SELECT value FROM tab_state WHERE tab_key = ? AND field = ?;
+
+-- name: ListTabState :many
+SELECT tab_key, value FROM tab_state WHERE field = ?;
+
+-- name: DeleteTabState :exec
+DELETE FROM tab_state WHERE tab_key = ? AND field = ?;
+
+-- name: DeleteTabStates :exec
+DELETE FROM tab_state WHERE tab_key = ?;
+
+-- name: DeleteStrayTabStates :exec
+DELETE FROM tab_state
+WHERE updated_at <= ? AND tab_key NOT IN (SELECT tab_key FROM tab_presence);
+
+-- name: CompleteSolveSession :exec
+UPDATE solve_sessions SET elapsed_ms = ?, running_since = NULL, completed_at = ?
+WHERE id = ? AND completed_at IS NULL;
+
+-- name: GetCompletedSolvesByOwner :many
+SELECT s.*, p.name AS puzzle_name FROM solve_sessions s
+JOIN puzzles p ON p.id = s.puzzle_id
+WHERE s.owner_id = sqlc.arg(owner_id)
+  AND s.completed_at IS NOT NULL
+  AND (s.owner_id = sqlc.arg(viewer_id) OR p.visibility = 'public')
+ORDER BY s.completed_at DESC LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetSolveHistory :many
+SELECT s.puzzle_id, s.elapsed_ms, s.completed_at, p.width, p.height,
+  EXISTS (SELECT 1 FROM solve_checks c WHERE c.session_id = s.id) AS checked,
+  EXISTS (SELECT 1 FROM solve_entries e WHERE e.session_id = s.id AND e.revealed) AS revealed
+FROM solve_sessions s
+JOIN puzzles p ON p.id = s.puzzle_id
+WHERE s.completed_at IS NOT NULL
+  AND (s.owner_id = sqlc.arg(user_id) OR EXISTS (
+    SELECT 1 FROM solve_entries e
+    WHERE e.session_id = s.id AND e.user_id = sqlc.arg(user_id) AND e.char != '' AND NOT e.revealed
+  ))
+  AND (sqlc.arg(user_id) = sqlc.arg(viewer_id) OR p.visibility = 'public')
+ORDER BY s.completed_at;
+
+-- name: GetSessionCells :many
+SELECT * FROM session_cells WHERE session_id = ? ORDER BY y, x;
+
+-- name: UpsertSessionCell :execrows
+INSERT INTO session_cells (session_id, x, y, char, is_pencil, entered_by, entered_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(session_id, x, y) DO UPDATE SET
+    char = excluded.char,
+    is_pencil = excluded.is_pencil,
+    is_wrong = FALSE,
+    entered_by = excluded.entered_by,
+    entered_at = excluded.entered_at
+WHERE session_cells.revealed = FALSE;
+
+-- name: SetSessionCellWrong :exec
+UPDATE session_cells SET is_wrong = ?
+WHERE session_id = ? AND x = ? AND y = ?;
+
+-- name: RevealSessionCell :exec
+INSERT INTO session_cells (session_id, x, y, char, revealed)
+VALUES (?, ?, ?, ?, TRUE)
+ON CONFLICT(session_id, x, y) DO UPDATE SET
+    char = excluded.char,
+    is_wrong = FALSE,
+    is_pencil = FALSE,
+    revealed = TRUE,
+    entered_by = '';
+
+-- name: DeleteSessionCellsOutside :exec
+DELETE FROM session_cells
+WHERE session_id IN (SELECT id FROM solve_sessions WHERE puzzle_id = ?)
+  AND (x >= ? OR y >= ?);
+
+-- name: DeleteOpenSessionCell :exec
+DELETE FROM session_cells
+WHERE session_id IN (SELECT id FROM solve_sessions WHERE puzzle_id = ? AND completed_at IS NULL)
+  AND x = ? AND y = ?;
+
+-- name: DeleteClue :exec
+DELETE FROM clues WHERE puzzle_id = ? AND number = ? AND direction = ?;
+
+-- name: CreatePuzzleEdit :exec
+INSERT INTO puzzle_edits (puzzle_id, kind, undo_state, redo_state)
+VALUES (?, ?, ?, ?);
+
+-- name: DeleteUndonePuzzleEdits :exec
+DELETE FROM puzzle_edits WHERE puzzle_id = ? AND undone = TRUE;
+
+-- name: TrimPuzzleEdits :exec
+DELETE FROM puzzle_edits
+WHERE puzzle_id = sqlc.arg(puzzle_id) AND id <= (
+    SELECT id FROM puzzle_edits WHERE puzzle_id = sqlc.arg(puzzle_id)
+    ORDER BY id DESC LIMIT 1 OFFSET sqlc.arg(keep)
+);
+
+-- name: GetLastAppliedPuzzleEdit :one
+SELECT * FROM puzzle_edits
+WHERE puzzle_id = ? AND undone = FALSE
+ORDER BY id DESC LIMIT 1;
+
+-- name: GetFirstUndonePuzzleEdit :one
+SELECT * FROM puzzle_edits
+WHERE puzzle_id = ? AND undone = TRUE
+ORDER BY id ASC LIMIT 1;
+
+-- name: SetPuzzleEditUndone :exec
+UPDATE puzzle_edits SET undone = ? WHERE id = ?;
+
+-- name: CreatePuzzleVersion :one
+INSERT INTO puzzle_versions (id, puzzle_id, name, created_by, state)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetPuzzleVersions :many
+SELECT v.id, v.puzzle_id, v.name, v.created_by, v.created_at, u.username AS created_by_username
+FROM puzzle_versions v
+JOIN users u ON u.id = v.created_by
+WHERE v.puzzle_id = ?
+ORDER BY v.created_at DESC, v.rowid DESC;
+
+-- name: GetPuzzleVersion :one
+SELECT * FROM puzzle_versions WHERE id = ? AND puzzle_id = ?;
+
+-- name: CreateChatMessage :one
+INSERT INTO chat_messages (id, puzzle_id, session_id, user_id, body)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetChatMessages :many
+SELECT m.id, m.puzzle_id, m.session_id, m.user_id, m.body, m.created_at, u.username
+FROM chat_messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.puzzle_id = ? AND m.session_id = ?
+ORDER BY m.created_at DESC, m.rowid DESC
+LIMIT ?;
+
+-- name: BumpPuzzleRevision :one
+INSERT INTO puzzle_revisions (puzzle_id, revision) VALUES (?, 1)
+ON CONFLICT(puzzle_id) DO UPDATE SET revision = revision + 1
+RETURNING revision;
+
+-- name: GetPuzzleRevision :one
+SELECT revision FROM puzzle_revisions WHERE puzzle_id = ?;
+
+-- name: CreateSolveEntry :exec
+INSERT INTO solve_entries (session_id, x, y, char, is_pencil, revealed, user_id, elapsed_ms, entered_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetSolveEntries :many
+SELECT * FROM solve_entries WHERE session_id = ? ORDER BY id;
+
+-- name: GetPuzzleSolveEntries :many
+SELECT solve_entries.* FROM solve_entries
+JOIN solve_sessions ON solve_sessions.id = solve_entries.session_id
+WHERE solve_sessions.puzzle_id = ?
+ORDER BY solve_entries.session_id, solve_entries.id;
+
+-- name: CreateSolveCheck :exec
+INSERT INTO solve_checks (session_id, x, y, wrong, elapsed_ms, checked_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetPuzzleSolveChecks :many
+SELECT solve_checks.* FROM solve_checks
+JOIN solve_sessions ON solve_sessions.id = solve_checks.session_id
+WHERE solve_sessions.puzzle_id = ?
+ORDER BY solve_checks.session_id, solve_checks.id;
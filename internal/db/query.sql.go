@@ -11,10 +11,109 @@ import (
 	"time"
 )
 
+const bumpPuzzleRevision = `-- name: BumpPuzzleRevision :one
+INSERT INTO puzzle_revisions (puzzle_id, revision) VALUES (?, 1)
+ON CONFLICT(puzzle_id) DO UPDATE SET revision = revision + 1
+RETURNING revision
+`
+
+func (q *Queries) BumpPuzzleRevision(ctx context.Context, puzzleID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, bumpPuzzleRevision, puzzleID)
+	var revision int64
+	err := row.Scan(&revision)
+	return revision, err
+}
+
+const completeSolveSession = `-- name: CompleteSolveSession :exec
+UPDATE solve_sessions SET elapsed_ms = ?, running_since = NULL, completed_at = ?
+WHERE id = ? AND completed_at IS NULL
+`
+
+type CompleteSolveSessionParams struct {
+	ElapsedMs   int64
+	CompletedAt sql.NullTime
+	ID          string
+}
+
+func (q *Queries) CompleteSolveSession(ctx context.Context, arg CompleteSolveSessionParams) error {
+	_, err := q.db.ExecContext(ctx, completeSolveSession, arg.ElapsedMs, arg.CompletedAt, arg.ID)
+	return err
+}
+
+const countOtherSessionStreams = `-- name: CountOtherSessionStreams :one
+SELECT COUNT(*) FROM solve_session_streams
+WHERE session_id = ?1 AND instance_id != ?2
+  AND seen_at > ?3
+`
+
+type CountOtherSessionStreamsParams struct {
+	SessionID  string
+	InstanceID string
+	SeenSince  int64
+}
+
+func (q *Queries) CountOtherSessionStreams(ctx context.Context, arg CountOtherSessionStreamsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOtherSessionStreams, arg.SessionID, arg.InstanceID, arg.SeenSince)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countTabInstances = `-- name: CountTabInstances :one
+SELECT COUNT(*) FROM tab_presence
+WHERE tab_key = ?1 AND seen_at > ?2
+`
+
+type CountTabInstancesParams struct {
+	TabKey    string
+	SeenSince int64
+}
+
+func (q *Queries) CountTabInstances(ctx context.Context, arg CountTabInstancesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countTabInstances, arg.TabKey, arg.SeenSince)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createChatMessage = `-- name: CreateChatMessage :one
+INSERT INTO chat_messages (id, puzzle_id, session_id, user_id, body)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, puzzle_id, session_id, user_id, body, created_at
+`
+
+type CreateChatMessageParams struct {
+	ID        string
+	PuzzleID  string
+	SessionID string
+	UserID    string
+	Body      string
+}
+
+func (q *Queries) CreateChatMessage(ctx context.Context, arg CreateChatMessageParams) (ChatMessage, error) {
+	row := q.db.QueryRowContext(ctx, createChatMessage,
+		arg.ID,
+		arg.PuzzleID,
+		arg.SessionID,
+		arg.UserID,
+		arg.Body,
+	)
+	var i ChatMessage
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.SessionID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const createPuzzle = `-- name: CreatePuzzle :one
 INSERT INTO puzzles (id, owner_id, name, width, height)
 VALUES (?, ?, ?, ?, ?)
-RETURNING id, owner_id, name, width, height, created_at, updated_at
+RETURNING id, owner_id, name, width, height, created_at, updated_at, visibility
 `
 
 type CreatePuzzleParams struct {
@@ -42,6 +141,157 @@ func (q *Queries) CreatePuzzle(ctx context.Context, arg CreatePuzzleParams) (Puz
 		&i.Height,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const createPuzzleEdit = `-- name: CreatePuzzleEdit :exec
+INSERT INTO puzzle_edits (puzzle_id, kind, undo_state, redo_state)
+VALUES (?, ?, ?, ?)
+`
+
+type CreatePuzzleEditParams struct {
+	PuzzleID  string
+	Kind      string
+	UndoState string
+	RedoState string
+}
+
+func (q *Queries) CreatePuzzleEdit(ctx context.Context, arg CreatePuzzleEditParams) error {
+	_, err := q.db.ExecContext(ctx, createPuzzleEdit,
+		arg.PuzzleID,
+		arg.Kind,
+		arg.UndoState,
+		arg.RedoState,
+	)
+	return err
+}
+
+const createPuzzleVersion = `-- name: CreatePuzzleVersion :one
+INSERT INTO puzzle_versions (id, puzzle_id, name, created_by, state)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, puzzle_id, name, created_by, state, created_at
+`
+
+type CreatePuzzleVersionParams struct {
+	ID        string
+	PuzzleID  string
+	Name      string
+	CreatedBy string
+	State     string
+}
+
+func (q *Queries) CreatePuzzleVersion(ctx context.Context, arg CreatePuzzleVersionParams) (PuzzleVersion, error) {
+	row := q.db.QueryRowContext(ctx, createPuzzleVersion,
+		arg.ID,
+		arg.PuzzleID,
+		arg.Name,
+		arg.CreatedBy,
+		arg.State,
+	)
+	var i PuzzleVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.State,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSolveCheck = `-- name: CreateSolveCheck :exec
+INSERT INTO solve_checks (session_id, x, y, wrong, elapsed_ms, checked_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateSolveCheckParams struct {
+	SessionID string
+	X         int64
+	Y         int64
+	Wrong     bool
+	ElapsedMs int64
+	CheckedAt int64
+}
+
+func (q *Queries) CreateSolveCheck(ctx context.Context, arg CreateSolveCheckParams) error {
+	_, err := q.db.ExecContext(ctx, createSolveCheck,
+		arg.SessionID,
+		arg.X,
+		arg.Y,
+		arg.Wrong,
+		arg.ElapsedMs,
+		arg.CheckedAt,
+	)
+	return err
+}
+
+const createSolveEntry = `-- name: CreateSolveEntry :exec
+INSERT INTO solve_entries (session_id, x, y, char, is_pencil, revealed, user_id, elapsed_ms, entered_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateSolveEntryParams struct {
+	SessionID string
+	X         int64
+	Y         int64
+	Char      string
+	IsPencil  bool
+	Revealed  bool
+	UserID    string
+	ElapsedMs int64
+	EnteredAt int64
+}
+
+func (q *Queries) CreateSolveEntry(ctx context.Context, arg CreateSolveEntryParams) error {
+	_, err := q.db.ExecContext(ctx, createSolveEntry,
+		arg.SessionID,
+		arg.X,
+		arg.Y,
+		arg.Char,
+		arg.IsPencil,
+		arg.Revealed,
+		arg.UserID,
+		arg.ElapsedMs,
+		arg.EnteredAt,
+	)
+	return err
+}
+
+const createSolveSession = `-- name: CreateSolveSession :one
+INSERT INTO solve_sessions (id, puzzle_id, owner_id, kind)
+VALUES (?, ?, ?, ?)
+RETURNING id, puzzle_id, owner_id, kind, created_at, updated_at, autocheck, elapsed_ms, running_since, completed_at
+`
+
+type CreateSolveSessionParams struct {
+	ID       string
+	PuzzleID string
+	OwnerID  string
+	Kind     string
+}
+
+func (q *Queries) CreateSolveSession(ctx context.Context, arg CreateSolveSessionParams) (SolveSession, error) {
+	row := q.db.QueryRowContext(ctx, createSolveSession,
+		arg.ID,
+		arg.PuzzleID,
+		arg.OwnerID,
+		arg.Kind,
+	)
+	var i SolveSession
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.OwnerID,
+		&i.Kind,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Autocheck,
+		&i.ElapsedMs,
+		&i.RunningSince,
+		&i.CompletedAt,
 	)
 	return i, err
 }
@@ -109,6 +359,191 @@ func (q *Queries) DeleteCellsOutside(ctx context.Context, arg DeleteCellsOutside
 	return err
 }
 
+const deleteClue = `-- name: DeleteClue :exec
+DELETE FROM clues WHERE puzzle_id = ? AND number = ? AND direction = ?
+`
+
+type DeleteClueParams struct {
+	PuzzleID  string
+	Number    int64
+	Direction string
+}
+
+func (q *Queries) DeleteClue(ctx context.Context, arg DeleteClueParams) error {
+	_, err := q.db.ExecContext(ctx, deleteClue, arg.PuzzleID, arg.Number, arg.Direction)
+	return err
+}
+
+const deleteOpenSessionCell = `-- name: DeleteOpenSessionCell :exec
+DELETE FROM session_cells
+WHERE session_id IN (SELECT id FROM solve_sessions WHERE puzzle_id = ? AND completed_at IS NULL)
+  AND x = ? AND y = ?
+`
+
+type DeleteOpenSessionCellParams struct {
+	PuzzleID string
+	X        int64
+	Y        int64
+}
+
+func (q *Queries) DeleteOpenSessionCell(ctx context.Context, arg DeleteOpenSessionCellParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOpenSessionCell, arg.PuzzleID, arg.X, arg.Y)
+	return err
+}
+
+const deletePuzzleMember = `-- name: DeletePuzzleMember :exec
+DELETE FROM puzzle_members WHERE puzzle_id = ? AND user_id = ?
+`
+
+type DeletePuzzleMemberParams struct {
+	PuzzleID string
+	UserID   string
+}
+
+func (q *Queries) DeletePuzzleMember(ctx context.Context, arg DeletePuzzleMemberParams) error {
+	_, err := q.db.ExecContext(ctx, deletePuzzleMember, arg.PuzzleID, arg.UserID)
+	return err
+}
+
+const deleteSessionCellsOutside = `-- name: DeleteSessionCellsOutside :exec
+DELETE FROM session_cells
+WHERE session_id IN (SELECT id FROM solve_sessions WHERE puzzle_id = ?)
+  AND (x >= ? OR y >= ?)
+`
+
+type DeleteSessionCellsOutsideParams struct {
+	PuzzleID string
+	X        int64
+	Y        int64
+}
+
+func (q *Queries) DeleteSessionCellsOutside(ctx context.Context, arg DeleteSessionCellsOutsideParams) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionCellsOutside, arg.PuzzleID, arg.X, arg.Y)
+	return err
+}
+
+const deleteSessionStream = `-- name: DeleteSessionStream :exec
+DELETE FROM solve_session_streams WHERE session_id = ? AND instance_id = ?
+`
+
+type DeleteSessionStreamParams struct {
+	SessionID  string
+	InstanceID string
+}
+
+func (q *Queries) DeleteSessionStream(ctx context.Context, arg DeleteSessionStreamParams) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionStream, arg.SessionID, arg.InstanceID)
+	return err
+}
+
+const deleteStaleSessionStreams = `-- name: DeleteStaleSessionStreams :many
+DELETE FROM solve_session_streams WHERE seen_at <= ?
+RETURNING session_id
+`
+
+func (q *Queries) DeleteStaleSessionStreams(ctx context.Context, seenAt int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, deleteStaleSessionStreams, seenAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var session_id string
+		if err := rows.Scan(&session_id); err != nil {
+			return nil, err
+		}
+		items = append(items, session_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteStaleTabs = `-- name: DeleteStaleTabs :many
+DELETE FROM tab_presence WHERE seen_at <= ?
+RETURNING tab_key, instance_id, puzzle_id, session_id, mode, user_id, username, color, seen_at
+`
+
+func (q *Queries) DeleteStaleTabs(ctx context.Context, seenAt int64) ([]TabPresence, error) {
+	rows, err := q.db.QueryContext(ctx, deleteStaleTabs, seenAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TabPresence
+	for rows.Next() {
+		var i TabPresence
+		if err := rows.Scan(
+			&i.TabKey,
+			&i.InstanceID,
+			&i.PuzzleID,
+			&i.SessionID,
+			&i.Mode,
+			&i.UserID,
+			&i.Username,
+			&i.Color,
+			&i.SeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteStrayTabStates = `-- name: DeleteStrayTabStates :exec
+DELETE FROM tab_state
+WHERE updated_at <= ? AND tab_key NOT IN (SELECT tab_key FROM tab_presence)
+`
+
+func (q *Queries) DeleteStrayTabStates(ctx context.Context, updatedAt int64) error {
+	_, err := q.db.ExecContext(ctx, deleteStrayTabStates, updatedAt)
+	return err
+}
+
+const deleteTabState = `-- name: DeleteTabState :exec
+DELETE FROM tab_state WHERE tab_key = ? AND field = ?
+`
+
+type DeleteTabStateParams struct {
+	TabKey string
+	Field  string
+}
+
+func (q *Queries) DeleteTabState(ctx context.Context, arg DeleteTabStateParams) error {
+	_, err := q.db.ExecContext(ctx, deleteTabState, arg.TabKey, arg.Field)
+	return err
+}
+
+const deleteTabStates = `-- name: DeleteTabStates :exec
+DELETE FROM tab_state WHERE tab_key = ?
+`
+
+func (q *Queries) DeleteTabStates(ctx context.Context, tabKey string) error {
+	_, err := q.db.ExecContext(ctx, deleteTabStates, tabKey)
+	return err
+}
+
+const deleteUndonePuzzleEdits = `-- name: DeleteUndonePuzzleEdits :exec
+DELETE FROM puzzle_edits WHERE puzzle_id = ? AND undone = TRUE
+`
+
+func (q *Queries) DeleteUndonePuzzleEdits(ctx context.Context, puzzleID string) error {
+	_, err := q.db.ExecContext(ctx, deleteUndonePuzzleEdits, puzzleID)
+	return err
+}
+
 const followUser = `-- name: FollowUser :exec
 INSERT INTO follows (follower_id, followed_id)
 VALUES (?, ?)
@@ -124,8 +559,37 @@ func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) error {
 	return err
 }
 
+const getCell = `-- name: GetCell :one
+SELECT puzzle_id, x, y, char, is_block, is_pencil, solution, circled, shaded, color, bars FROM cells WHERE puzzle_id = ? AND x = ? AND y = ? LIMIT 1
+`
+
+type GetCellParams struct {
+	PuzzleID string
+	X        int64
+	Y        int64
+}
+
+func (q *Queries) GetCell(ctx context.Context, arg GetCellParams) (Cell, error) {
+	row := q.db.QueryRowContext(ctx, getCell, arg.PuzzleID, arg.X, arg.Y)
+	var i Cell
+	err := row.Scan(
+		&i.PuzzleID,
+		&i.X,
+		&i.Y,
+		&i.Char,
+		&i.IsBlock,
+		&i.IsPencil,
+		&i.Solution,
+		&i.Circled,
+		&i.Shaded,
+		&i.Color,
+		&i.Bars,
+	)
+	return i, err
+}
+
 const getCells = `-- name: GetCells :many
-SELECT puzzle_id, x, y, char, is_block, is_pencil, solution FROM cells WHERE puzzle_id = ? ORDER BY y, x
+SELECT puzzle_id, x, y, char, is_block, is_pencil, solution, circled, shaded, color, bars FROM cells WHERE puzzle_id = ? ORDER BY y, x
 `
 
 func (q *Queries) GetCells(ctx context.Context, puzzleID string) ([]Cell, error) {
@@ -145,6 +609,66 @@ func (q *Queries) GetCells(ctx context.Context, puzzleID string) ([]Cell, error)
 			&i.IsBlock,
 			&i.IsPencil,
 			&i.Solution,
+			&i.Circled,
+			&i.Shaded,
+			&i.Color,
+			&i.Bars,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChatMessages = `-- name: GetChatMessages :many
+SELECT m.id, m.puzzle_id, m.session_id, m.user_id, m.body, m.created_at, u.username
+FROM chat_messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.puzzle_id = ? AND m.session_id = ?
+ORDER BY m.created_at DESC, m.rowid DESC
+LIMIT ?
+`
+
+type GetChatMessagesParams struct {
+	PuzzleID  string
+	SessionID string
+	Limit     int64
+}
+
+type GetChatMessagesRow struct {
+	ID        string
+	PuzzleID  string
+	SessionID string
+	UserID    string
+	Body      string
+	CreatedAt time.Time
+	Username  string
+}
+
+func (q *Queries) GetChatMessages(ctx context.Context, arg GetChatMessagesParams) ([]GetChatMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChatMessages, arg.PuzzleID, arg.SessionID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChatMessagesRow
+	for rows.Next() {
+		var i GetChatMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PuzzleID,
+			&i.SessionID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.Username,
 		); err != nil {
 			return nil, err
 		}
@@ -191,6 +715,97 @@ func (q *Queries) GetClues(ctx context.Context, puzzleID string) ([]Clue, error)
 	return items, nil
 }
 
+const getCompletedSolvesByOwner = `-- name: GetCompletedSolvesByOwner :many
+SELECT s.id, s.puzzle_id, s.owner_id, s.kind, s.created_at, s.updated_at, s.autocheck, s.elapsed_ms, s.running_since, s.completed_at, p.name AS puzzle_name FROM solve_sessions s
+JOIN puzzles p ON p.id = s.puzzle_id
+WHERE s.owner_id = ?1
+  AND s.completed_at IS NOT NULL
+  AND (s.owner_id = ?2 OR p.visibility = 'public')
+ORDER BY s.completed_at DESC LIMIT ?3 OFFSET ?4
+`
+
+type GetCompletedSolvesByOwnerParams struct {
+	OwnerID  string
+	ViewerID string
+	Limit    int64
+	Offset   int64
+}
+
+type GetCompletedSolvesByOwnerRow struct {
+	ID           string
+	PuzzleID     string
+	OwnerID      string
+	Kind         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Autocheck    bool
+	ElapsedMs    int64
+	RunningSince sql.NullTime
+	CompletedAt  sql.NullTime
+	PuzzleName   string
+}
+
+func (q *Queries) GetCompletedSolvesByOwner(ctx context.Context, arg GetCompletedSolvesByOwnerParams) ([]GetCompletedSolvesByOwnerRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCompletedSolvesByOwner,
+		arg.OwnerID,
+		arg.ViewerID,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCompletedSolvesByOwnerRow
+	for rows.Next() {
+		var i GetCompletedSolvesByOwnerRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PuzzleID,
+			&i.OwnerID,
+			&i.Kind,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Autocheck,
+			&i.ElapsedMs,
+			&i.RunningSince,
+			&i.CompletedAt,
+			&i.PuzzleName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFirstUndonePuzzleEdit = `-- name: GetFirstUndonePuzzleEdit :one
+SELECT id, puzzle_id, kind, undo_state, redo_state, undone, created_at FROM puzzle_edits
+WHERE puzzle_id = ? AND undone = TRUE
+ORDER BY id ASC LIMIT 1
+`
+
+func (q *Queries) GetFirstUndonePuzzleEdit(ctx context.Context, puzzleID string) (PuzzleEdit, error) {
+	row := q.db.QueryRowContext(ctx, getFirstUndonePuzzleEdit, puzzleID)
+	var i PuzzleEdit
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.Kind,
+		&i.UndoState,
+		&i.RedoState,
+		&i.Undone,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const getFollowers = `-- name: GetFollowers :many
 SELECT u.id, u.username, u.password_hash, u.created_at FROM users u
 JOIN follows f on u.id = f.follower_id
@@ -275,8 +890,29 @@ func (q *Queries) GetFollowing(ctx context.Context, arg GetFollowingParams) ([]U
 	return items, nil
 }
 
+const getLastAppliedPuzzleEdit = `-- name: GetLastAppliedPuzzleEdit :one
+SELECT id, puzzle_id, kind, undo_state, redo_state, undone, created_at FROM puzzle_edits
+WHERE puzzle_id = ? AND undone = FALSE
+ORDER BY id DESC LIMIT 1
+`
+
+func (q *Queries) GetLastAppliedPuzzleEdit(ctx context.Context, puzzleID string) (PuzzleEdit, error) {
+	row := q.db.QueryRowContext(ctx, getLastAppliedPuzzleEdit, puzzleID)
+	var i PuzzleEdit
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.Kind,
+		&i.UndoState,
+		&i.RedoState,
+		&i.Undone,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const getLastPuzzleByOwner = `-- name: GetLastPuzzleByOwner :one
-SELECT id, owner_id, name, width, height, created_at, updated_at FROM puzzles
+SELECT id, owner_id, name, width, height, created_at, updated_at, visibility FROM puzzles
 WHERE owner_id = ?
 ORDER BY created_at DESC
 LIMIT 1
@@ -293,12 +929,42 @@ func (q *Queries) GetLastPuzzleByOwner(ctx context.Context, ownerID string) (Puz
 		&i.Height,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getPersonalSolveSession = `-- name: GetPersonalSolveSession :one
+SELECT id, puzzle_id, owner_id, kind, created_at, updated_at, autocheck, elapsed_ms, running_since, completed_at FROM solve_sessions
+WHERE puzzle_id = ? AND owner_id = ? AND kind = 'personal'
+LIMIT 1
+`
+
+type GetPersonalSolveSessionParams struct {
+	PuzzleID string
+	OwnerID  string
+}
+
+func (q *Queries) GetPersonalSolveSession(ctx context.Context, arg GetPersonalSolveSessionParams) (SolveSession, error) {
+	row := q.db.QueryRowContext(ctx, getPersonalSolveSession, arg.PuzzleID, arg.OwnerID)
+	var i SolveSession
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.OwnerID,
+		&i.Kind,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Autocheck,
+		&i.ElapsedMs,
+		&i.RunningSince,
+		&i.CompletedAt,
 	)
 	return i, err
 }
 
 const getPuzzle = `-- name: GetPuzzle :one
-SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, u.username as owner_username FROM puzzles p
+SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, p.visibility, u.username as owner_username FROM puzzles p
 JOIN users u ON u.id = p.owner_id
 WHERE p.id = ? LIMIT 1
 `
@@ -311,6 +977,7 @@ type GetPuzzleRow struct {
 	Height        int64
 	CreatedAt     time.Time
 	UpdatedAt     sql.NullTime
+	Visibility    string
 	OwnerUsername string
 }
 
@@ -325,21 +992,112 @@ func (q *Queries) GetPuzzle(ctx context.Context, id string) (GetPuzzleRow, error
 		&i.Height,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Visibility,
 		&i.OwnerUsername,
 	)
 	return i, err
 }
 
+const getPuzzleMember = `-- name: GetPuzzleMember :one
+SELECT puzzle_id, user_id, role, created_at FROM puzzle_members WHERE puzzle_id = ? AND user_id = ? LIMIT 1
+`
+
+type GetPuzzleMemberParams struct {
+	PuzzleID string
+	UserID   string
+}
+
+func (q *Queries) GetPuzzleMember(ctx context.Context, arg GetPuzzleMemberParams) (PuzzleMember, error) {
+	row := q.db.QueryRowContext(ctx, getPuzzleMember, arg.PuzzleID, arg.UserID)
+	var i PuzzleMember
+	err := row.Scan(
+		&i.PuzzleID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPuzzleMembers = `-- name: GetPuzzleMembers :many
+SELECT m.puzzle_id, m.user_id, m.role, m.created_at, u.username FROM puzzle_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.puzzle_id = ?
+ORDER BY m.created_at, u.username
+`
+
+type GetPuzzleMembersRow struct {
+	PuzzleID  string
+	UserID    string
+	Role      string
+	CreatedAt time.Time
+	Username  string
+}
+
+func (q *Queries) GetPuzzleMembers(ctx context.Context, puzzleID string) ([]GetPuzzleMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPuzzleMembers, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPuzzleMembersRow
+	for rows.Next() {
+		var i GetPuzzleMembersRow
+		if err := rows.Scan(
+			&i.PuzzleID,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPuzzleRevision = `-- name: GetPuzzleRevision :one
+SELECT revision FROM puzzle_revisions WHERE puzzle_id = ?
+`
+
+func (q *Queries) GetPuzzleRevision(ctx context.Context, puzzleID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getPuzzleRevision, puzzleID)
+	var revision int64
+	err := row.Scan(&revision)
+	return revision, err
+}
+
 const getPuzzlesByOwner = `-- name: GetPuzzlesByOwner :many
-SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, u.username as owner_username FROM puzzles p
+SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, p.visibility, u.username as owner_username FROM puzzles p
 JOIN users u ON u.id = p.owner_id
-WHERE p.owner_id = ? ORDER BY p.created_at DESC LIMIT ? OFFSET ?
+WHERE p.owner_id = ?1
+  AND (
+    p.owner_id = ?2
+    OR p.visibility = 'public'
+    OR (p.visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows f
+        WHERE f.follower_id = ?2 AND f.followed_id = p.owner_id
+    ))
+    OR EXISTS (
+        SELECT 1 FROM puzzle_members m
+        WHERE m.puzzle_id = p.id AND m.user_id = ?2
+    )
+  )
+ORDER BY p.created_at DESC LIMIT ?3 OFFSET ?4
 `
 
 type GetPuzzlesByOwnerParams struct {
-	OwnerID string
-	Limit   int64
-	Offset  int64
+	OwnerID  string
+	ViewerID string
+	Limit    int64
+	Offset   int64
 }
 
 type GetPuzzlesByOwnerRow struct {
@@ -350,11 +1108,17 @@ type GetPuzzlesByOwnerRow struct {
 	Height        int64
 	CreatedAt     time.Time
 	UpdatedAt     sql.NullTime
+	Visibility    string
 	OwnerUsername string
 }
 
 func (q *Queries) GetPuzzlesByOwner(ctx context.Context, arg GetPuzzlesByOwnerParams) ([]GetPuzzlesByOwnerRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPuzzlesByOwner, arg.OwnerID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, getPuzzlesByOwner,
+		arg.OwnerID,
+		arg.ViewerID,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -370,6 +1134,7 @@ func (q *Queries) GetPuzzlesByOwner(ctx context.Context, arg GetPuzzlesByOwnerPa
 			&i.Height,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Visibility,
 			&i.OwnerUsername,
 		); err != nil {
 			return nil, err
@@ -386,7 +1151,7 @@ func (q *Queries) GetPuzzlesByOwner(ctx context.Context, arg GetPuzzlesByOwnerPa
 }
 
 const getPuzzlesFromFollowing = `-- name: GetPuzzlesFromFollowing :many
-SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at FROM puzzles p
+SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, p.visibility FROM puzzles p
 JOIN follows f ON f.followed_id = p.owner_id
 WHERE f.follower_id = ?
 ORDER BY p.created_at DESC LIMIT ? OFFSET ?
@@ -415,6 +1180,7 @@ func (q *Queries) GetPuzzlesFromFollowing(ctx context.Context, arg GetPuzzlesFro
 			&i.Height,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -430,10 +1196,10 @@ func (q *Queries) GetPuzzlesFromFollowing(ctx context.Context, arg GetPuzzlesFro
 }
 
 const getPuzzlesFromFollowingWithUsername = `-- name: GetPuzzlesFromFollowingWithUsername :many
-SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, u.username as owner_username FROM puzzles p
+SELECT p.id, p.owner_id, p.name, p.width, p.height, p.created_at, p.updated_at, p.visibility, u.username as owner_username FROM puzzles p
 JOIN follows f ON f.followed_id = p.owner_id
 JOIN users u ON u.id = p.owner_id
-WHERE f.follower_id = ?
+WHERE f.follower_id = ? AND p.visibility IN ('public', 'followers')
 ORDER BY p.created_at DESC LIMIT ? OFFSET ?
 `
 
@@ -451,27 +1217,314 @@ type GetPuzzlesFromFollowingWithUsernameRow struct {
 	Height        int64
 	CreatedAt     time.Time
 	UpdatedAt     sql.NullTime
+	Visibility    string
 	OwnerUsername string
 }
 
-func (q *Queries) GetPuzzlesFromFollowingWithUsername(ctx context.Context, arg GetPuzzlesFromFollowingWithUsernameParams) ([]GetPuzzlesFromFollowingWithUsernameRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPuzzlesFromFollowingWithUsername, arg.FollowerID, arg.Limit, arg.Offset)
+func (q *Queries) GetPuzzlesFromFollowingWithUsername(ctx context.Context, arg GetPuzzlesFromFollowingWithUsernameParams) ([]GetPuzzlesFromFollowingWithUsernameRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPuzzlesFromFollowingWithUsername, arg.FollowerID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPuzzlesFromFollowingWithUsernameRow
+	for rows.Next() {
+		var i GetPuzzlesFromFollowingWithUsernameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Width,
+			&i.Height,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Visibility,
+			&i.OwnerUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPuzzleSolveChecks = `-- name: GetPuzzleSolveChecks :many
+SELECT solve_checks.id, solve_checks.session_id, solve_checks.x, solve_checks.y, solve_checks.wrong, solve_checks.elapsed_ms, solve_checks.checked_at FROM solve_checks
+JOIN solve_sessions ON solve_sessions.id = solve_checks.session_id
+WHERE solve_sessions.puzzle_id = ?
+ORDER BY solve_checks.session_id, solve_checks.id
+`
+
+func (q *Queries) GetPuzzleSolveChecks(ctx context.Context, puzzleID string) ([]SolveCheck, error) {
+	rows, err := q.db.QueryContext(ctx, getPuzzleSolveChecks, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SolveCheck
+	for rows.Next() {
+		var i SolveCheck
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.X,
+			&i.Y,
+			&i.Wrong,
+			&i.ElapsedMs,
+			&i.CheckedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPuzzleSolveEntries = `-- name: GetPuzzleSolveEntries :many
+SELECT solve_entries.id, solve_entries.session_id, solve_entries.x, solve_entries.y, solve_entries.char, solve_entries.is_pencil, solve_entries.revealed, solve_entries.user_id, solve_entries.elapsed_ms, solve_entries.entered_at FROM solve_entries
+JOIN solve_sessions ON solve_sessions.id = solve_entries.session_id
+WHERE solve_sessions.puzzle_id = ?
+ORDER BY solve_entries.session_id, solve_entries.id
+`
+
+func (q *Queries) GetPuzzleSolveEntries(ctx context.Context, puzzleID string) ([]SolveEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getPuzzleSolveEntries, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SolveEntry
+	for rows.Next() {
+		var i SolveEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.X,
+			&i.Y,
+			&i.Char,
+			&i.IsPencil,
+			&i.Revealed,
+			&i.UserID,
+			&i.ElapsedMs,
+			&i.EnteredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPuzzleVersion = `-- name: GetPuzzleVersion :one
+SELECT id, puzzle_id, name, created_by, state, created_at FROM puzzle_versions WHERE id = ? AND puzzle_id = ?
+`
+
+type GetPuzzleVersionParams struct {
+	ID       string
+	PuzzleID string
+}
+
+func (q *Queries) GetPuzzleVersion(ctx context.Context, arg GetPuzzleVersionParams) (PuzzleVersion, error) {
+	row := q.db.QueryRowContext(ctx, getPuzzleVersion, arg.ID, arg.PuzzleID)
+	var i PuzzleVersion
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.State,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPuzzleVersions = `-- name: GetPuzzleVersions :many
+SELECT v.id, v.puzzle_id, v.name, v.created_by, v.created_at, u.username AS created_by_username
+FROM puzzle_versions v
+JOIN users u ON u.id = v.created_by
+WHERE v.puzzle_id = ?
+ORDER BY v.created_at DESC, v.rowid DESC
+`
+
+type GetPuzzleVersionsRow struct {
+	ID                string
+	PuzzleID          string
+	Name              string
+	CreatedBy         string
+	CreatedAt         time.Time
+	CreatedByUsername string
+}
+
+func (q *Queries) GetPuzzleVersions(ctx context.Context, puzzleID string) ([]GetPuzzleVersionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPuzzleVersions, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPuzzleVersionsRow
+	for rows.Next() {
+		var i GetPuzzleVersionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PuzzleID,
+			&i.Name,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSessionCells = `-- name: GetSessionCells :many
+SELECT session_id, x, y, char, is_wrong, revealed, is_pencil, entered_by, entered_at FROM session_cells WHERE session_id = ? ORDER BY y, x
+`
+
+func (q *Queries) GetSessionCells(ctx context.Context, sessionID string) ([]SessionCell, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionCells, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SessionCell
+	for rows.Next() {
+		var i SessionCell
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.X,
+			&i.Y,
+			&i.Char,
+			&i.IsWrong,
+			&i.Revealed,
+			&i.IsPencil,
+			&i.EnteredBy,
+			&i.EnteredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSolveEntries = `-- name: GetSolveEntries :many
+SELECT id, session_id, x, y, char, is_pencil, revealed, user_id, elapsed_ms, entered_at FROM solve_entries WHERE session_id = ? ORDER BY id
+`
+
+func (q *Queries) GetSolveEntries(ctx context.Context, sessionID string) ([]SolveEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getSolveEntries, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SolveEntry
+	for rows.Next() {
+		var i SolveEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.X,
+			&i.Y,
+			&i.Char,
+			&i.IsPencil,
+			&i.Revealed,
+			&i.UserID,
+			&i.ElapsedMs,
+			&i.EnteredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSolveHistory = `-- name: GetSolveHistory :many
+SELECT s.puzzle_id, s.elapsed_ms, s.completed_at, p.width, p.height,
+  EXISTS (SELECT 1 FROM solve_checks c WHERE c.session_id = s.id) AS checked,
+  EXISTS (SELECT 1 FROM solve_entries e WHERE e.session_id = s.id AND e.revealed) AS revealed
+FROM solve_sessions s
+JOIN puzzles p ON p.id = s.puzzle_id
+WHERE s.completed_at IS NOT NULL
+  AND (s.owner_id = ?1 OR EXISTS (
+    SELECT 1 FROM solve_entries e
+    WHERE e.session_id = s.id AND e.user_id = ?1 AND e.char != '' AND NOT e.revealed
+  ))
+  AND (?1 = ?2 OR p.visibility = 'public')
+ORDER BY s.completed_at
+`
+
+type GetSolveHistoryParams struct {
+	UserID   string
+	ViewerID string
+}
+
+type GetSolveHistoryRow struct {
+	PuzzleID    string
+	ElapsedMs   int64
+	CompletedAt sql.NullTime
+	Width       int64
+	Height      int64
+	Checked     int64
+	Revealed    int64
+}
+
+func (q *Queries) GetSolveHistory(ctx context.Context, arg GetSolveHistoryParams) ([]GetSolveHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSolveHistory, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetPuzzlesFromFollowingWithUsernameRow
+	var items []GetSolveHistoryRow
 	for rows.Next() {
-		var i GetPuzzlesFromFollowingWithUsernameRow
+		var i GetSolveHistoryRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.OwnerID,
-			&i.Name,
+			&i.PuzzleID,
+			&i.ElapsedMs,
+			&i.CompletedAt,
 			&i.Width,
 			&i.Height,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.OwnerUsername,
+			&i.Checked,
+			&i.Revealed,
 		); err != nil {
 			return nil, err
 		}
@@ -486,6 +1539,44 @@ func (q *Queries) GetPuzzlesFromFollowingWithUsername(ctx context.Context, arg G
 	return items, nil
 }
 
+const getSolveSession = `-- name: GetSolveSession :one
+SELECT id, puzzle_id, owner_id, kind, created_at, updated_at, autocheck, elapsed_ms, running_since, completed_at FROM solve_sessions WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetSolveSession(ctx context.Context, id string) (SolveSession, error) {
+	row := q.db.QueryRowContext(ctx, getSolveSession, id)
+	var i SolveSession
+	err := row.Scan(
+		&i.ID,
+		&i.PuzzleID,
+		&i.OwnerID,
+		&i.Kind,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Autocheck,
+		&i.ElapsedMs,
+		&i.RunningSince,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getTabState = `-- name: GetTabState :one
+SELECT value FROM tab_state WHERE tab_key = ? AND field = ?
+`
+
+type GetTabStateParams struct {
+	TabKey string
+	Field  string
+}
+
+func (q *Queries) GetTabState(ctx context.Context, arg GetTabStateParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getTabState, arg.TabKey, arg.Field)
+	var value string
+	err := row.Scan(&value)
+	return value, err
+}
+
 const getUser = `-- name: GetUser :one
 SELECT id, username, password_hash, created_at FROM users WHERE id = ? LIMIT 1
 `
@@ -519,13 +1610,17 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 }
 
 const importCell = `-- name: ImportCell :exec
-INSERT INTO cells (puzzle_id, x, y, char, is_block, is_pencil, solution)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO cells (puzzle_id, x, y, char, is_block, is_pencil, solution, circled, shaded, color, bars)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(puzzle_id, x, y) DO UPDATE SET
     char = excluded.char,
     solution = excluded.solution,
     is_block = excluded.is_block,
-    is_pencil = excluded.is_pencil
+    is_pencil = excluded.is_pencil,
+    circled = excluded.circled,
+    shaded = excluded.shaded,
+    color = excluded.color,
+    bars = excluded.bars
 `
 
 type ImportCellParams struct {
@@ -536,6 +1631,10 @@ type ImportCellParams struct {
 	IsBlock  bool
 	IsPencil bool
 	Solution string
+	Circled  bool
+	Shaded   bool
+	Color    string
+	Bars     string
 }
 
 func (q *Queries) ImportCell(ctx context.Context, arg ImportCellParams) error {
@@ -547,6 +1646,10 @@ func (q *Queries) ImportCell(ctx context.Context, arg ImportCellParams) error {
 		arg.IsBlock,
 		arg.IsPencil,
 		arg.Solution,
+		arg.Circled,
+		arg.Shaded,
+		arg.Color,
+		arg.Bars,
 	)
 	return err
 }
@@ -570,9 +1673,266 @@ func (q *Queries) IsFollowing(ctx context.Context, arg IsFollowingParams) (int64
 	return column_1, err
 }
 
+const joinTab = `-- name: JoinTab :exec
+INSERT INTO tab_presence (tab_key, instance_id, puzzle_id, session_id, mode, user_id, username, color, seen_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (tab_key, instance_id) DO UPDATE SET
+    puzzle_id = excluded.puzzle_id,
+    session_id = excluded.session_id,
+    mode = excluded.mode,
+    user_id = excluded.user_id,
+    username = excluded.username,
+    color = excluded.color,
+    seen_at = excluded.seen_at
+`
+
+type JoinTabParams struct {
+	TabKey     string
+	InstanceID string
+	PuzzleID   string
+	SessionID  string
+	Mode       string
+	UserID     string
+	Username   string
+	Color      string
+	SeenAt     int64
+}
+
+func (q *Queries) JoinTab(ctx context.Context, arg JoinTabParams) error {
+	_, err := q.db.ExecContext(ctx, joinTab,
+		arg.TabKey,
+		arg.InstanceID,
+		arg.PuzzleID,
+		arg.SessionID,
+		arg.Mode,
+		arg.UserID,
+		arg.Username,
+		arg.Color,
+		arg.SeenAt,
+	)
+	return err
+}
+
+const leaveTab = `-- name: LeaveTab :exec
+DELETE FROM tab_presence WHERE tab_key = ? AND instance_id = ?
+`
+
+type LeaveTabParams struct {
+	TabKey     string
+	InstanceID string
+}
+
+func (q *Queries) LeaveTab(ctx context.Context, arg LeaveTabParams) error {
+	_, err := q.db.ExecContext(ctx, leaveTab, arg.TabKey, arg.InstanceID)
+	return err
+}
+
+const listTabsOn = `-- name: ListTabsOn :many
+SELECT tab_key, instance_id, puzzle_id, session_id, mode, user_id, username, color, seen_at FROM tab_presence
+WHERE puzzle_id = ?1 AND mode = ?2 AND session_id = ?3
+  AND seen_at > ?4
+`
+
+type ListTabsOnParams struct {
+	PuzzleID  string
+	Mode      string
+	SessionID string
+	SeenSince int64
+}
+
+func (q *Queries) ListTabsOn(ctx context.Context, arg ListTabsOnParams) ([]TabPresence, error) {
+	rows, err := q.db.QueryContext(ctx, listTabsOn,
+		arg.PuzzleID,
+		arg.Mode,
+		arg.SessionID,
+		arg.SeenSince,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TabPresence
+	for rows.Next() {
+		var i TabPresence
+		if err := rows.Scan(
+			&i.TabKey,
+			&i.InstanceID,
+			&i.PuzzleID,
+			&i.SessionID,
+			&i.Mode,
+			&i.UserID,
+			&i.Username,
+			&i.Color,
+			&i.SeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTabState = `-- name: ListTabState :many
+SELECT tab_key, value FROM tab_state WHERE field = ?
+`
+
+type ListTabStateRow struct {
+	TabKey string
+	Value  string
+}
+
+func (q *Queries) ListTabState(ctx context.Context, field string) ([]ListTabStateRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTabState, field)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTabStateRow
+	for rows.Next() {
+		var i ListTabStateRow
+		if err := rows.Scan(&i.TabKey, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pauseSolveSessionTimer = `-- name: PauseSolveSessionTimer :exec
+UPDATE solve_sessions SET elapsed_ms = ?, running_since = NULL
+WHERE id = ? AND running_since IS NOT NULL
+`
+
+type PauseSolveSessionTimerParams struct {
+	ElapsedMs int64
+	ID        string
+}
+
+func (q *Queries) PauseSolveSessionTimer(ctx context.Context, arg PauseSolveSessionTimerParams) error {
+	_, err := q.db.ExecContext(ctx, pauseSolveSessionTimer, arg.ElapsedMs, arg.ID)
+	return err
+}
+
+const revealSessionCell = `-- name: RevealSessionCell :exec
+INSERT INTO session_cells (session_id, x, y, char, revealed)
+VALUES (?, ?, ?, ?, TRUE)
+ON CONFLICT(session_id, x, y) DO UPDATE SET
+    char = excluded.char,
+    is_wrong = FALSE,
+    is_pencil = FALSE,
+    revealed = TRUE,
+    entered_by = ''
+`
+
+type RevealSessionCellParams struct {
+	SessionID string
+	X         int64
+	Y         int64
+	Char      string
+}
+
+func (q *Queries) RevealSessionCell(ctx context.Context, arg RevealSessionCellParams) error {
+	_, err := q.db.ExecContext(ctx, revealSessionCell,
+		arg.SessionID,
+		arg.X,
+		arg.Y,
+		arg.Char,
+	)
+	return err
+}
+
+const setPuzzleEditUndone = `-- name: SetPuzzleEditUndone :exec
+UPDATE puzzle_edits SET undone = ? WHERE id = ?
+`
+
+type SetPuzzleEditUndoneParams struct {
+	Undone bool
+	ID     int64
+}
+
+func (q *Queries) SetPuzzleEditUndone(ctx context.Context, arg SetPuzzleEditUndoneParams) error {
+	_, err := q.db.ExecContext(ctx, setPuzzleEditUndone, arg.Undone, arg.ID)
+	return err
+}
+
+const setSessionCellWrong = `-- name: SetSessionCellWrong :exec
+UPDATE session_cells SET is_wrong = ?
+WHERE session_id = ? AND x = ? AND y = ?
+`
+
+type SetSessionCellWrongParams struct {
+	IsWrong   bool
+	SessionID string
+	X         int64
+	Y         int64
+}
+
+func (q *Queries) SetSessionCellWrong(ctx context.Context, arg SetSessionCellWrongParams) error {
+	_, err := q.db.ExecContext(ctx, setSessionCellWrong,
+		arg.IsWrong,
+		arg.SessionID,
+		arg.X,
+		arg.Y,
+	)
+	return err
+}
+
+const setTabState = `-- name: SetTabState :exec
+INSERT INTO tab_state (tab_key, field, value, updated_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (tab_key, field) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+`
+
+type SetTabStateParams struct {
+	TabKey    string
+	Field     string
+	Value     string
+	UpdatedAt int64
+}
+
+func (q *Queries) SetTabState(ctx context.Context, arg SetTabStateParams) error {
+	_, err := q.db.ExecContext(ctx, setTabState,
+		arg.TabKey,
+		arg.Field,
+		arg.Value,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const startSolveSessionTimer = `-- name: StartSolveSessionTimer :execrows
+UPDATE solve_sessions SET running_since = ?
+WHERE id = ? AND running_since IS NULL AND completed_at IS NULL
+`
+
+type StartSolveSessionTimerParams struct {
+	RunningSince sql.NullTime
+	ID           string
+}
+
+func (q *Queries) StartSolveSessionTimer(ctx context.Context, arg StartSolveSessionTimerParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, startSolveSessionTimer, arg.RunningSince, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const toggleBlock = `-- name: ToggleBlock :exec
 UPDATE cells 
-SET is_block = NOT is_block, char = '' 
+SET is_block = NOT is_block, char = '', solution = ''
 WHERE puzzle_id = ? AND x = ? AND y = ?
 `
 
@@ -587,6 +1947,55 @@ func (q *Queries) ToggleBlock(ctx context.Context, arg ToggleBlockParams) error
 	return err
 }
 
+const touchSessionStream = `-- name: TouchSessionStream :exec
+INSERT INTO solve_session_streams (session_id, instance_id, seen_at)
+VALUES (?, ?, ?)
+ON CONFLICT (session_id, instance_id) DO UPDATE SET seen_at = excluded.seen_at
+`
+
+type TouchSessionStreamParams struct {
+	SessionID  string
+	InstanceID string
+	SeenAt     int64
+}
+
+func (q *Queries) TouchSessionStream(ctx context.Context, arg TouchSessionStreamParams) error {
+	_, err := q.db.ExecContext(ctx, touchSessionStream, arg.SessionID, arg.InstanceID, arg.SeenAt)
+	return err
+}
+
+const touchTabs = `-- name: TouchTabs :exec
+UPDATE tab_presence SET seen_at = ? WHERE instance_id = ?
+`
+
+type TouchTabsParams struct {
+	SeenAt     int64
+	InstanceID string
+}
+
+func (q *Queries) TouchTabs(ctx context.Context, arg TouchTabsParams) error {
+	_, err := q.db.ExecContext(ctx, touchTabs, arg.SeenAt, arg.InstanceID)
+	return err
+}
+
+const trimPuzzleEdits = `-- name: TrimPuzzleEdits :exec
+DELETE FROM puzzle_edits
+WHERE puzzle_id = ?1 AND id <= (
+    SELECT id FROM puzzle_edits WHERE puzzle_id = ?1
+    ORDER BY id DESC LIMIT 1 OFFSET ?2
+)
+`
+
+type TrimPuzzleEditsParams struct {
+	PuzzleID string
+	Keep     int64
+}
+
+func (q *Queries) TrimPuzzleEdits(ctx context.Context, arg TrimPuzzleEditsParams) error {
+	_, err := q.db.ExecContext(ctx, trimPuzzleEdits, arg.PuzzleID, arg.Keep)
+	return err
+}
+
 const unfollowUser = `-- name: UnfollowUser :exec
 DELETE FROM follows
 WHERE follower_id = ? AND followed_id = ?
@@ -608,7 +2017,8 @@ VALUES (?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(puzzle_id, x, y) DO UPDATE SET
     char = excluded.char,
     is_block = excluded.is_block,
-    is_pencil = excluded.is_pencil
+    is_pencil = excluded.is_pencil,
+    solution = CASE WHEN excluded.is_block THEN '' ELSE cells.solution END
 `
 
 type UpdateCellParams struct {
@@ -634,6 +2044,56 @@ func (q *Queries) UpdateCell(ctx context.Context, arg UpdateCellParams) error {
 	return err
 }
 
+const updateCellSolution = `-- name: UpdateCellSolution :exec
+UPDATE cells SET solution = ?
+WHERE puzzle_id = ? AND x = ? AND y = ? AND is_block = FALSE
+`
+
+type UpdateCellSolutionParams struct {
+	Solution string
+	PuzzleID string
+	X        int64
+	Y        int64
+}
+
+func (q *Queries) UpdateCellSolution(ctx context.Context, arg UpdateCellSolutionParams) error {
+	_, err := q.db.ExecContext(ctx, updateCellSolution,
+		arg.Solution,
+		arg.PuzzleID,
+		arg.X,
+		arg.Y,
+	)
+	return err
+}
+
+const updateCellStyle = `-- name: UpdateCellStyle :exec
+UPDATE cells SET circled = ?, shaded = ?, color = ?, bars = ?
+WHERE puzzle_id = ? AND x = ? AND y = ?
+`
+
+type UpdateCellStyleParams struct {
+	Circled  bool
+	Shaded   bool
+	Color    string
+	Bars     string
+	PuzzleID string
+	X        int64
+	Y        int64
+}
+
+func (q *Queries) UpdateCellStyle(ctx context.Context, arg UpdateCellStyleParams) error {
+	_, err := q.db.ExecContext(ctx, updateCellStyle,
+		arg.Circled,
+		arg.Shaded,
+		arg.Color,
+		arg.Bars,
+		arg.PuzzleID,
+		arg.X,
+		arg.Y,
+	)
+	return err
+}
+
 const updatePuzzleDimensions = `-- name: UpdatePuzzleDimensions :exec
 UPDATE puzzles SET width = ?, height = ? WHERE id = ?
 `
@@ -649,6 +2109,20 @@ func (q *Queries) UpdatePuzzleDimensions(ctx context.Context, arg UpdatePuzzleDi
 	return err
 }
 
+const updatePuzzleName = `-- name: UpdatePuzzleName :exec
+UPDATE puzzles SET name = ? WHERE id = ?
+`
+
+type UpdatePuzzleNameParams struct {
+	Name string
+	ID   string
+}
+
+func (q *Queries) UpdatePuzzleName(ctx context.Context, arg UpdatePuzzleNameParams) error {
+	_, err := q.db.ExecContext(ctx, updatePuzzleName, arg.Name, arg.ID)
+	return err
+}
+
 const updatePuzzleUpdatedAt = `-- name: UpdatePuzzleUpdatedAt :exec
 UPDATE puzzles SET updated_at = CURRENT_TIMESTAMP WHERE id = ?
 `
@@ -658,6 +2132,43 @@ func (q *Queries) UpdatePuzzleUpdatedAt(ctx context.Context, id string) error {
 	return err
 }
 
+const updatePuzzleVisibility = `-- name: UpdatePuzzleVisibility :exec
+UPDATE puzzles SET visibility = ? WHERE id = ?
+`
+
+type UpdatePuzzleVisibilityParams struct {
+	Visibility string
+	ID         string
+}
+
+func (q *Queries) UpdatePuzzleVisibility(ctx context.Context, arg UpdatePuzzleVisibilityParams) error {
+	_, err := q.db.ExecContext(ctx, updatePuzzleVisibility, arg.Visibility, arg.ID)
+	return err
+}
+
+const updateSolveSessionAutocheck = `-- name: UpdateSolveSessionAutocheck :exec
+UPDATE solve_sessions SET autocheck = ? WHERE id = ?
+`
+
+type UpdateSolveSessionAutocheckParams struct {
+	Autocheck bool
+	ID        string
+}
+
+func (q *Queries) UpdateSolveSessionAutocheck(ctx context.Context, arg UpdateSolveSessionAutocheckParams) error {
+	_, err := q.db.ExecContext(ctx, updateSolveSessionAutocheck, arg.Autocheck, arg.ID)
+	return err
+}
+
+const updateSolveSessionUpdatedAt = `-- name: UpdateSolveSessionUpdatedAt :exec
+UPDATE solve_sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = ?
+`
+
+func (q *Queries) UpdateSolveSessionUpdatedAt(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, updateSolveSessionUpdatedAt, id)
+	return err
+}
+
 const upsertClue = `-- name: UpsertClue :exec
 INSERT INTO clues (puzzle_id, number, direction, text)
 VALUES (?, ?, ?, ?)
@@ -681,3 +2192,59 @@ func (q *Queries) UpsertClue(ctx context.Context, arg UpsertClueParams) error {
 	)
 	return err
 }
+
+const upsertPuzzleMember = `-- name: UpsertPuzzleMember :exec
+INSERT INTO puzzle_members (puzzle_id, user_id, role)
+VALUES (?, ?, ?)
+ON CONFLICT(puzzle_id, user_id) DO UPDATE SET
+    role = excluded.role
+`
+
+type UpsertPuzzleMemberParams struct {
+	PuzzleID string
+	UserID   string
+	Role     string
+}
+
+func (q *Queries) UpsertPuzzleMember(ctx context.Context, arg UpsertPuzzleMemberParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPuzzleMember, arg.PuzzleID, arg.UserID, arg.Role)
+	return err
+}
+
+const upsertSessionCell = `-- name: UpsertSessionCell :execrows
+INSERT INTO session_cells (session_id, x, y, char, is_pencil, entered_by, entered_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(session_id, x, y) DO UPDATE SET
+    char = excluded.char,
+    is_pencil = excluded.is_pencil,
+    is_wrong = FALSE,
+    entered_by = excluded.entered_by,
+    entered_at = excluded.entered_at
+WHERE session_cells.revealed = FALSE
+`
+
+type UpsertSessionCellParams struct {
+	SessionID string
+	X         int64
+	Y         int64
+	Char      string
+	IsPencil  bool
+	EnteredBy string
+	EnteredAt int64
+}
+
+func (q *Queries) UpsertSessionCell(ctx context.Context, arg UpsertSessionCellParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upsertSessionCell,
+		arg.SessionID,
+		arg.X,
+		arg.Y,
+		arg.Char,
+		arg.IsPencil,
+		arg.EnteredBy,
+		arg.EnteredAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"net/http"
+	"share_word/internal/app"
+	"share_word/internal/db"
+	"share_word/internal/web/components"
+	"slices"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) handleViewAnalytics(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	stats, err := s.Service.PuzzleAnalytics(r.Context(), puzzleID, userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	metric := app.HeatMetric(r.URL.Query().Get("heat"))
+	if !slices.Contains(app.HeatMetrics, metric) {
+		metric = app.HeatTime
+	}
+
+	p, err := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "puzzle not found", http.StatusNotFound)
+		return
+	}
+	cells, err := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "failed to load cells", http.StatusInternalServerError)
+		return
+	}
+
+	// The constructor sees the answers the heat is laid over
+	for i := range cells {
+		cells[i].Char = cells[i].Solution
+	}
+	annotated := s.Service.CalculateNumbers(int(p.Width), int(p.Height), cells)
+	clues, err := s.Service.GetFullClues(r.Context(), p.ID, cells)
+	if err != nil {
+		http.Error(w, "failed to load clues", http.StatusInternalServerError)
+		return
+	}
+
+	// Slowest clues first
+	sort.SliceStable(stats.Clues, func(i, j int) bool {
+		return stats.Clues[i].AvgTime() > stats.Clues[j].AvgTime()
+	})
+
+	var currentUser *db.User
+	if userID != "" {
+		currentUser, _ = s.Service.GetUserByID(r.Context(), userID)
+	}
+	components.Layout(components.AnalyticsPage(currentUser, p, annotated, clues, stats, metric), currentUser, false).Render(r.Context(), w)
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"share_word/internal/app"
+	"share_word/internal/web/components"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// handlePostChat posts to the chat of the room the sender is in: their solve
+// session, or the constructors' chat in edit mode.
+func (s *Server) handlePostChat(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+
+	var payload struct {
+		Text      string `json:"chatText"`
+		Mode      string `json:"mode"`
+		SessionID string `json:"sessionID"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := ""
+	if payload.Mode == "edit" {
+		if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+			return
+		}
+	} else {
+		sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+		if !ok {
+			return
+		}
+		sessionID = sess.ID
+	}
+
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	if _, err := s.Service.PostChatMessage(r.Context(), puzzleID, sessionID, userID, payload.Text); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r, datastar.WithCompression())
+	sse.PatchSignals([]byte(`{"chatText": ""}`))
+}
+
+// pushChat sends a stream the latest messages in its room.
+func (s *Server) pushChat(ctx context.Context, sse *datastar.ServerSentEventGenerator, puzzleID, sessionID string) {
+	messages, err := s.Service.GetChatMessages(ctx, puzzleID, sessionID)
+	if err != nil {
+		log.Printf("Failed to load chat for %s: %v", puzzleID, err)
+		return
+	}
+	p, err := s.Service.Queries.GetPuzzle(ctx, puzzleID)
+	if err != nil {
+		return
+	}
+	cells, err := s.Service.Queries.GetCells(ctx, puzzleID)
+	if err != nil {
+		return
+	}
+	clues := s.Service.DeriveClues(int(p.Width), int(p.Height), cells)
+
+	userID := s.SessionManager.GetString(ctx, "userID")
+	sse.PatchElementTempl(components.ChatLog(puzzleID, messages, clues, userID))
+}
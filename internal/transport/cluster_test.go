@@ -0,0 +1,113 @@
+package transport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"share_word/internal/app"
+	"share_word/internal/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// freePort is a TCP port nothing is listening on.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestClusteredInstances(t *testing.T) {
+	// Replicas share one database
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "shared.db")+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	require.NoError(t, err)
+	defer dbConn.Close()
+	goose.SetDialect("sqlite3")
+	require.NoError(t, goose.Up(dbConn, "../../sql/schema/"))
+	queries := db.New(dbConn)
+
+	portA, portB := freePort(t), freePort(t)
+	instance := func(name string, port, peer int) *Server {
+		svc := app.NewServiceWithConfig(queries, dbConn, app.Config{
+			NatsServerName:    name,
+			NatsCluster:       "shareword-test",
+			NatsClusterListen: fmt.Sprintf("127.0.0.1:%d", port),
+			NatsRoutes:        []string{fmt.Sprintf("nats-route://127.0.0.1:%d", peer)},
+		})
+		t.Cleanup(svc.Shutdown)
+		require.NotNil(t, svc.NC)
+		return NewServer(svc, dbConn, false)
+	}
+	a := instance("a", portA, portB)
+	b := instance("b", portB, portA)
+	require.Eventually(t, func() bool {
+		return a.Service.NatsServer.NumRoutes() > 0 && b.Service.NatsServer.NumRoutes() > 0
+	}, 5*time.Second, 10*time.Millisecond, "the instances find each other")
+
+	ctx := context.Background()
+	owner, err := a.Service.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := a.Service.CreatePuzzle(ctx, "Replicated", owner.ID, 5, 5)
+	require.NoError(t, err)
+	// Sessions live in the database, so a login on one works on the other
+	cookie := loginAs(t, a, "owner", "password123456")
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s/edit/stream?clientID=tab1", p.ID), nil).WithContext(streamCtx)
+	req.Header.Set("Cookie", cookie)
+	rr := &lockedRecorder{ResponseRecorder: httptest.NewRecorder()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Router.ServeHTTP(rr, req)
+	}()
+	require.Eventually(t, func() bool {
+		return strings.Contains(rr.String(), `id="puzzle-ui"`)
+	}, 2*time.Second, 5*time.Millisecond, "the stream on b renders the grid")
+	require.NotContains(t, rr.String(), `data-is-block="true"`)
+
+	res := postSignals(a, fmt.Sprintf("/puzzles/%s/cells/0/0/set-block/true", p.ID), cookie, `{"symmetryMode":"none"}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Eventually(t, func() bool {
+		return strings.Contains(rr.String(), `data-is-block="true"`)
+	}, 2*time.Second, 5*time.Millisecond, "an edit on a reaches the stream on b")
+
+	// A second tab, whose requests land on either instance
+	reqA := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s/edit/stream?clientID=tab2", p.ID), nil).WithContext(streamCtx)
+	reqA.Header.Set("Cookie", cookie)
+	rrA := &lockedRecorder{ResponseRecorder: httptest.NewRecorder()}
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		a.Router.ServeHTTP(rrA, reqA)
+	}()
+	require.Eventually(t, func() bool {
+		return strings.Contains(rrA.String(), `id="puzzle-ui"`)
+	}, 2*time.Second, 5*time.Millisecond)
+
+	res = postSignals(a, fmt.Sprintf("/puzzles/%s/cells/2/2/focus", p.ID), cookie, `{"clientID":"tab2"}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Eventually(t, func() bool {
+		return strings.Contains(rr.String(), "peer-focus")
+	}, 2*time.Second, 5*time.Millisecond, "the stream on b shows a cursor placed through a")
+
+	res = postSignals(b, fmt.Sprintf("/puzzles/%s/edit/input", p.ID), cookie, `{"clientID":"tab2","lastKey":"Q"}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	cell, err := queries.GetCell(ctx, db.GetCellParams{PuzzleID: p.ID, X: 2, Y: 2})
+	require.NoError(t, err)
+	require.Equal(t, "Q", cell.Solution, "b types where a put the cursor")
+
+	cancel()
+	<-done
+	<-doneA
+}
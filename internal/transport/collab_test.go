@@ -3,10 +3,20 @@ package transport
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"share_word/internal/app"
+	"share_word/internal/db"
+	"share_word/internal/web/components"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/nats-io/nats.go"
+	"github.com/starfederation/datastar-go/datastar"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,8 +27,9 @@ func TestTabIsolation(t *testing.T) {
 	ctx := context.Background()
 
 	// 1. Setup user and puzzle
-	_, _ = s.Service.RegisterUser(ctx, "tabuser", "pass123")
-	loginBody := `{"username":"tabuser", "password":"pass123"}`
+	user, err := s.Service.RegisterUser(ctx, "tabuser", "password123456")
+	require.NoError(t, err)
+	loginBody := `{"username":"tabuser", "password":"password123456"}`
 	req := httptest.NewRequest("POST", "/login", strings.NewReader(loginBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Datastar-Request", "true")
@@ -26,7 +37,7 @@ func TestTabIsolation(t *testing.T) {
 	s.Router.ServeHTTP(rr, req)
 	cookie := rr.Header().Get("Set-Cookie")
 
-	p, err := s.Service.CreatePuzzle(ctx, "Tab Test", "tabuser", 5, 5)
+	p, err := s.Service.CreatePuzzle(ctx, "Tab Test", user.ID, 5, 5)
 	require.NoError(t, err)
 
 	// 2. Focus cell 0,0 in Tab A
@@ -47,8 +58,7 @@ func TestTabIsolation(t *testing.T) {
 
 	// 4. Verify internal state isolation by scanning the map
 	var valA, valB string
-	s.Service.FocusedCells.Range(func(k, v any) bool {
-		key := k.(string)
+	s.Service.FocusedCells.Range(func(key string, v any) bool {
 		if strings.HasSuffix(key, ":"+tabA) {
 			valA = v.(string)
 		}
@@ -67,18 +77,24 @@ func TestInputSanitization(t *testing.T) {
 	defer cleanup()
 	ctx := context.Background()
 
-	p, err := s.Service.CreatePuzzle(ctx, "Sanity Test", "owner", 5, 5)
+	owner, err := s.Service.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	p, err := s.Service.CreatePuzzle(ctx, "Sanity Test", owner.ID, 5, 5)
 	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
 
 	// Update cell with lowercase long string
 	updateBody := `{"cellValue":"abc"}`
 	req := httptest.NewRequest("POST", fmt.Sprintf("/puzzles/%s/cells/0/0/update", p.ID), strings.NewReader(updateBody))
+	req.Header.Set("Cookie", cookie)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Datastar-Request", "true")
 	s.Router.ServeHTTP(httptest.NewRecorder(), req)
 
 	// Verify it was sanitized to 'C' (last char, uppercase)
-	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	cells, _ := s.Service.GetSolveCells(ctx, p.ID, sess.ID)
 	found := false
 	for _, c := range cells {
 		if c.X == 0 && c.Y == 0 {
@@ -88,3 +104,898 @@ func TestInputSanitization(t *testing.T) {
 	}
 	assert.True(t, found)
 }
+
+func TestSolveSessions(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	s.Service.SkipCooldown = true
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	solver, _ := s.Service.RegisterUser(ctx, "solver", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "watcher", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Rooms", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, "public"))
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	require.NoError(t, s.Service.AddPuzzleMember(ctx, p.ID, owner.ID, "solver", "solver"))
+	solverCookie := loginAs(t, s, "solver", "password123456")
+	watcherCookie := loginAs(t, s, "watcher", "password123456")
+
+	letterAt := func(sessionID string) string {
+		cells, err := s.Service.GetSolveCells(ctx, p.ID, sessionID)
+		require.NoError(t, err)
+		return cells[0].Char
+	}
+	update := fmt.Sprintf("/puzzles/%s/cells/0/0/update", p.ID)
+
+	t.Run("personal sessions do not clobber each other", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, postSignals(s, update, ownerCookie, `{"cellValue":"a"}`).Code)
+		assert.Equal(t, http.StatusOK, postSignals(s, update, solverCookie, `{"cellValue":"b"}`).Code)
+
+		ownerSess, _ := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+		solverSess, _ := s.Service.PersonalSession(ctx, p.ID, solver.ID)
+		assert.NotEqual(t, ownerSess.ID, solverSess.ID)
+		assert.Equal(t, "A", letterAt(ownerSess.ID))
+		assert.Equal(t, "B", letterAt(solverSess.ID))
+
+		cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+		assert.Equal(t, "", cells[0].Char, "the authored grid is untouched")
+
+		body := fmt.Sprintf(`{"cellValue":"z","sessionID":%q}`, ownerSess.ID)
+		assert.Equal(t, http.StatusForbidden, postSignals(s, update, solverCookie, body).Code, "personal sessions are private")
+	})
+
+	t.Run("shared rooms are written by everyone in them", func(t *testing.T) {
+		rr := postSignals(s, fmt.Sprintf("/puzzles/%s/sessions", p.ID), ownerCookie, `{}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		assert.Contains(t, rr.Body.String(), fmt.Sprintf("/puzzles/%s?session=", p.ID))
+
+		body := fmt.Sprintf(`{"cellValue":"c","sessionID":%q}`, room.ID)
+		assert.Equal(t, http.StatusOK, postSignals(s, update, solverCookie, body).Code)
+		assert.Equal(t, "C", letterAt(room.ID))
+		assert.Equal(t, http.StatusForbidden, postSignals(s, update, watcherCookie, body).Code, "viewers may watch but not type")
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s?session=%s", p.ID, room.ID), nil)
+		req.Header.Set("Cookie", watcherCookie)
+		rr = httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), room.ID)
+	})
+
+	t.Run("sessions belong to one puzzle", func(t *testing.T) {
+		other, err := s.Service.CreatePuzzle(ctx, "Elsewhere", owner.ID, 5, 5)
+		require.NoError(t, err)
+		room, err := s.Service.CreateSharedSession(ctx, other.ID, owner.ID)
+		require.NoError(t, err)
+
+		body := fmt.Sprintf(`{"cellValue":"d","sessionID":%q}`, room.ID)
+		assert.Equal(t, http.StatusNotFound, postSignals(s, update, ownerCookie, body).Code)
+	})
+}
+
+func TestCheckAndRevealEndpoints(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Checked", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("../app/testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, s.Service.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, "public"))
+
+	cookie := loginAs(t, s, "owner", "password123456")
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	signals := fmt.Sprintf(`{"clientID":"tab","sessionID":%q}`, sess.ID)
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, signals)
+	require.NoError(t, s.Service.SetSessionCell(ctx, sess.ID, "", 0, 0, "Q", false))
+
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/check/letter", p.ID), cookie, signals)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	_, marks, _ := s.Service.GetSolveState(ctx, p.ID, sess)
+	assert.True(t, marks["0,0"].Wrong)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/reveal/word", p.ID), cookie, signals)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	cells, marks, _ := s.Service.GetSolveState(ctx, p.ID, sess)
+	assert.Equal(t, "A", cells[0].Char)
+	assert.Equal(t, "E", cells[4].Char)
+	assert.True(t, marks["0,0"].Revealed)
+
+	assert.Equal(t, http.StatusBadRequest, postSignals(s, fmt.Sprintf("/puzzles/%s/check/everything", p.ID), cookie, signals).Code)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, fmt.Sprintf("/puzzles/%s/reveal/puzzle", p.ID), "", signals).Code)
+}
+
+func TestAutocheckToggle(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "friend", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Autocheck", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.AddPuzzleMember(ctx, p.ID, owner.ID, "friend", "solver"))
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	friendCookie := loginAs(t, s, "friend", "password123456")
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/autocheck", p.ID), friendCookie, fmt.Sprintf(`{"autocheck":true,"sessionID":%q}`, room.ID))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	room, _ = s.Service.Queries.GetSolveSession(ctx, room.ID)
+	assert.True(t, room.Autocheck, "any solver in the room can turn it on for everyone")
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s?session=%s", p.ID, room.ID), nil)
+	req.Header.Set("Cookie", ownerCookie)
+	rr = httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+	assert.Contains(t, rr.Body.String(), "autocheck: true")
+}
+
+func TestSolveTimerDisplay(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Timed", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("../app/testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, s.Service.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, "public"))
+
+	cookie := loginAs(t, s, "owner", "password123456")
+	get := func(path, cookie string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Cookie", cookie)
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	page := get(fmt.Sprintf("/puzzles/%s", p.ID), cookie)
+	assert.Contains(t, page, `id="solve-timer"`)
+	assert.NotContains(t, page, "solve-timer solved")
+
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	rows := []string{"ABCDE", "F#G#H", "IJKLM", "N#O#P", "QRSTU"}
+	for y, row := range rows {
+		for x, ch := range row {
+			if ch != '#' {
+				require.NoError(t, s.Service.SetSessionCell(ctx, sess.ID, "", int64(x), int64(y), string(ch), false))
+			}
+		}
+	}
+
+	assert.Contains(t, get(fmt.Sprintf("/puzzles/%s", p.ID), cookie), "solve-timer solved")
+
+	profile := get(fmt.Sprintf("/users/%s", owner.ID), "")
+	assert.Contains(t, profile, "Solved")
+	assert.Contains(t, profile, "0:00")
+	assert.Contains(t, profile, `id="solver-stats"`)
+	assert.Contains(t, profile, "5×5")
+	assert.Contains(t, profile, "day streak")
+}
+
+func TestPencilInput(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Pencil", owner.ID, 5, 5)
+	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, `{"clientID":"tab"}`)
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/input", p.ID), cookie, `{"clientID":"tab","lastKey":"a","pencil":true}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	cells, _ := s.Service.GetSolveCells(ctx, p.ID, sess.ID)
+	assert.Equal(t, "A", cells[0].Char)
+	assert.True(t, cells[0].IsPencil)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s", p.ID), nil)
+	req.Header.Set("Cookie", cookie)
+	page := httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.Contains(t, page.Body.String(), "cell-pencil")
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, `{"clientID":"tab"}`)
+	postSignals(s, fmt.Sprintf("/puzzles/%s/input", p.ID), cookie, `{"clientID":"tab","lastKey":"b","pencil":false}`)
+	cells, _ = s.Service.GetSolveCells(ctx, p.ID, sess.ID)
+	assert.Equal(t, "B", cells[0].Char)
+	assert.False(t, cells[0].IsPencil, "writing in pen inks the cell in")
+}
+
+func TestRebusInput(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Rebus", owner.ID, 5, 5)
+	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, `{"clientID":"tab"}`)
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/input", p.ID), cookie, `{"clientID":"tab","lastKey":"Rebus","rebusBuffer":"heart"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/cells/2/0/update", p.ID), cookie, `{"clientID":"tab","cellValue":"star","rebusMode":true}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	cells, _ := s.Service.GetSolveCells(ctx, p.ID, sess.ID)
+	assert.Equal(t, "HEART", cells[0].Char)
+	assert.Equal(t, "STAR", cells[2].Char)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s", p.ID), nil)
+	req.Header.Set("Cookie", cookie)
+	page := httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.Contains(t, page.Body.String(), "--rebus-len: 5;")
+}
+
+func TestCellStyleEndpoint(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	s.Service.RegisterUser(ctx, "stranger", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Styled", owner.ID, 5, 5)
+	require.NoError(t, err)
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/cells/1/1/style/circle", p.ID), strangerCookie, `{}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/cells/1/1/style/sparkle", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/cells/9/9/style/circle", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/cells/1/1/style/circle", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/cells/1/1/style/color", p.ID), ownerCookie, `{"editColor":"#fde68a"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s", p.ID), nil)
+	req.Header.Set("Cookie", ownerCookie)
+	page := httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.Contains(t, page.Body.String(), "cell-circle")
+	assert.Contains(t, page.Body.String(), "--cell-color: #fde68a;")
+}
+
+func TestEditInputWritesSolution(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	solver, _ := s.Service.RegisterUser(ctx, "solver", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Authored", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.AddPuzzleMember(ctx, p.ID, owner.ID, solver.Username, app.RoleSolver))
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	solverCookie := loginAs(t, s, "solver", "password123456")
+
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/edit/input", p.ID), solverCookie, `{"clientID":"tab","lastKey":"A"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), ownerCookie, `{"clientID":"tab"}`)
+	for _, key := range []string{"c", "a", "t", "Backspace", "Backspace", "o", "w"} {
+		rr = postSignals(s, fmt.Sprintf("/puzzles/%s/edit/input", p.ID), ownerCookie, fmt.Sprintf(`{"clientID":"tab","lastKey":%q}`, key))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	assert.Equal(t, "C", cells[0].Solution)
+	assert.Equal(t, "O", cells[1].Solution)
+	assert.Equal(t, "W", cells[2].Solution)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s/edit", p.ID), nil)
+	req.Header.Set("Cookie", ownerCookie)
+	page := httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.Contains(t, page.Body.String(), `<span class="clue-answer">COW__</span>`)
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s", p.ID), nil)
+	req.Header.Set("Cookie", solverCookie)
+	page = httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.NotContains(t, page.Body.String(), "clue-answer", "solvers never see the key")
+}
+
+func TestEditInputIgnoresEmptyRebus(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Authored", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.SetCellSolution(ctx, p.ID, 0, 0, "C"))
+	cookie := loginAs(t, s, "owner", "password123456")
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, `{"clientID":"tab"}`)
+	for _, buffer := range []string{"!!", " "} {
+		rr := postSignals(s, fmt.Sprintf("/puzzles/%s/edit/input", p.ID), cookie, fmt.Sprintf(`{"clientID":"tab","lastKey":"Rebus","rebusBuffer":%q}`, buffer))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/edit/input", p.ID), cookie, `{"clientID":"tab","lastKey":"?"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	assert.Equal(t, "C", cells[0].Solution, "nothing typable was entered")
+}
+
+func TestUndoRedoEndpoints(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	s.Service.RegisterUser(ctx, "stranger", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "History", owner.ID, 5, 5)
+	require.NoError(t, err)
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/set-block/true", p.ID), ownerCookie, `{"symmetryMode":"rotational"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/undo", p.ID), strangerCookie, `{}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/undo", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	assert.False(t, cells[0].IsBlock)
+	assert.False(t, cells[24].IsBlock)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/redo", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	cells, _ = s.Service.Queries.GetCells(ctx, p.ID)
+	assert.True(t, cells[0].IsBlock)
+	assert.True(t, cells[24].IsBlock)
+
+	// Running out of history is fine
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/redo", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestVersionEndpoints(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	s.Service.RegisterUser(ctx, "stranger", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Versions", owner.ID, 5, 5)
+	require.NoError(t, err)
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+
+	rr := postSignals(s, fmt.Sprintf("/puzzles/%s/versions", p.ID), strangerCookie, `{"versionName":"Mine"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/versions", p.ID), ownerCookie, `{"versionName":"Open grid"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Open grid")
+
+	versions, _ := s.Service.GetVersions(ctx, p.ID)
+	require.Len(t, versions, 1)
+
+	postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/set-block/true", p.ID), ownerCookie, `{"symmetryMode":"none"}`)
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/versions/diff", p.ID), ownerCookie, fmt.Sprintf(`{"diffFrom":%q,"diffTo":"current"}`, versions[0].ID))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "1 squares changed")
+
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/versions/%s/restore", p.ID, versions[0].ID), strangerCookie, `{}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/versions/nope/restore", p.ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/versions/%s/restore", p.ID, versions[0].ID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	assert.False(t, cells[0].IsBlock)
+}
+
+func TestStreamPresenceLifecycle(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Arrivals", owner.ID, 5, 5)
+	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s/stream?clientID=tab1", p.ID), nil).WithContext(streamCtx)
+	req.Header.Set("Cookie", cookie)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(s.Service.PresentOn(p.ID, "solve", "")) == 1
+	}, time.Second, 5*time.Millisecond, "connecting joins the registry")
+	assert.Equal(t, "owner", s.Service.PresentOn(p.ID, "solve", "")[0].Username)
+
+	// Set directly rather than through the focus endpoint, which would race
+	// the stream's first push for the in-memory database
+	key := s.Service.PresentOn(p.ID, "solve", "")[0].Key
+	s.Service.FocusedCells.Store(key, "1,1")
+	s.Service.CurrentDirections.Store(key, app.DirectionDown)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not close")
+	}
+	assert.Empty(t, s.Service.PresentOn(p.ID, "solve", ""), "disconnecting leaves")
+	_, ok := s.Service.FocusedCells.Load(key)
+	assert.False(t, ok, "the tab's cursor is forgotten")
+	_, ok = s.Service.CurrentDirections.Load(key)
+	assert.False(t, ok)
+}
+
+func TestChatEndpoint(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "solver", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "watcher", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Talk", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, "public"))
+	require.NoError(t, s.Service.AddPuzzleMember(ctx, p.ID, owner.ID, "solver", "solver"))
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	solverCookie := loginAs(t, s, "solver", "password123456")
+	watcherCookie := loginAs(t, s, "watcher", "password123456")
+	chat := fmt.Sprintf("/puzzles/%s/chat", p.ID)
+
+	t.Run("solvers in a room can post", func(t *testing.T) {
+		body := fmt.Sprintf(`{"chatText":"stuck on 6A","sessionID":%q}`, room.ID)
+		rr := postSignals(s, chat, solverCookie, body)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"chatText": ""`)
+
+		msgs, err := s.Service.GetChatMessages(ctx, p.ID, room.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "solver", msgs[0].Username)
+	})
+
+	t.Run("viewers and guests can't post", func(t *testing.T) {
+		body := fmt.Sprintf(`{"chatText":"hi","sessionID":%q}`, room.ID)
+		assert.Equal(t, http.StatusForbidden, postSignals(s, chat, watcherCookie, body).Code)
+		assert.Equal(t, http.StatusForbidden, postSignals(s, chat, "", `{"chatText":"hi"}`).Code)
+	})
+
+	t.Run("edit mode chat is for constructors", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, postSignals(s, chat, solverCookie, `{"chatText":"hi","mode":"edit"}`).Code)
+		assert.Equal(t, http.StatusOK, postSignals(s, chat, ownerCookie, `{"chatText":"fix 2D","mode":"edit"}`).Code)
+
+		msgs, err := s.Service.GetChatMessages(ctx, p.ID, "")
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "fix 2D", msgs[0].Body)
+	})
+
+	t.Run("empty messages are rejected", func(t *testing.T) {
+		body := fmt.Sprintf(`{"chatText":"  ","sessionID":%q}`, room.ID)
+		assert.Equal(t, http.StatusBadRequest, postSignals(s, chat, solverCookie, body).Code)
+	})
+
+	t.Run("clue references link to the clue", func(t *testing.T) {
+		msgs, err := s.Service.GetChatMessages(ctx, p.ID, room.ID)
+		require.NoError(t, err)
+		cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+		clues := s.Service.DeriveClues(5, 5, cells)
+
+		var sb strings.Builder
+		require.NoError(t, components.ChatLog(p.ID, msgs, clues, "").Render(ctx, &sb))
+		assert.Contains(t, sb.String(), fmt.Sprintf("/puzzles/%s/clues/6/across/focus", p.ID))
+
+		focus := fmt.Sprintf("/puzzles/%s/clues/6/across/focus", p.ID)
+		body := fmt.Sprintf(`{"clientID":"tab1","sessionID":%q}`, room.ID)
+		require.Equal(t, http.StatusOK, postSignals(s, focus, solverCookie, body).Code)
+		var focused string
+		s.Service.FocusedCells.Range(func(key string, v any) bool {
+			if strings.HasSuffix(key, ":tab1") {
+				focused = v.(string)
+			}
+			return true
+		})
+		assert.Equal(t, "0,1", focused)
+
+		missing := fmt.Sprintf("/puzzles/%s/clues/6/down/focus", p.ID)
+		assert.Equal(t, http.StatusNotFound, postSignals(s, missing, solverCookie, body).Code)
+	})
+
+	t.Run("cursors only go to sessions the tab can watch", func(t *testing.T) {
+		personal, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+		require.NoError(t, err)
+		body := fmt.Sprintf(`{"clientID":"tab2","sessionID":%q}`, personal.ID)
+		assert.Equal(t, http.StatusForbidden, postSignals(s, fmt.Sprintf("/puzzles/%s/clues/6/across/focus", p.ID), solverCookie, body).Code)
+		assert.Equal(t, http.StatusForbidden, postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), solverCookie, body).Code)
+		assert.Equal(t, http.StatusOK, postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), ownerCookie, body).Code)
+	})
+}
+
+func TestLetterAttribution(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	solver, _ := s.Service.RegisterUser(ctx, "solver", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Credit", owner.ID, 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.AddPuzzleMember(ctx, p.ID, owner.ID, "solver", "solver"))
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	solverCookie := loginAs(t, s, "solver", "password123456")
+
+	update := fmt.Sprintf("/puzzles/%s/cells/0/0/update", p.ID)
+	body := fmt.Sprintf(`{"cellValue":"a","sessionID":%q}`, room.ID)
+	require.Equal(t, http.StatusOK, postSignals(s, update, ownerCookie, body).Code)
+
+	focus := fmt.Sprintf("/puzzles/%s/cells/1/0/focus", p.ID)
+	body = fmt.Sprintf(`{"clientID":"tab1","sessionID":%q}`, room.ID)
+	require.Equal(t, http.StatusOK, postSignals(s, focus, solverCookie, body).Code)
+	input := fmt.Sprintf("/puzzles/%s/input", p.ID)
+	body = fmt.Sprintf(`{"lastKey":"b","clientID":"tab1","sessionID":%q}`, room.ID)
+	require.Equal(t, http.StatusOK, postSignals(s, input, solverCookie, body).Code)
+
+	_, marks, err := s.Service.GetSolveState(ctx, p.ID, room)
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID, marks["0,0"].EnteredBy, "typed into a square directly")
+	assert.Equal(t, solver.ID, marks["1,0"].EnteredBy, "typed at the cursor")
+}
+
+func TestEventsCarryChanges(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Precise", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
+
+	events := make(chan *nats.Msg, 16)
+	for _, subject := range []string{"puzzles." + p.ID, app.SessionSubject(p.ID, room.ID)} {
+		sub, err := s.Service.NC.ChanSubscribe(subject, events)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+	}
+	next := func() app.Event {
+		select {
+		case msg := <-events:
+			e, err := app.ParseEvent(msg.Data)
+			require.NoError(t, err)
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+			return app.Event{}
+		}
+	}
+
+	t.Run("symmetric blocks arrive as one change", func(t *testing.T) {
+		path := fmt.Sprintf("/puzzles/%s/cells/0/1/set-block/true", p.ID)
+		require.Equal(t, http.StatusOK, postSignals(s, path, cookie, `{"symmetryMode":"rotational"}`).Code)
+		e := next()
+		assert.Equal(t, app.EventCellsChanged, e.Type)
+		assert.Equal(t, owner.ID, e.UserID)
+		assert.Equal(t, []app.EventCell{{X: 0, Y: 1, IsBlock: true}, {X: 4, Y: 3, IsBlock: true}}, e.Cells)
+	})
+
+	t.Run("typing carries the letter and the moved cursor", func(t *testing.T) {
+		signals := fmt.Sprintf(`{"clientID":"tab","sessionID":%q}`, room.ID)
+		require.Equal(t, http.StatusOK, postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/focus", p.ID), cookie, signals).Code)
+		e := next()
+		assert.Equal(t, app.EventCursorMoved, e.Type)
+		assert.Equal(t, room.ID, e.SessionID)
+
+		signals = fmt.Sprintf(`{"lastKey":"q","clientID":"tab","sessionID":%q}`, room.ID)
+		require.Equal(t, http.StatusOK, postSignals(s, fmt.Sprintf("/puzzles/%s/input", p.ID), cookie, signals).Code)
+		e = next()
+		assert.Equal(t, app.EventLettersChanged, e.Type)
+		assert.Equal(t, []app.EventLetter{{X: 0, Y: 0, Char: "Q", EnteredBy: owner.ID}}, e.Letters)
+		assert.Equal(t, &app.EventCursor{ClientID: "tab", X: 1, Y: 0, Direction: app.DirectionAcross}, e.Cursor)
+	})
+}
+
+func TestIncrementalPush(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	owner, _ := s.Service.RegisterUser(context.Background(), "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(context.Background(), "Patched", owner.ID, 5, 5)
+	require.NoError(t, err)
+	ctx, err := s.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	s.SessionManager.Put(ctx, "userID", owner.ID)
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+
+	var view gridView
+	push := func() string {
+		rr := httptest.NewRecorder()
+		sse := datastar.NewSSE(rr, httptest.NewRequest("GET", "/", nil))
+		s.pushPuzzleState(ctx, sse, p.ID, "solve", "tab", sess.ID, app.RoleOwner, -1, &view)
+		return rr.Body.String()
+	}
+
+	first := push()
+	assert.Contains(t, first, `id="puzzle-ui"`, "the first push renders everything")
+	assert.NotContains(t, push(), `class="cell`, "nothing changed, so no squares are patched")
+
+	s.Service.FocusedCells.Store(s.SessionManager.Token(ctx)+":tab", "0,0")
+	require.NoError(t, s.Service.SetSessionCell(ctx, sess.ID, owner.ID, 2, 2, "Q", false))
+	s.Service.PublishLetters(ctx, app.Event{Type: app.EventLettersChanged, PuzzleID: p.ID, SessionID: sess.ID}, []app.Point{{X: 2, Y: 2}})
+	patch := push()
+	assert.NotContains(t, patch, `id="puzzle-ui"`)
+	for _, id := range []string{"cell-0-0", "cell-4-0", "cell-2-2", "clue-li-1-across"} {
+		assert.Contains(t, patch, fmt.Sprintf(`id="%s"`, id))
+	}
+	for _, id := range []string{"cell-0-1", "cell-4-4", "clue-li-1-down"} {
+		assert.NotContains(t, patch, fmt.Sprintf(`id="%s"`, id))
+	}
+
+	require.NoError(t, s.Service.SetBlocks(ctx, p.ID, []app.Point{{X: 0, Y: 0}}, true))
+	s.Service.PublishCellsChanged(ctx, p.ID, owner.ID, []app.Point{{X: 0, Y: 0}})
+	patch = push()
+	assert.Contains(t, patch, `id="clue-sidebar"`, "clues that come and go redraw the list")
+	assert.NotContains(t, patch, `id="puzzle-ui"`)
+
+	require.NoError(t, s.Service.ResizePuzzle(ctx, p.ID, 6, 5))
+	s.Service.PublishGridReplaced(ctx, app.EventResized, p.ID, owner.ID)
+	assert.Contains(t, push(), `id="puzzle-ui"`, "a new size redraws the grid")
+}
+
+// lockedRecorder is a ResponseRecorder a test can read while a stream is
+// still writing to it.
+type lockedRecorder struct {
+	mu sync.Mutex
+	*httptest.ResponseRecorder
+}
+
+func (r *lockedRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *lockedRecorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ResponseRecorder.Flush()
+}
+
+func (r *lockedRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Body.String()
+}
+
+func TestStreamResume(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Flaky", owner.ID, 5, 5)
+	require.NoError(t, err)
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	cookie := loginAs(t, s, "owner", "password123456")
+
+	moveCursor := func(x, y int64, dir app.Direction) {
+		s.Service.Publish(app.Event{Type: app.EventCursorMoved, PuzzleID: p.ID, SessionID: room.ID, Cursor: &app.EventCursor{ClientID: "peer", X: x, Y: y, Direction: dir}})
+	}
+	moveCursor(4, 0, app.DirectionAcross)
+	seen, err := s.Service.LatestEventSeq(ctx)
+	require.NoError(t, err)
+	require.NotZero(t, seen)
+
+	// Missed while the tab was away
+	require.NoError(t, s.Service.SetSessionCell(ctx, room.ID, owner.ID, 2, 2, "Q", false))
+	s.Service.PublishLetters(ctx, app.Event{Type: app.EventLettersChanged, PuzzleID: p.ID, SessionID: room.ID}, []app.Point{{X: 2, Y: 2}})
+	moveCursor(0, 4, app.DirectionDown)
+	latest, err := s.Service.LatestEventSeq(ctx)
+	require.NoError(t, err)
+
+	// stream connects, waits for its grid to be tagged with an event id and
+	// returns what it sent
+	stream := func(lastEventID string) string {
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s/stream?clientID=tab1&session=%s", p.ID, room.ID), nil).WithContext(streamCtx)
+		req.Header.Set("Cookie", cookie)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		rr := &lockedRecorder{ResponseRecorder: httptest.NewRecorder()}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Router.ServeHTTP(rr, req)
+		}()
+		require.Eventually(t, func() bool {
+			return strings.Contains(rr.String(), "\nid: ") || strings.HasPrefix(rr.String(), "id: ")
+		}, 2*time.Second, 5*time.Millisecond, "the grid is tagged with the events it reflects")
+		cancel()
+		<-done
+		return rr.String()
+	}
+
+	t.Run("a fresh stream renders everything", func(t *testing.T) {
+		body := stream("")
+		assert.Contains(t, body, `id="puzzle-ui"`)
+		var tagged uint64
+		_, err := fmt.Sscanf(body[strings.Index(body, "\nid: ")+1:], "id: %d", &tagged)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, tagged, latest, "including its own arrival")
+	})
+
+	t.Run("a resumed stream patches only what it missed", func(t *testing.T) {
+		body := stream(fmt.Sprint(seen))
+		assert.NotContains(t, body, `id="puzzle-ui"`)
+		// The typed square, and the peer's word before and after it moved
+		for _, id := range []string{"cell-2-2", "cell-3-0", "cell-0-3"} {
+			assert.Contains(t, body, fmt.Sprintf(`id="%s"`, id))
+		}
+		for _, id := range []string{"cell-2-3", "cell-4-4"} {
+			assert.NotContains(t, body, fmt.Sprintf(`id="%s"`, id))
+		}
+	})
+
+	t.Run("a stream too far behind starts over", func(t *testing.T) {
+		body := stream(fmt.Sprint(latest + 1000))
+		assert.Contains(t, body, `id="puzzle-ui"`)
+	})
+}
+
+func TestSolveReplayView(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "stranger", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Replayed", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("../app/testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, s.Service.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, app.VisibilityPublic))
+	sess, err := s.Service.PersonalSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	for i, e := range []db.CreateSolveEntryParams{
+		{X: 0, Y: 0, Char: "A"},
+		{X: 2, Y: 0, Char: "C"},
+		{X: 0, Y: 0, Char: "B"},
+	} {
+		e.SessionID, e.UserID, e.ElapsedMs = sess.ID, owner.ID, int64(i+1)*1000
+		require.NoError(t, s.Service.Queries.CreateSolveEntry(ctx, e))
+	}
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+	get := func(path, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Cookie", cookie)
+		req.Header.Set("Datastar-Request", "true")
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	frame := func(shown, at int) string {
+		q := url.QueryEscape(fmt.Sprintf(`{"replayShown":%d,"replayAt":%d}`, shown, at))
+		rr := get(fmt.Sprintf("/puzzles/%s/replay/frame?session=%s&datastar=%s", p.ID, sess.ID, q), ownerCookie)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	page := get(fmt.Sprintf("/puzzles/%s/replay?session=%s", p.ID, sess.ID), ownerCookie)
+	require.Equal(t, http.StatusOK, page.Code)
+	assert.Contains(t, page.Body.String(), `id="puzzle-ui"`)
+	assert.Contains(t, page.Body.String(), "replay-scrubber")
+
+	body := frame(0, 2000)
+	assert.Contains(t, body, `id="cell-0-0"`)
+	assert.Contains(t, body, `id="cell-2-0"`)
+	assert.NotContains(t, body, `id="cell-1-0"`, "untouched squares aren't patched")
+	assert.Contains(t, body, `"replayShown": 2000`)
+
+	body = frame(2000, 3000)
+	assert.Contains(t, body, `id="cell-0-0"`)
+	assert.NotContains(t, body, `id="cell-2-0"`)
+
+	assert.NotContains(t, frame(3000, 3000), "cell-", "nothing changes, nothing is sent")
+
+	rr := get(fmt.Sprintf("/puzzles/%s/replay?session=%s", p.ID, sess.ID), strangerCookie)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "an unfinished personal solve is private")
+}
+
+func TestSolveAnalyticsView(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	solver, _ := s.Service.RegisterUser(ctx, "solver", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Measured", owner.ID, 5, 5)
+	require.NoError(t, err)
+	data, err := os.ReadFile("../app/testdata/sample.ipuz")
+	require.NoError(t, err)
+	require.NoError(t, s.Service.ImportPuzzle(ctx, p.ID, data, "sample.ipuz"))
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, app.VisibilityPublic))
+	sess, err := s.Service.PersonalSession(ctx, p.ID, solver.ID)
+	require.NoError(t, err)
+	require.NoError(t, s.Service.SetSessionCell(ctx, sess.ID, solver.ID, 0, 0, "Z", false))
+	require.NoError(t, s.Service.SetSessionCell(ctx, sess.ID, solver.ID, 0, 0, "A", false))
+
+	get := func(path, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Cookie", cookie)
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	assert.Contains(t, get(fmt.Sprintf("/puzzles/%s/edit", p.ID), ownerCookie).Body.String(), fmt.Sprintf("/puzzles/%s/stats", p.ID))
+
+	rr := get(fmt.Sprintf("/puzzles/%s/stats?heat=erasures", p.ID), ownerCookie)
+	require.Equal(t, http.StatusOK, rr.Code)
+	page := rr.Body.String()
+	assert.Contains(t, page, "1 solve")
+	assert.Contains(t, page, `id="clue-stats-1-across"`)
+	assert.Regexp(t, `id="cell-0-0" class="[^"]*cell-heat`, page, "the erased square is shaded")
+	assert.Contains(t, page, "--heat: 1.00;")
+	assert.NotRegexp(t, `id="cell-2-0" class="[^"]*cell-heat`, page)
+
+	rr = get(fmt.Sprintf("/puzzles/%s/stats", p.ID), loginAs(t, s, "solver", "password123456"))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "solvers don't see the constructor's stats")
+}
@@ -22,7 +22,7 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch data for the dashboard
 	limit, offset := int64(10), int64(0)
-	myPuzzles, _ := s.Service.Queries.GetPuzzlesByOwner(r.Context(), db.GetPuzzlesByOwnerParams{OwnerID: userID, Limit: limit, Offset: offset})
+	myPuzzles, _ := s.Service.Queries.GetPuzzlesByOwner(r.Context(), db.GetPuzzlesByOwnerParams{OwnerID: userID, ViewerID: userID, Limit: limit, Offset: offset})
 	followingPuzzles, _ := s.Service.Queries.GetPuzzlesFromFollowingWithUsername(r.Context(), db.GetPuzzlesFromFollowingWithUsernameParams{FollowerID: userID, Limit: limit, Offset: offset})
 
 	components.Layout(components.Dashboard(user, myPuzzles, followingPuzzles), user, true).Render(r.Context(), w)
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"share_word/internal/app"
+	"share_word/internal/web/components"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+func (s *Server) handleAddMember(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
+	var payload struct {
+		Username string `json:"memberUsername"`
+		Role     string `json:"memberRole"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Service.AddPuzzleMember(r.Context(), puzzleID, userID, payload.Username, app.Role(payload.Role))
+	if errors.Is(err, app.ErrForbidden) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	s.patchMembersPanel(w, r, puzzleID, errMsg)
+}
+
+func (s *Server) handleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userID")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
+	err := s.Service.RemovePuzzleMember(r.Context(), puzzleID, userID, memberID)
+	if errors.Is(err, app.ErrForbidden) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	s.patchMembersPanel(w, r, puzzleID, "")
+}
+
+func (s *Server) patchMembersPanel(w http.ResponseWriter, r *http.Request, puzzleID string, errMsg string) {
+	members, err := s.Service.GetPuzzleMembers(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "failed to load members", http.StatusInternalServerError)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r, datastar.WithCompression())
+	if errMsg == "" {
+		sse.PatchSignals([]byte(`{"memberUsername": ""}`))
+	}
+	sse.PatchElementTempl(components.MembersPanel(puzzleID, members, errMsg))
+
+	// Role changes affect what every connected client may do; a failed one
+	// changed nothing
+	if errMsg != "" {
+		return
+	}
+	s.Service.Publish(app.Event{Type: app.EventAccessChanged, PuzzleID: puzzleID, UserID: s.SessionManager.GetString(r.Context(), "userID")})
+}
@@ -0,0 +1,227 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"share_word/internal/app"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loginAs(t *testing.T, s *Server, username, password string) string {
+	t.Helper()
+	body := fmt.Sprintf(`{"username":%q, "password":%q}`, username, password)
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Datastar-Request", "true")
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	return rr.Header().Get("Set-Cookie")
+}
+
+func postSignals(s *Server, path, cookie, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Datastar-Request", "true")
+	if cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPuzzleMutationAuthorization(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, err := s.Service.RegisterUser(ctx, "owner", "password123456")
+	require.NoError(t, err)
+	_, err = s.Service.RegisterUser(ctx, "stranger", "password123456")
+	require.NoError(t, err)
+
+	p, err := s.Service.CreatePuzzle(ctx, "Guarded", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+
+	mutations := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"set block", fmt.Sprintf("/puzzles/%s/cells/0/0/set-block/true", p.ID), `{"symmetryMode":"none"}`},
+		{"resize", fmt.Sprintf("/puzzles/%s/resize", p.ID), `{"width":6,"height":6}`},
+		{"import", fmt.Sprintf("/puzzles/%s/import", p.ID), `{"importedFiles":[]}`},
+		{"save clue", fmt.Sprintf("/puzzles/%s/clues/1/across/save", p.ID), `{"clueText":"Vandalism"}`},
+	}
+
+	for _, m := range mutations {
+		t.Run(m.name+" anonymous", func(t *testing.T) {
+			rr := postSignals(s, m.path, "", m.body)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+		})
+		t.Run(m.name+" stranger", func(t *testing.T) {
+			rr := postSignals(s, m.path, strangerCookie, m.body)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+		})
+	}
+
+	// Nothing should have changed
+	cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+	assert.Len(t, cells, 25)
+	assert.False(t, cells[0].IsBlock)
+	clues, _ := s.Service.Queries.GetClues(ctx, p.ID)
+	assert.Empty(t, clues)
+
+	t.Run("owner can mutate", func(t *testing.T) {
+		rr := postSignals(s, fmt.Sprintf("/puzzles/%s/cells/0/0/set-block/true", p.ID), ownerCookie, `{"symmetryMode":"none"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		cells, _ := s.Service.Queries.GetCells(ctx, p.ID)
+		assert.True(t, cells[0].IsBlock)
+	})
+}
+
+func TestPuzzleEditViewAuthorization(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "stranger", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Guarded", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	strangerCookie := loginAs(t, s, "stranger", "password123456")
+	editLink := fmt.Sprintf(`href="/puzzles/%s/edit"`, p.ID)
+
+	get := func(path, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		rr := httptest.NewRecorder()
+		s.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/puzzles/"+p.ID+"/edit", strangerCookie)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = get("/puzzles/"+p.ID+"/edit/stream", strangerCookie)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = get("/puzzles/"+p.ID, strangerCookie)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "drafts are private")
+
+	rr = postSignals(s, "/puzzles/"+p.ID+"/visibility", strangerCookie, `{"visibility":"public"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = postSignals(s, "/puzzles/"+p.ID+"/visibility", ownerCookie, `{"visibility":"public"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = get("/puzzles/"+p.ID, strangerCookie)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), editLink, "edit tab should be hidden from non-owners")
+
+	rr = get("/puzzles/"+p.ID, ownerCookie)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), editLink)
+
+	rr = get("/puzzles/"+p.ID+"/edit", ownerCookie)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestPuzzleMemberRoles(t *testing.T) {
+	s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owner, _ := s.Service.RegisterUser(ctx, "owner", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "cocon", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "solver", "password123456")
+	_, _ = s.Service.RegisterUser(ctx, "watcher", "password123456")
+	p, err := s.Service.CreatePuzzle(ctx, "Team Grid", owner.ID, 5, 5)
+	require.NoError(t, err)
+
+	ownerCookie := loginAs(t, s, "owner", "password123456")
+	coconCookie := loginAs(t, s, "cocon", "password123456")
+	solverCookie := loginAs(t, s, "solver", "password123456")
+	watcherCookie := loginAs(t, s, "watcher", "password123456")
+
+	invite := func(cookie, username, role string) *httptest.ResponseRecorder {
+		return postSignals(s, fmt.Sprintf("/puzzles/%s/members", p.ID), cookie, fmt.Sprintf(`{"memberUsername":%q,"memberRole":%q}`, username, role))
+	}
+
+	rr := invite(solverCookie, "watcher", "co-constructor")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "only the owner may invite")
+
+	events := make(chan *nats.Msg, 4)
+	sub, err := s.Service.NC.ChanSubscribe("puzzles."+p.ID, events)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	accessChanged := func() bool {
+		select {
+		case msg := <-events:
+			e, err := app.ParseEvent(msg.Data)
+			return err == nil && e.Type == app.EventAccessChanged
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+
+	rr = invite(ownerCookie, "nobody", "solver")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, accessChanged(), "a failed invite changes nobody's access")
+
+	rr = invite(ownerCookie, "cocon", "co-constructor")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "cocon")
+	assert.True(t, accessChanged())
+	invite(ownerCookie, "solver", "solver")
+
+	update := fmt.Sprintf("/puzzles/%s/cells/0/0/update", p.ID)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, update, watcherCookie, `{"cellValue":"a"}`).Code)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, update, "", `{"cellValue":"a"}`).Code)
+	assert.Equal(t, http.StatusOK, postSignals(s, update, solverCookie, `{"cellValue":"a"}`).Code)
+
+	resize := fmt.Sprintf("/puzzles/%s/resize", p.ID)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, resize, solverCookie, `{"width":6,"height":6}`).Code)
+	assert.Equal(t, http.StatusOK, postSignals(s, resize, coconCookie, `{"width":6,"height":6}`).Code)
+
+	members, _ := s.Service.GetPuzzleMembers(ctx, p.ID)
+	var solverID string
+	for _, m := range members {
+		if m.Username == "solver" {
+			solverID = m.UserID
+		}
+	}
+	require.NotEmpty(t, solverID)
+	rr = postSignals(s, fmt.Sprintf("/puzzles/%s/members/%s/remove", p.ID, solverID), ownerCookie, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, update, solverCookie, `{"cellValue":"b"}`).Code)
+
+	// Once the puzzle is public, anyone signed in may solve it on their own
+	require.NoError(t, s.Service.SetPuzzleVisibility(ctx, p.ID, owner.ID, app.VisibilityPublic))
+	assert.Equal(t, http.StatusOK, postSignals(s, update, watcherCookie, `{"cellValue":"c"}`).Code)
+	req := httptest.NewRequest("GET", fmt.Sprintf("/puzzles/%s", p.ID), nil)
+	req.Header.Set("Cookie", watcherCookie)
+	page := httptest.NewRecorder()
+	s.Router.ServeHTTP(page, req)
+	assert.Contains(t, page.Body.String(), `id="puzzle-input"`)
+	assert.NotContains(t, page.Body.String(), "View only")
+
+	room, err := s.Service.CreateSharedSession(ctx, p.ID, owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, postSignals(s, update, watcherCookie, fmt.Sprintf(`{"cellValue":"d","sessionID":%q}`, room.ID)).Code, "rooms still need the solver role")
+}
@@ -2,19 +2,25 @@ package transport
 
 import (
 	"context"
+	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"share_word/internal/app"
 	"share_word/internal/db"
 	"share_word/internal/web/components"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
-	"github.com/nats-io/nats.go"
 	"github.com/starfederation/datastar-go/datastar"
 )
 
@@ -26,10 +32,120 @@ func (s *Server) handleViewPuzzleEdit(w http.ResponseWriter, r *http.Request) {
 	s.viewPuzzle(w, r, "edit")
 }
 
+// requirePuzzleRole writes an error response and returns false unless the
+// session user holds at least min on the puzzle.
+func (s *Server) requirePuzzleRole(w http.ResponseWriter, r *http.Request, puzzleID string, min app.Role) (app.Role, bool) {
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	role, err := s.Service.AuthorizePuzzle(r.Context(), puzzleID, userID, min)
+	if err != nil {
+		writeAccessError(w, err)
+		return role, false
+	}
+	return role, true
+}
+
+// requireSolveSession writes an error response and returns false unless the
+// session user may type into the solve session. An empty sessionID means
+// their personal session.
+func (s *Server) requireSolveSession(w http.ResponseWriter, r *http.Request, puzzleID, sessionID string) (db.SolveSession, bool) {
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	sess, err := s.Service.OpenSolveSession(r.Context(), puzzleID, sessionID, userID, app.RoleSolver)
+	if err != nil {
+		writeAccessError(w, err)
+		return sess, false
+	}
+	return sess, true
+}
+
+// requireWatchedSession writes an error response and returns false unless the
+// session user may watch the solve session, as its stream does. An empty
+// sessionID stands for the puzzle itself.
+func (s *Server) requireWatchedSession(w http.ResponseWriter, r *http.Request, puzzleID, sessionID string) (string, bool) {
+	if sessionID == "" {
+		return "", true
+	}
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	sess, err := s.Service.OpenSolveSession(r.Context(), puzzleID, sessionID, userID, app.RoleViewer)
+	if err != nil {
+		writeAccessError(w, err)
+		return "", false
+	}
+	return sess.ID, true
+}
+
+func writeAccessError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, app.ErrForbidden), errors.Is(err, app.ErrNoSession):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "puzzle not found", http.StatusNotFound)
+	default:
+		http.Error(w, "failed to check permissions", http.StatusInternalServerError)
+	}
+}
+
+// cursorOf is where a tab's cursor is, or nil if it hasn't focused a square.
+func (s *Server) cursorOf(key, clientID string) *app.EventCursor {
+	val, ok := s.Service.FocusedCells.Load(key)
+	if !ok {
+		return nil
+	}
+	c := &app.EventCursor{ClientID: clientID, Direction: app.DirectionAcross}
+	fmt.Sscanf(val.(string), "%d,%d", &c.X, &c.Y)
+	if d, ok := s.Service.CurrentDirections.Load(key); ok {
+		c.Direction = d.(app.Direction)
+	}
+	return c
+}
+
+// publishCursor tells the tab's grid that its cursor moved: the session's
+// streams, or everyone on the puzzle when there is no session.
+func (s *Server) publishCursor(r *http.Request, puzzleID, sessionID, clientID string) {
+	key := s.SessionManager.Token(r.Context()) + ":" + clientID
+	s.Service.Publish(app.Event{
+		Type:      app.EventCursorMoved,
+		PuzzleID:  puzzleID,
+		SessionID: sessionID,
+		UserID:    s.SessionManager.GetString(r.Context(), "userID"),
+		Cursor:    s.cursorOf(key, clientID),
+	})
+}
+
+// publishLetters tells a session which of its squares changed, along with
+// the cursor of the tab that changed them. With no changes only the cursor
+// moved.
+func (s *Server) publishLetters(r *http.Request, puzzleID, sessionID, clientID string, changed []app.Point) {
+	if len(changed) == 0 {
+		s.publishCursor(r, puzzleID, sessionID, clientID)
+		return
+	}
+	key := s.SessionManager.Token(r.Context()) + ":" + clientID
+	s.Service.PublishLetters(r.Context(), app.Event{
+		Type:      app.EventLettersChanged,
+		PuzzleID:  puzzleID,
+		SessionID: sessionID,
+		UserID:    s.SessionManager.GetString(r.Context(), "userID"),
+		Cursor:    s.cursorOf(key, clientID),
+	}, changed)
+}
+
+// streamRole is the minimum role needed to open a puzzle in the given mode.
+func streamRole(mode string) app.Role {
+	if mode == "edit" {
+		return app.RoleCoConstructor
+	}
+	return app.RoleViewer
+}
+
 func (s *Server) viewPuzzle(w http.ResponseWriter, r *http.Request, mode string) {
 	currentUserID := s.SessionManager.GetString(r.Context(), "userID")
 	puzzleID := chi.URLParam(r, "id")
 
+	role, ok := s.requirePuzzleRole(w, r, puzzleID, streamRole(mode))
+	if !ok {
+		return
+	}
+
 	// Get editing ID from registry
 	token := s.SessionManager.Token(r.Context())
 	editingClueID := ""
@@ -43,7 +159,17 @@ func (s *Server) viewPuzzle(w http.ResponseWriter, r *http.Request, mode string)
 		return
 	}
 
-	cells, err := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	// Solvers land in their personal session unless the link names a room
+	var session db.SolveSession
+	if mode == "solve" {
+		session, err = s.Service.OpenSolveSession(r.Context(), puzzleID, r.URL.Query().Get("session"), currentUserID, app.RoleViewer)
+		if err != nil && !errors.Is(err, app.ErrNoSession) {
+			writeAccessError(w, err)
+			return
+		}
+	}
+
+	cells, marks, err := s.Service.GetSolveState(r.Context(), puzzleID, session)
 	if err != nil {
 		http.Error(w, "failed to load cells", http.StatusInternalServerError)
 		return
@@ -62,14 +188,22 @@ func (s *Server) viewPuzzle(w http.ResponseWriter, r *http.Request, mode string)
 		currentUser = u
 	}
 
+	var members []db.GetPuzzleMembersRow
+	if role == app.RoleOwner {
+		members, _ = s.Service.GetPuzzleMembers(r.Context(), puzzleID)
+	}
+
 	currentDir := app.DirectionAcross
 	// We'll let the clientID generated client-side take over once SSE starts.
 
-	components.Layout(components.PuzzlePage(currentUser, p, annotated, clues, mode, s.Service.StartTime, editingClueID, string(currentDir)), currentUser, false).Render(r.Context(), w)
+	components.Layout(components.PuzzlePage(currentUser, p, annotated, clues, mode, s.Service.StartTime, editingClueID, string(currentDir), role, members, session, marks), currentUser, false).Render(r.Context(), w)
 }
 
 func (s *Server) handleSetBlock(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 	x, _ := strconv.ParseInt(chi.URLParam(r, "x"), 10, 64)
 	y, _ := strconv.ParseInt(chi.URLParam(r, "y"), 10, 64)
 
@@ -81,21 +215,18 @@ func (s *Server) handleSetBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-		PuzzleID: puzzleID,
-		X:        x,
-		Y:        y,
-		Char:     "",
-		IsBlock:  payload.IsBlock,
-		IsPencil: false,
-	})
+	points := []app.Point{{X: x, Y: y}}
+	_ = s.Service.SetBlocks(r.Context(), puzzleID, points, payload.IsBlock)
 
-	s.Service.BroadcastUpdate(puzzleID, true)
+	s.Service.PublishCellsChanged(r.Context(), puzzleID, s.SessionManager.GetString(r.Context(), "userID"), points)
 	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleSetBlockState(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 	x, _ := strconv.ParseInt(chi.URLParam(r, "x"), 10, 64)
 	y, _ := strconv.ParseInt(chi.URLParam(r, "y"), 10, 64)
 	stateStr := chi.URLParam(r, "state")
@@ -118,19 +249,40 @@ func (s *Server) handleSetBlockState(w http.ResponseWriter, r *http.Request) {
 
 	points := app.GetSymmetricCells(x, y, p.Width, p.Height, payload.SymmetryMode)
 
-	for _, pt := range points {
-		_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-			PuzzleID: puzzleID,
-			X:        pt.X,
-			Y:        pt.Y,
-			Char:     "",
-			Solution: "",
-			IsBlock:  isBlock,
-			IsPencil: false,
-		})
+	_ = s.Service.SetBlocks(r.Context(), puzzleID, points, isBlock)
+
+	s.Service.PublishCellsChanged(r.Context(), puzzleID, s.SessionManager.GetString(r.Context(), "userID"), points)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSetCellStyle(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+	x, _ := strconv.ParseInt(chi.URLParam(r, "x"), 10, 64)
+	y, _ := strconv.ParseInt(chi.URLParam(r, "y"), 10, 64)
+	style := app.CellStyle(chi.URLParam(r, "style"))
+
+	var payload struct {
+		EditColor string `json:"editColor"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Service.ToggleCellStyle(r.Context(), puzzleID, x, y, style, payload.EditColor)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "cell not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	s.Service.BroadcastUpdate(puzzleID, true)
+	s.Service.PublishCellsChanged(r.Context(), puzzleID, s.SessionManager.GetString(r.Context(), "userID"), []app.Point{{X: x, Y: y}})
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -141,7 +293,10 @@ func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 
 	var payload struct {
 		CellValue string `json:"cellValue"`
+		RebusMode bool   `json:"rebusMode"`
+		Pencil    bool   `json:"pencil"`
 		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
 	}
 	if err := datastar.ReadSignals(r, &payload); err != nil {
 		log.Printf("Error reading signals in handleUpdateCell: %v", err)
@@ -149,20 +304,21 @@ func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
 	char := payload.CellValue
 	log.Printf("UpdateCell: %s at %d,%d (client: %s)", char, x, y, payload.ClientID)
-	if len(char) > 0 {
+	if payload.RebusMode {
+		char = app.NormalizeRebus(char)
+	} else if len(char) > 0 {
 		char = strings.ToUpper(char[len(char)-1:])
 	}
 
-	_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-		PuzzleID: puzzleID,
-		X:        x,
-		Y:        y,
-		Char:     char,
-		IsBlock:  false,
-		IsPencil: false,
-	})
+	_ = s.Service.SetSessionCell(r.Context(), sess.ID, userID, x, y, char, payload.Pencil)
 
 	// Auto-advance logic
 	if char != "" {
@@ -174,7 +330,7 @@ func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 			currentDir = d.(app.Direction)
 		}
 
-		cells, _ := s.Service.Queries.GetCells(r.Context(), puzzleID)
+		cells, _ := s.Service.GetSolveCells(r.Context(), puzzleID, sess.ID)
 
 		nx, ny, nDir := s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, true)
 		if nx != x || ny != y || nDir != currentDir {
@@ -184,21 +340,30 @@ func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.publishLetters(r, puzzleID, sess.ID, payload.ClientID, []app.Point{{X: x, Y: y}})
 	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleFocusCell(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleViewer); !ok {
+		return
+	}
 	x := chi.URLParam(r, "x")
 	y := chi.URLParam(r, "y")
 	coord := fmt.Sprintf("%s,%s", x, y)
 
 	var payload struct {
-		ClientID string `json:"clientID"`
+		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
 	}
 	_ = datastar.ReadSignals(r, &payload)
 
+	sessionID, ok := s.requireWatchedSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+
 	token := s.SessionManager.Token(r.Context())
 	key := token + ":" + payload.ClientID
 
@@ -217,7 +382,7 @@ func (s *Server) handleFocusCell(w http.ResponseWriter, r *http.Request) {
 
 	s.Service.FocusedCells.Store(key, coord)
 	log.Printf("Focus Stored: %s for key %s", coord, key)
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.publishCursor(r, puzzleID, sessionID, payload.ClientID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -229,12 +394,19 @@ func (s *Server) handleNavigate(w http.ResponseWriter, r *http.Request) {
 	mode := chi.URLParam(r, "mode") // across, down, or auto
 
 	var payload struct {
-		ClientID string `json:"clientID"`
+		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
 	}
 	if err := datastar.ReadSignals(r, &payload); err != nil {
 		log.Printf("Error reading signals in handleNavigate: %v", err)
 	}
 
+	sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
 	log.Printf("Navigate: %s %s from %d,%d (client: %s)", dir, mode, x, y, payload.ClientID)
 
 	token := s.SessionManager.Token(r.Context())
@@ -253,7 +425,7 @@ func (s *Server) handleNavigate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p, _ := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
-	cells, _ := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	cells, _ := s.Service.GetSolveCells(r.Context(), puzzleID, sess.ID)
 
 	forward := dir == "forward"
 	var nx, ny int64
@@ -272,15 +444,8 @@ func (s *Server) handleNavigate(w http.ResponseWriter, r *http.Request) {
 
 		if currentChar != "" {
 			log.Printf("Backspace: Clearing current cell %d,%d", x, y)
-			_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-				PuzzleID: puzzleID,
-				X:        x,
-				Y:        y,
-				Char:     "",
-				IsBlock:  false,
-				IsPencil: false,
-			})
-			s.Service.BroadcastUpdate(puzzleID, false)
+			_ = s.Service.SetSessionCell(r.Context(), sess.ID, userID, x, y, "", false)
+			s.publishLetters(r, puzzleID, sess.ID, payload.ClientID, []app.Point{{X: x, Y: y}})
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -293,29 +458,27 @@ func (s *Server) handleNavigate(w http.ResponseWriter, r *http.Request) {
 		nDir = navDir
 	}
 
+	var cleared []app.Point
 	if nx != x || ny != y || nDir != currentDir {
 		log.Printf("Navigated to %d,%d (%s)", nx, ny, nDir)
 		s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
 		s.Service.CurrentDirections.Store(key, nDir)
 		// If moving backward in auto mode, clear the target cell
 		if !forward && mode == "auto" {
-			_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-				PuzzleID: puzzleID,
-				X:        nx,
-				Y:        ny,
-				Char:     "",
-				IsBlock:  false,
-				IsPencil: false,
-			})
+			_ = s.Service.SetSessionCell(r.Context(), sess.ID, userID, nx, ny, "", false)
+			cleared = append(cleared, app.Point{X: nx, Y: ny})
 		}
 	}
 
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.publishLetters(r, puzzleID, sess.ID, payload.ClientID, cleared)
 	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleSaveClue(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 	number, _ := strconv.Atoi(chi.URLParam(r, "number"))
 	direction := chi.URLParam(r, "direction")
 
@@ -331,12 +494,7 @@ func (s *Server) handleSaveClue(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Saving clue: Puzzle=%s, Clue=%d-%s, Text=%q, ClientID=%s", puzzleID, number, direction, payload.Text, payload.ClientID)
 
-	err := s.Service.Queries.UpsertClue(r.Context(), db.UpsertClueParams{
-		PuzzleID:  puzzleID,
-		Number:    int64(number),
-		Direction: direction,
-		Text:      payload.Text,
-	})
+	err := s.Service.SaveClue(r.Context(), puzzleID, int64(number), app.Direction(direction), payload.Text)
 	if err != nil {
 		log.Printf("Failed to upsert clue: %v", err)
 		http.Error(w, "failed to save clue", http.StatusInternalServerError)
@@ -345,7 +503,12 @@ func (s *Server) handleSaveClue(w http.ResponseWriter, r *http.Request) {
 
 	token := s.SessionManager.Token(r.Context())
 	s.Service.EditingClues.Delete(token + ":" + payload.ClientID)
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.Service.Publish(app.Event{
+		Type:     app.EventClueChanged,
+		PuzzleID: puzzleID,
+		UserID:   s.SessionManager.GetString(r.Context(), "userID"),
+		Clue:     &app.EventClue{Number: int64(number), Direction: app.Direction(direction), Text: payload.Text},
+	})
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -354,6 +517,9 @@ func (s *Server) handleEditClue(w http.ResponseWriter, r *http.Request) {
 	direction := chi.URLParam(r, "direction")
 	puzzleID := chi.URLParam(r, "id")
 	clueID := fmt.Sprintf("%d-%s", number, direction)
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 
 	var payload struct {
 		ClientID string `json:"clientID"`
@@ -362,38 +528,47 @@ func (s *Server) handleEditClue(w http.ResponseWriter, r *http.Request) {
 
 	token := s.SessionManager.Token(r.Context())
 	s.Service.EditingClues.Store(token+":"+payload.ClientID, clueID)
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.Service.Publish(app.Event{
+		Type:     app.EventClueEditing,
+		PuzzleID: puzzleID,
+		UserID:   s.SessionManager.GetString(r.Context(), "userID"),
+		Clue:     &app.EventClue{Number: int64(number), Direction: app.Direction(direction), Editing: true},
+	})
 	w.WriteHeader(http.StatusOK)
 }
 func (s *Server) handleFocusClue(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleViewer); !ok {
+		return
+	}
 	number, _ := strconv.Atoi(chi.URLParam(r, "number"))
 	direction := app.Direction(chi.URLParam(r, "direction"))
 
 	var payload struct {
-		ClientID string `json:"clientID"`
+		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
 	}
 	_ = datastar.ReadSignals(r, &payload)
 
+	sessionID, ok := s.requireWatchedSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+
 	token := s.SessionManager.Token(r.Context())
 	key := token + ":" + payload.ClientID
 
-	p, _ := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
 	cells, _ := s.Service.Queries.GetCells(r.Context(), puzzleID)
-	annotated := s.Service.CalculateNumbers(int(p.Width), int(p.Height), cells)
-
-	var fx, fy int64
-	for _, ac := range annotated {
-		if ac.Number == number {
-			fx, fy = ac.X, ac.Y
-			break
-		}
+	fx, fy, ok := s.Service.GetClueFocusTarget(r.Context(), puzzleID, cells, number, direction)
+	if !ok {
+		http.Error(w, "clue not found", http.StatusNotFound)
+		return
 	}
 
 	s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", fx, fy))
 	s.Service.CurrentDirections.Store(key, direction)
 
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.publishCursor(r, puzzleID, sessionID, payload.ClientID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -401,16 +576,25 @@ func (s *Server) handlePuzzleInput(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
 
 	var payload struct {
-		Key      string `json:"lastKey"`
-		IsShift  bool   `json:"isShift"`
-		IsCtrl   bool   `json:"isCtrl"`
-		ClientID string `json:"clientID"`
+		Key       string `json:"lastKey"`
+		IsShift   bool   `json:"isShift"`
+		IsCtrl    bool   `json:"isCtrl"`
+		Pencil    bool   `json:"pencil"`
+		Rebus     string `json:"rebusBuffer"`
+		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
 	}
 	if err := datastar.ReadSignals(r, &payload); err != nil {
 		http.Error(w, "invalid signals", http.StatusBadRequest)
 		return
 	}
 
+	sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
 	token := s.SessionManager.Token(r.Context())
 	key := token + ":" + payload.ClientID
 
@@ -436,10 +620,16 @@ func (s *Server) handlePuzzleInput(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p, _ := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
-	cells, _ := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	cells, _ := s.Service.GetSolveCells(r.Context(), puzzleID, sess.ID)
 
 	modifierHeld := payload.IsShift || payload.IsCtrl
 
+	var changed []app.Point
+	setLetter := func(x, y int64, char string, pencil bool) {
+		_ = s.Service.SetSessionCell(r.Context(), sess.ID, userID, x, y, char, pencil)
+		changed = append(changed, app.Point{X: x, Y: y})
+	}
+
 	switch payload.Key {
 	case "Tab":
 		forward := !payload.IsShift
@@ -484,16 +674,21 @@ func (s *Server) handlePuzzleInput(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if currentChar != "" {
-			_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-				PuzzleID: puzzleID, X: x, Y: y, Char: "", IsBlock: false, IsPencil: false,
-			})
+			setLetter(x, y, "", false)
 		} else {
 			nx, ny, nDir := s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, false)
 			s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
 			s.Service.CurrentDirections.Store(key, nDir)
-			_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-				PuzzleID: puzzleID, X: nx, Y: ny, Char: "", IsBlock: false, IsPencil: false,
-			})
+			setLetter(nx, ny, "", false)
+		}
+	case "Rebus":
+		// A committed rebus buffer fills the cell like a single letter would
+		char := app.NormalizeRebus(payload.Rebus)
+		if char != "" {
+			setLetter(x, y, char, payload.Pencil)
+			nx, ny, nDir := s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, true)
+			s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
+			s.Service.CurrentDirections.Store(key, nDir)
 		}
 	case " ":
 		// Non-overwriting space
@@ -504,16 +699,230 @@ func (s *Server) handlePuzzleInput(w http.ResponseWriter, r *http.Request) {
 		// Assume single character
 		if len(payload.Key) == 1 {
 			char := strings.ToUpper(payload.Key)
-			_ = s.Service.Queries.UpdateCell(r.Context(), db.UpdateCellParams{
-				PuzzleID: puzzleID, X: x, Y: y, Char: char, IsBlock: false, IsPencil: false,
-			})
+			setLetter(x, y, char, payload.Pencil)
 			nx, ny, nDir := s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, true)
 			s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
 			s.Service.CurrentDirections.Store(key, nDir)
 		}
 	}
 
-	s.Service.BroadcastUpdate(puzzleID, false)
+	s.publishLetters(r, puzzleID, sess.ID, payload.ClientID, changed)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleEditInput is handlePuzzleInput for constructors: keys typed in edit
+// mode write the grid's answers instead of a session's letters.
+func (s *Server) handleEditInput(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+
+	var payload struct {
+		Key      string `json:"lastKey"`
+		IsShift  bool   `json:"isShift"`
+		IsCtrl   bool   `json:"isCtrl"`
+		Rebus    string `json:"rebusBuffer"`
+		ClientID string `json:"clientID"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	token := s.SessionManager.Token(r.Context())
+	key := token + ":" + payload.ClientID
+
+	focusedCell := ""
+	if val, ok := s.Service.FocusedCells.Load(key); ok {
+		focusedCell = val.(string)
+	}
+	if focusedCell == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var x, y int64
+	fmt.Sscanf(focusedCell, "%d,%d", &x, &y)
+
+	currentDir := app.DirectionAcross
+	if d, ok := s.Service.CurrentDirections.Load(key); ok {
+		currentDir = d.(app.Direction)
+	}
+
+	p, err := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "puzzle not found", http.StatusNotFound)
+		return
+	}
+	cells, _ := s.Service.Queries.GetCells(r.Context(), puzzleID)
+
+	move := func(nx, ny int64, nDir app.Direction) {
+		s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
+		s.Service.CurrentDirections.Store(key, nDir)
+	}
+	arrow := func(dir app.Direction, forward bool) {
+		if payload.IsShift || payload.IsCtrl {
+			s.Service.CurrentDirections.Store(key, dir)
+			return
+		}
+		nx, ny := s.Service.GetNextCell(int(p.Width), int(p.Height), cells, x, y, dir, forward)
+		s.Service.FocusedCells.Store(key, fmt.Sprintf("%d,%d", nx, ny))
+	}
+	var changed []app.Point
+	setSolution := func(x, y int64, solution string) {
+		_ = s.Service.SetCellSolution(r.Context(), puzzleID, x, y, solution)
+		changed = append(changed, app.Point{X: x, Y: y})
+	}
+	write := func(solution string) {
+		// Typing only punctuation leaves the answer alone; Backspace clears it
+		solution = app.NormalizeRebus(solution)
+		if solution == "" {
+			return
+		}
+		setSolution(x, y, solution)
+		move(s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, true))
+	}
+
+	switch payload.Key {
+	case "Tab":
+		move(s.Service.GetClueJumpTarget(r.Context(), puzzleID, cells, x, y, currentDir, !payload.IsShift))
+	case "ArrowRight":
+		arrow(app.DirectionAcross, true)
+	case "ArrowLeft":
+		arrow(app.DirectionAcross, false)
+	case "ArrowDown":
+		arrow(app.DirectionDown, true)
+	case "ArrowUp":
+		arrow(app.DirectionDown, false)
+	case "Backspace":
+		var current string
+		for _, c := range cells {
+			if c.X == x && c.Y == y {
+				current = c.Solution
+				break
+			}
+		}
+		if current == "" {
+			nx, ny, nDir := s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, false)
+			move(nx, ny, nDir)
+			x, y = nx, ny
+		}
+		setSolution(x, y, "")
+	case "Rebus":
+		write(payload.Rebus)
+	case " ":
+		move(s.Service.GetAutoAdvanceTarget(r.Context(), puzzleID, cells, x, y, currentDir, true))
+	default:
+		if len(payload.Key) == 1 {
+			write(payload.Key)
+		}
+	}
+
+	if len(changed) > 0 {
+		s.Service.PublishCellsChanged(r.Context(), puzzleID, s.SessionManager.GetString(r.Context(), "userID"), changed)
+	} else {
+		s.publishCursor(r, puzzleID, "", payload.ClientID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	s.checkOrReveal(w, r, false)
+}
+
+func (s *Server) handleReveal(w http.ResponseWriter, r *http.Request) {
+	s.checkOrReveal(w, r, true)
+}
+
+// checkOrReveal runs a check or reveal around the caller's cursor and pushes
+// the resulting marks to everyone in the session.
+func (s *Server) checkOrReveal(w http.ResponseWriter, r *http.Request, reveal bool) {
+	puzzleID := chi.URLParam(r, "id")
+	scope := app.CheckScope(chi.URLParam(r, "scope"))
+
+	var payload struct {
+		ClientID  string `json:"clientID"`
+		SessionID string `json:"sessionID"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+
+	token := s.SessionManager.Token(r.Context())
+	key := token + ":" + payload.ClientID
+
+	x, y := int64(-1), int64(-1)
+	if val, ok := s.Service.FocusedCells.Load(key); ok {
+		fmt.Sscanf(val.(string), "%d,%d", &x, &y)
+	}
+	currentDir := app.DirectionAcross
+	if d, ok := s.Service.CurrentDirections.Load(key); ok {
+		currentDir = d.(app.Direction)
+	}
+
+	points, err := s.Service.ScopePoints(r.Context(), puzzleID, sess.ID, scope, x, y, currentDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	event := app.EventLettersChecked
+	if reveal {
+		event = app.EventRevealed
+		err = s.Service.RevealCells(r.Context(), puzzleID, sess.ID, scope, x, y, currentDir)
+	} else {
+		err = s.Service.CheckCells(r.Context(), puzzleID, sess.ID, scope, x, y, currentDir)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Service.PublishLetters(r.Context(), app.Event{
+		Type:      event,
+		PuzzleID:  puzzleID,
+		SessionID: sess.ID,
+		UserID:    s.SessionManager.GetString(r.Context(), "userID"),
+		Scope:     scope,
+	}, points)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSetAutocheck(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+
+	var payload struct {
+		Autocheck bool   `json:"autocheck"`
+		SessionID string `json:"sessionID"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	sess, ok := s.requireSolveSession(w, r, puzzleID, payload.SessionID)
+	if !ok {
+		return
+	}
+
+	if err := s.Service.SetSessionAutocheck(r.Context(), sess.ID, payload.Autocheck); err != nil {
+		http.Error(w, "failed to update autocheck", http.StatusInternalServerError)
+		return
+	}
+
+	s.Service.Publish(app.Event{
+		Type:      app.EventAutocheck,
+		PuzzleID:  puzzleID,
+		SessionID: sess.ID,
+		UserID:    s.SessionManager.GetString(r.Context(), "userID"),
+		Autocheck: &payload.Autocheck,
+	})
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -528,9 +937,27 @@ func (s *Server) handlePuzzleStreamEdit(w http.ResponseWriter, r *http.Request)
 func (s *Server) handlePuzzleStream(w http.ResponseWriter, r *http.Request, mode string) {
 	puzzleID := chi.URLParam(r, "id")
 	clientID := r.URL.Query().Get("clientID")
+	sessionID := r.URL.Query().Get("session")
 	subject := fmt.Sprintf("puzzles.%s", puzzleID)
 
-	log.Printf("SSE: Client connecting to %s (mode: %s, clientID: %s, remote: %s)", subject, mode, clientID, r.RemoteAddr)
+	log.Printf("SSE: Client connecting to %s (mode: %s, clientID: %s, session: %s, remote: %s)", subject, mode, clientID, sessionID, r.RemoteAddr)
+
+	role, ok := s.requirePuzzleRole(w, r, puzzleID, streamRole(mode))
+	if !ok {
+		return
+	}
+
+	subjects := []string{subject}
+	if mode == "solve" && sessionID != "" {
+		userID := s.SessionManager.GetString(r.Context(), "userID")
+		if _, err := s.Service.OpenSolveSession(r.Context(), puzzleID, sessionID, userID, app.RoleViewer); err != nil {
+			writeAccessError(w, err)
+			return
+		}
+		subjects = append(subjects, app.SessionSubject(puzzleID, sessionID))
+	} else {
+		sessionID = ""
+	}
 
 	if s.Service.NC == nil {
 		log.Printf("SSE: NATS connection is nil, rejecting client")
@@ -538,23 +965,182 @@ func (s *Server) handlePuzzleStream(w http.ResponseWriter, r *http.Request, mode
 		return
 	}
 
+	// A tab reconnecting after a dropped connection says which event it saw
+	// last, and catches up from there rather than starting over
+	var resumeFrom uint64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		resumeFrom, _ = strconv.ParseUint(id, 10, 64)
+	}
+
 	notify := make(chan struct{}, 1)
-	sub, err := s.Service.NC.Subscribe(subject, func(msg *nats.Msg) {
+	completed := make(chan struct{}, 1)
+	chat := make(chan struct{}, 1)
+	// Set when a pending update needs the whole puzzle rendered again
+	var rebuild atomic.Bool
+	// Set when the tab's role on the puzzle needs looking up again
+	var accessChanged atomic.Bool
+	// The newest revision heard of, so pushes don't show an older grid
+	var revision atomic.Int64
+	revision.Store(-1)
+
+	// Guarded by eventMu, which is held while events are passed on so a push
+	// is only tagged with events it includes
+	var (
+		eventMu  sync.Mutex
+		lastSeq  uint64          // Newest event handled
+		handled  uint64          // Events handled so far
+		missed   = ^uint64(0)    // Events logged before the watch; unknown until it starts
+		stale    = newStaleSet() // What the missed events touched
+		caughtUp = make(chan struct{})
+	)
+	handle := func(seq uint64, event app.Event, err error) {
+		ch := notify
+		if err != nil {
+			log.Printf("SSE: Unreadable event %d on %s: %v", seq, subject, err)
+		}
+		if err != nil || event.Type.Structural() {
+			rebuild.Store(true)
+		}
+		if err != nil || event.Type == app.EventAccessChanged {
+			accessChanged.Store(true)
+		}
+		storeMax(&revision, event.Revision)
+		switch event.Type {
+		case app.EventCompleted:
+			ch = completed
+		case app.EventChatPosted:
+			ch = chat
+		}
+
+		eventMu.Lock()
+		defer eventMu.Unlock()
+		lastSeq = max(lastSeq, seq)
+		handled++
+		if handled <= missed {
+			stale.add(event, err)
+			if handled == missed {
+				close(caughtUp)
+			}
+		}
 		select {
-		case notify <- struct{}{}:
+		case ch <- struct{}{}:
 		default:
 		}
-	})
+	}
+
+	resuming := resumeFrom > 0
+	watchFrom := func() (*app.EventWatch, error) {
+		after := resumeFrom
+		if !resuming {
+			var err error
+			if after, err = s.Service.LatestEventSeq(r.Context()); err != nil {
+				return nil, err
+			}
+		}
+		lastSeq = after
+		return s.Service.WatchEvents(r.Context(), subjects, after, handle)
+	}
+	watch, err := watchFrom()
+	if resuming && errors.Is(err, app.ErrReplayUnavailable) {
+		log.Printf("SSE: Can't resume %s from event %d (clientID: %s), starting over", subject, resumeFrom, clientID)
+		resuming = false
+		watch, err = watchFrom()
+	}
 	if err != nil {
+		log.Printf("SSE: Failed to watch %s: %v", subject, err)
 		http.Error(w, "failed to subscribe", http.StatusInternalServerError)
 		return
 	}
-	defer sub.Unsubscribe()
+	defer watch.Stop()
+
+	eventMu.Lock()
+	missed = watch.Missed
+	if handled >= missed {
+		close(caughtUp)
+	}
+	eventMu.Unlock()
+
+	// The solve clock runs while anyone has the session open
+	if sessionID != "" {
+		if err := s.Service.SessionStreamOpened(r.Context(), sessionID); err != nil {
+			log.Printf("SSE: Failed to start timer for session %s: %v", sessionID, err)
+		}
+		defer s.Service.SessionStreamClosed(sessionID)
+	}
+
+	// Show up on everyone else's grid while connected
+	var presence app.Presence
+	if clientID != "" {
+		userID := s.SessionManager.GetString(r.Context(), "userID")
+		presence = app.Presence{
+			Key:       s.SessionManager.Token(r.Context()) + ":" + clientID,
+			PuzzleID:  puzzleID,
+			SessionID: sessionID,
+			Mode:      mode,
+			UserID:    userID,
+		}
+		if userID != "" {
+			if u, err := s.Service.GetUserByID(r.Context(), userID); err == nil {
+				presence.Username = u.Username
+			}
+		}
+		s.Service.Join(presence)
+		defer s.Service.Leave(presence.Key)
+	}
+	heartbeat := time.NewTicker(s.Service.PresenceTimeout / 3)
+	defer heartbeat.Stop()
 
 	sse := datastar.NewSSE(w, r, datastar.WithCompression())
 
-	// Push initial state immediately
-	s.pushPuzzleState(r.Context(), sse, puzzleID, mode, clientID)
+	// A resuming tab already shows the grid as of its last event, so once
+	// the events since are in only what they touched is patched
+	var view gridView
+	if resuming {
+		select {
+		case <-caughtUp:
+			eventMu.Lock()
+			view.stale = stale
+			eventMu.Unlock()
+		case <-time.After(5 * time.Second):
+			log.Printf("SSE: Timed out catching %s up (clientID: %s)", subject, clientID)
+			resuming = false
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	// Push initial state immediately, covering everything heard of so far.
+	// Guests solving on their own have no room to chat in.
+	rebuild.Store(false)
+	select {
+	case <-notify:
+	default:
+	}
+	hasChat := mode == "edit" || sessionID != ""
+	if !resuming {
+		select {
+		case <-chat:
+		default:
+		}
+	}
+	s.pushPuzzleState(r.Context(), sse, puzzleID, mode, clientID, sessionID, role, revision.Load(), &view)
+	if hasChat && !resuming {
+		s.pushChat(r.Context(), sse, puzzleID, sessionID)
+	}
+
+	// Tag the tab's grid with the newest event it reflects, for it to
+	// resume from, once nothing is waiting to be pushed
+	var tagged uint64
+	tagSeen := func() {
+		eventMu.Lock()
+		seq := lastSeq
+		idle := len(notify) == 0 && len(chat) == 0 && len(completed) == 0
+		eventMu.Unlock()
+		if idle && seq != tagged {
+			sse.PatchSignals([]byte(`{}`), datastar.WithPatchSignalsEventID(strconv.FormatUint(seq, 10)))
+			tagged = seq
+		}
+	}
 
 	// Hot reload check
 	if components.EnableHotReload {
@@ -572,28 +1158,83 @@ func (s *Server) handlePuzzleStream(w http.ResponseWriter, r *http.Request, mode
 	}
 
 	for {
+		tagSeen()
 		select {
 		case <-r.Context().Done():
 			log.Printf("SSE: Client disconnected from %s (clientID: %s)", subject, clientID)
 			return
 		case <-notify:
 			log.Printf("SSE: Pushing update for %s (clientID: %s)", subject, clientID)
-			s.pushPuzzleState(r.Context(), sse, puzzleID, mode, clientID)
+			if rebuild.Swap(false) {
+				view = gridView{}
+			}
+			if accessChanged.Swap(false) {
+				userID := s.SessionManager.GetString(r.Context(), "userID")
+				role, _ = s.Service.PuzzleRole(r.Context(), puzzleID, userID)
+			}
+			s.pushPuzzleState(r.Context(), sse, puzzleID, mode, clientID, sessionID, role, revision.Load(), &view)
+		case <-chat:
+			if hasChat {
+				s.pushChat(r.Context(), sse, puzzleID, sessionID)
+			}
+		case <-heartbeat.C:
+			// An empty patch finds out whether the client is still there;
+			// returning drops its presence and solve timer
+			if err := sse.PatchSignals([]byte("{}")); err != nil {
+				log.Printf("SSE: Client gone for puzzle %s (clientID: %s): %v", puzzleID, clientID, err)
+				return
+			}
+			// A stream that outlived its timeout joins again
+			if presence.Key != "" && !s.Service.Heartbeat(presence.Key) {
+				s.Service.Join(presence)
+			}
+		case <-completed:
+			log.Printf("SSE: Session %s solved (clientID: %s)", sessionID, clientID)
+			sess, err := s.Service.Queries.GetSolveSession(r.Context(), sessionID)
+			if err != nil {
+				continue
+			}
+			elapsed := app.SolveElapsed(sess, time.Now())
+			contributions, err := s.Service.Contributions(r.Context(), puzzleID, sessionID)
+			if err != nil {
+				log.Printf("SSE: Failed to tally session %s: %v", sessionID, err)
+			}
+			sse.PatchElementTempl(components.SolveTimer(sess, elapsed))
+			sse.PatchElementTempl(components.SolveComplete(elapsed, contributions))
 		}
 	}
 }
 
-func (s *Server) pushPuzzleState(ctx context.Context, sse *datastar.ServerSentEventGenerator, puzzleID string, mode string, clientID string) {
-	p, err := s.Service.Queries.GetPuzzle(ctx, puzzleID)
-	if err != nil {
-		fmt.Printf("Error getting puzzle in push: %v\n", err)
-		return
+// storeMax raises v to n if n is larger.
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		old := v.Load()
+		if n <= old || v.CompareAndSwap(old, n) {
+			return
+		}
 	}
+}
 
-	cells, err := s.Service.Queries.GetCells(ctx, puzzleID)
+// pushPuzzleState renders the puzzle, as of at least the given revision, as
+// the tab sees it, given the tab's role on the puzzle. A negative revision
+// means the latest. With a view from an
+// earlier push only the squares and clues that changed are patched; a nil or
+// empty view, or a change in the grid's size or the caller's role, renders
+// the whole puzzle. A view holding only a stale set is taken to be the grid
+// as it is now apart from what the set names. The view is updated to what was
+// sent.
+func (s *Server) pushPuzzleState(ctx context.Context, sse *datastar.ServerSentEventGenerator, puzzleID string, mode string, clientID string, sessionID string, role app.Role, revision int64, view *gridView) {
+	// The grid is shared with every stream on it; only the cursor is worked
+	// out per tab
+	state, err := s.Service.PuzzleState(ctx, puzzleID, sessionID, revision)
 	if err != nil {
+		fmt.Printf("Error getting puzzle in push: %v\n", err)
 		return
 	}
+	p, cells, marks, annotated, clues, session := state.Puzzle, state.Cells, state.Marks, state.Annotated, state.Clues, state.Session
+
+	userID := s.SessionManager.GetString(ctx, "userID")
+	role = app.SessionRole(role, session, userID)
 
 	token := s.SessionManager.Token(ctx)
 	key := token + ":" + clientID
@@ -613,9 +1254,6 @@ func (s *Server) pushPuzzleState(ctx context.Context, sse *datastar.ServerSentEv
 		currentDir = d.(app.Direction)
 	}
 
-	annotated := s.Service.CalculateNumbers(int(p.Width), int(p.Height), cells)
-	clues, _ := s.Service.GetFullClues(ctx, p.ID, cells)
-
 	var activeWordCells map[string]bool
 	var activeClue *app.Clue
 	var inactiveClue *app.Clue
@@ -632,8 +1270,191 @@ func (s *Server) pushPuzzleState(ctx context.Context, sse *datastar.ServerSentEv
 		inactiveClue = s.Service.GetActiveClue(int(p.Width), int(p.Height), cells, clues, fx, fy, otherDir)
 	}
 
-	sse.PatchSignals([]byte(fmt.Sprintf(`{"direction": %q}`, currentDir)))
-	sse.PatchElementTempl(components.PuzzleUI(p, annotated, clues, mode, editingClueID, focusedCell, activeWordCells, activeClue, inactiveClue))
+	// Everyone else on the same grid shows up with their own color
+	present := s.Service.PresentOn(puzzleID, mode, sessionID)
+	var peers []app.Presence
+	for _, pr := range present {
+		if pr.Key != key {
+			peers = append(peers, pr)
+		}
+	}
+	peerMarks := s.Service.PeerMarks(int(p.Width), int(p.Height), cells, peers)
+
+	next := newGridView(p, role, annotated, clues, editingClueID, focusedCell, activeWordCells, activeClue, marks, peerMarks)
+	if view != nil && view.stale != nil && !view.stale.rebuild {
+		*view = s.staleView(next, view.stale, p, cells, clues, clientID)
+	}
+
+	sse.PatchSignals([]byte(fmt.Sprintf(`{"direction": %q, "autocheck": %t}`, currentDir, session.Autocheck)))
+	if view == nil || !view.sameShape(next) {
+		sse.PatchElementTempl(components.PuzzleUI(p, annotated, clues, mode, editingClueID, focusedCell, activeWordCells, activeClue, inactiveClue, role, marks, peerMarks))
+	} else {
+		var patches []templ.Component
+		for _, cell := range annotated {
+			coord := fmt.Sprintf("%d,%d", cell.X, cell.Y)
+			if view.cells[coord] != next.cells[coord] {
+				patches = append(patches, components.Cell(cell, p.ID, mode, focusedCell, activeWordCells, marks, peerMarks[coord]))
+			}
+		}
+		if !slices.Equal(view.clueIDs, next.clueIDs) {
+			// Blocks moved, so clues came or went
+			patches = append(patches, components.ClueSidebar(p.ID, clues, mode, editingClueID, activeClue))
+		} else {
+			for i, clue := range clues {
+				id := next.clueIDs[i]
+				if view.clues[id] != next.clues[id] {
+					patches = append(patches, components.ClueItem(p.ID, clue, mode, editingClueID, next.clues[id].active))
+				}
+			}
+		}
+		// Each patched element is matched to the page by its id
+		if len(patches) > 0 {
+			sse.PatchElementTempl(templ.Join(patches...))
+		}
+	}
+	if view != nil {
+		*view = next
+	}
+	sse.PatchElementTempl(components.PresenceRoster(app.Roster(present), key, userID))
+	if session.ID != "" {
+		sse.PatchElementTempl(components.SolveTimer(session, app.SolveElapsed(session, time.Now())))
+	}
+}
+
+// gridView is what a stream last showed: each square and clue as rendered,
+// keyed by "x,y" and clue ID.
+type gridView struct {
+	width, height int64
+	role          app.Role
+	cells         map[string]cellView
+	clueIDs       []string
+	clues         map[string]clueView
+	// Set instead of the rest for a tab that resumed its stream
+	stale *staleSet
+}
+
+type cellView struct {
+	cell    app.AnnotatedCell
+	focused bool
+	word    bool
+	mark    app.CellMark
+	peer    app.PeerMark
+}
+
+type clueView struct {
+	clue    app.Clue
+	editing bool
+	active  bool
+}
+
+func newGridView(p db.GetPuzzleRow, role app.Role, cells []app.AnnotatedCell, clues []app.Clue, editingClueID, focusedCell string, activeWordCells map[string]bool, activeClue *app.Clue, marks map[string]app.CellMark, peerMarks map[string]app.PeerMark) gridView {
+	v := gridView{
+		width:  p.Width,
+		height: p.Height,
+		role:   role,
+		cells:  make(map[string]cellView, len(cells)),
+		clues:  make(map[string]clueView, len(clues)),
+	}
+	for _, c := range cells {
+		coord := fmt.Sprintf("%d,%d", c.X, c.Y)
+		v.cells[coord] = cellView{
+			cell:    c,
+			focused: focusedCell == coord,
+			word:    activeWordCells[coord],
+			mark:    marks[coord],
+			peer:    peerMarks[coord],
+		}
+	}
+	for _, c := range clues {
+		id := fmt.Sprintf("%d-%s", c.Number, c.Direction)
+		v.clueIDs = append(v.clueIDs, id)
+		v.clues[id] = clueView{
+			clue:    c,
+			editing: editingClueID == id,
+			active:  activeClue != nil && activeClue.Number == c.Number && activeClue.Direction == c.Direction,
+		}
+	}
+	return v
+}
+
+// staleSet is what a resuming tab may be showing out of date: the squares and
+// clues named by the events it missed, and the words under the cursors those
+// events moved. rebuild is set when a missed event could have changed
+// anything.
+type staleSet struct {
+	rebuild bool
+	cells   map[string]bool
+	clues   map[string]bool
+	cursors []app.EventCursor
+}
+
+func newStaleSet() *staleSet {
+	return &staleSet{cells: make(map[string]bool), clues: make(map[string]bool)}
+}
+
+// add notes what a missed event touched.
+func (st *staleSet) add(e app.Event, err error) {
+	if err != nil || e.Type.Structural() || e.Type == app.EventCellsChanged || e.Type == app.EventAutocheck {
+		// Blocks and answers renumber the grid; autocheck marks every square
+		st.rebuild = true
+		return
+	}
+	for _, l := range e.Letters {
+		st.cells[fmt.Sprintf("%d,%d", l.X, l.Y)] = true
+	}
+	if e.Clue != nil {
+		st.clues[fmt.Sprintf("%d-%s", e.Clue.Number, e.Clue.Direction)] = true
+	}
+	for _, c := range []*app.EventCursor{e.Cursor, e.PrevCursor} {
+		if c != nil {
+			st.cursors = append(st.cursors, *c)
+		}
+	}
+}
+
+// staleView is next with what st names blanked out, so that pushing next over
+// it patches just those squares and clues. A zero view never matches a
+// rendered one.
+func (s *Server) staleView(next gridView, st *staleSet, p db.GetPuzzleRow, cells []db.Cell, clues []app.Clue, clientID string) gridView {
+	v := next
+	v.cells = maps.Clone(next.cells)
+	v.clues = maps.Clone(next.clues)
+	// Events from before a resize can name squares and clues that are gone
+	blankCell := func(coord string) {
+		if _, ok := v.cells[coord]; ok {
+			v.cells[coord] = cellView{}
+		}
+	}
+	blankClue := func(id string) {
+		if _, ok := v.clues[id]; ok {
+			v.clues[id] = clueView{}
+		}
+	}
+	for coord := range st.cells {
+		blankCell(coord)
+	}
+	for id := range st.clues {
+		blankClue(id)
+	}
+	for _, c := range st.cursors {
+		blankCell(fmt.Sprintf("%d,%d", c.X, c.Y))
+		for coord := range s.Service.GetActiveWordCells(int(p.Width), int(p.Height), cells, c.X, c.Y, c.Direction) {
+			blankCell(coord)
+		}
+		// Only the tab's own cursor highlights a clue
+		if c.ClientID == clientID {
+			if clue := s.Service.GetActiveClue(int(p.Width), int(p.Height), cells, clues, c.X, c.Y, c.Direction); clue != nil {
+				blankClue(fmt.Sprintf("%d-%s", clue.Number, clue.Direction))
+			}
+		}
+	}
+	return v
+}
+
+// sameShape reports whether next can be reached from v by patching squares
+// and clues in place.
+func (v *gridView) sameShape(next gridView) bool {
+	return v.cells != nil && v.width == next.width && v.height == next.height && v.role == next.role && len(v.cells) == len(next.cells)
 }
 
 func (s *Server) handleCreatePuzzle(w http.ResponseWriter, r *http.Request) {
@@ -670,8 +1491,50 @@ func (s *Server) handleCreatePuzzle(w http.ResponseWriter, r *http.Request) {
 	datastar.NewSSE(w, r, datastar.WithCompression()).Redirect(fmt.Sprintf("/puzzles/%s", p.ID))
 }
 
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
+	sess, err := s.Service.CreateSharedSession(r.Context(), puzzleID, userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	datastar.NewSSE(w, r, datastar.WithCompression()).Redirect(fmt.Sprintf("/puzzles/%s?session=%s", puzzleID, sess.ID))
+}
+
+func (s *Server) handleSetVisibility(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
+	var payload struct {
+		Visibility string `json:"visibility"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Service.SetPuzzleVisibility(r.Context(), puzzleID, userID, app.Visibility(payload.Visibility))
+	if errors.Is(err, app.ErrForbidden) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Service.Publish(app.Event{Type: app.EventAccessChanged, PuzzleID: puzzleID, UserID: userID})
+	w.WriteHeader(http.StatusOK)
+}
+
 func (s *Server) handleResizePuzzle(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 
 	var payload struct {
 		Width  int64 `json:"width"`
@@ -691,12 +1554,15 @@ func (s *Server) handleResizePuzzle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Service.BroadcastUpdate(puzzleID, true)
+	s.Service.PublishGridReplaced(r.Context(), app.EventResized, puzzleID, s.SessionManager.GetString(r.Context(), "userID"))
 	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleImportPuzzle(w http.ResponseWriter, r *http.Request) {
 	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
 
 	var payload struct {
 		ImportedFiles []struct {
@@ -747,7 +1613,40 @@ func (s *Server) handleImportPuzzle(w http.ResponseWriter, r *http.Request) {
 	// Reset the importedFiles signal on the client so they can import again if needed
 	datastar.NewSSE(w, r, datastar.WithCompression()).PatchSignals([]byte(`{"importedFiles": []}`))
 
-	s.Service.BroadcastUpdate(puzzleID, true)
+	s.Service.PublishGridReplaced(r.Context(), app.EventImported, puzzleID, s.SessionManager.GetString(r.Context(), "userID"))
+}
+
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.stepHistory(w, r, true)
+}
+
+func (s *Server) handleRedo(w http.ResponseWriter, r *http.Request) {
+	s.stepHistory(w, r, false)
+}
+
+// stepHistory undoes or redoes one edit. Having nothing left to step over
+// isn't an error; the grid just stays as it is.
+func (s *Server) stepHistory(w http.ResponseWriter, r *http.Request, undo bool) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+
+	step, event := s.Service.Redo, app.EventRedone
+	if undo {
+		step, event = s.Service.Undo, app.EventUndone
+	}
+	changed, err := step(r.Context(), puzzleID)
+	if err != nil {
+		log.Printf("Failed to step history for %s: %v", puzzleID, err)
+		http.Error(w, "failed to update puzzle", http.StatusInternalServerError)
+		return
+	}
+
+	if changed {
+		s.Service.PublishGridReplaced(r.Context(), event, puzzleID, s.SessionManager.GetString(r.Context(), "userID"))
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
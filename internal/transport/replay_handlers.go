@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"share_word/internal/app"
+	"share_word/internal/db"
+	"share_word/internal/web/components"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+func (s *Server) handleViewReplay(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	replay, err := s.Service.OpenReplay(r.Context(), puzzleID, r.URL.Query().Get("session"), userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	p, err := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "puzzle not found", http.StatusNotFound)
+		return
+	}
+	cells, err := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "failed to load cells", http.StatusInternalServerError)
+		return
+	}
+
+	// Playback starts from the empty grid
+	grid, marks := replay.At(cells, 0)
+	annotated := s.Service.CalculateNumbers(int(p.Width), int(p.Height), grid)
+	clues, err := s.Service.GetFullClues(r.Context(), p.ID, grid)
+	if err != nil {
+		http.Error(w, "failed to load clues", http.StatusInternalServerError)
+		return
+	}
+
+	var currentUser *db.User
+	if userID != "" {
+		currentUser, _ = s.Service.GetUserByID(r.Context(), userID)
+	}
+	components.Layout(components.ReplayPage(currentUser, p, annotated, clues, replay, marks), currentUser, false).Render(r.Context(), w)
+}
+
+// handleReplayFrame moves a replay from the position the page shows to the
+// one it asks for, patching only the squares that differ between the two.
+func (s *Server) handleReplayFrame(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+	replay, err := s.Service.OpenReplay(r.Context(), puzzleID, r.URL.Query().Get("session"), userID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	var payload struct {
+		At    int64 `json:"replayAt"`
+		Shown int64 `json:"replayShown"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.Service.Queries.GetPuzzle(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "puzzle not found", http.StatusNotFound)
+		return
+	}
+	cells, err := s.Service.Queries.GetCells(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "failed to load cells", http.StatusInternalServerError)
+		return
+	}
+
+	before, beforeMarks := replay.At(cells, time.Duration(payload.Shown)*time.Millisecond)
+	after, afterMarks := replay.At(cells, time.Duration(payload.At)*time.Millisecond)
+	shown := make(map[string]db.Cell, len(before))
+	for _, c := range before {
+		shown[fmt.Sprintf("%d,%d", c.X, c.Y)] = c
+	}
+
+	var patches []templ.Component
+	for _, cell := range s.Service.CalculateNumbers(int(p.Width), int(p.Height), after) {
+		coord := fmt.Sprintf("%d,%d", cell.X, cell.Y)
+		old := shown[coord]
+		if old.Char != cell.Char || old.IsPencil != cell.IsPencil || beforeMarks[coord] != afterMarks[coord] {
+			patches = append(patches, components.Cell(cell, p.ID, "replay", "", nil, afterMarks, app.PeerMark{}))
+		}
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if len(patches) > 0 {
+		sse.PatchElementTempl(templ.Join(patches...))
+	}
+	// Sent last, so a frame cut short is patched again from where it began
+	sse.PatchSignals([]byte(fmt.Sprintf(`{"replayShown": %d}`, payload.At)))
+}
@@ -100,8 +100,12 @@ func (s *Server) routes() {
 		r.Post("/puzzles", s.handleCreatePuzzle)
 		r.Get("/puzzles/{id}", s.handleViewPuzzleSolve)
 		r.Get("/puzzles/{id}/edit", s.handleViewPuzzleEdit)
+		r.Get("/puzzles/{id}/replay", s.handleViewReplay)
+		r.Get("/puzzles/{id}/replay/frame", s.handleReplayFrame)
+		r.Get("/puzzles/{id}/stats", s.handleViewAnalytics)
 		r.Post("/puzzles/{id}/cells/{x}/{y}/set-block", s.handleSetBlock)
 		r.Post("/puzzles/{id}/cells/{x}/{y}/set-block/{state}", s.handleSetBlockState)
+		r.Post("/puzzles/{id}/cells/{x}/{y}/style/{style}", s.handleSetCellStyle)
 		r.Post("/puzzles/{id}/cells/{x}/{y}/update", s.handleUpdateCell)
 		r.Post("/puzzles/{id}/cells/{x}/{y}/focus", s.handleFocusCell)
 
@@ -109,13 +113,28 @@ func (s *Server) routes() {
 		r.Group(func(puz chi.Router) {
 			puz.Use(s.rateLimit(rate.Limit(20), 40))
 			puz.Post("/puzzles/{id}/input", s.handlePuzzleInput)
+			puz.Post("/puzzles/{id}/edit/input", s.handleEditInput)
+			puz.Post("/puzzles/{id}/chat", s.handlePostChat)
 		})
 
 		r.Post("/puzzles/{id}/resize", s.handleResizePuzzle)
+		r.Post("/puzzles/{id}/visibility", s.handleSetVisibility)
+		r.Post("/puzzles/{id}/sessions", s.handleCreateSession)
+		r.Post("/puzzles/{id}/check/{scope}", s.handleCheck)
+		r.Post("/puzzles/{id}/reveal/{scope}", s.handleReveal)
+		r.Post("/puzzles/{id}/autocheck", s.handleSetAutocheck)
 		r.Post("/puzzles/{id}/import", s.handleImportPuzzle)
+		r.Post("/puzzles/{id}/undo", s.handleUndo)
+		r.Post("/puzzles/{id}/redo", s.handleRedo)
+		r.Get("/puzzles/{id}/versions", s.handleListVersions)
+		r.Post("/puzzles/{id}/versions", s.handleSaveVersion)
+		r.Post("/puzzles/{id}/versions/diff", s.handleDiffVersions)
+		r.Post("/puzzles/{id}/versions/{versionID}/restore", s.handleRestoreVersion)
 		r.Get("/puzzles/{id}/clues/{number}/{direction}/edit", s.handleEditClue)
 		r.Post("/puzzles/{id}/clues/{number}/{direction}/save", s.handleSaveClue)
 		r.Post("/puzzles/{id}/clues/{number}/{direction}/focus", s.handleFocusClue)
+		r.Post("/puzzles/{id}/members", s.handleAddMember)
+		r.Post("/puzzles/{id}/members/{userID}/remove", s.handleRemoveMember)
 
 		// Profiles
 		r.Get("/users/{id}", s.handleViewProfile)
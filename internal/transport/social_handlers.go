@@ -44,9 +44,11 @@ func (s *Server) handleViewProfile(w http.ResponseWriter, r *http.Request) {
 		isFollowing = val > 0
 	}
 
-	puzzles, _ := s.Service.Queries.GetPuzzlesByOwner(r.Context(), db.GetPuzzlesByOwnerParams{OwnerID: targetUserID, Limit: 50, Offset: 0})
+	puzzles, _ := s.Service.Queries.GetPuzzlesByOwner(r.Context(), db.GetPuzzlesByOwnerParams{OwnerID: targetUserID, ViewerID: currentUserID, Limit: 50, Offset: 0})
+	solves, _ := s.Service.GetCompletedSolves(r.Context(), targetUserID, currentUserID, 20, 0)
+	stats, _ := s.Service.GetSolverStats(r.Context(), targetUserID, currentUserID)
 
-	components.Layout(components.Profile(currentUser, targetUser, isFollowing, followers, following, limit, offset, puzzles), currentUser, true).Render(r.Context(), w)
+	components.Layout(components.Profile(currentUser, targetUser, isFollowing, followers, following, limit, offset, puzzles, solves, stats), currentUser, true).Render(r.Context(), w)
 }
 
 func (s *Server) handleFollow(w http.ResponseWriter, r *http.Request) {
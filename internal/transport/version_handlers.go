@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"share_word/internal/app"
+	"share_word/internal/web/components"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+	s.patchVersionsPanel(w, r, puzzleID, nil, "")
+}
+
+func (s *Server) handleSaveVersion(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+	userID := s.SessionManager.GetString(r.Context(), "userID")
+
+	var payload struct {
+		Name string `json:"versionName"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	errMsg := ""
+	if _, err := s.Service.SaveVersion(r.Context(), puzzleID, userID, payload.Name); err != nil {
+		errMsg = err.Error()
+	}
+	s.patchVersionsPanel(w, r, puzzleID, nil, errMsg)
+}
+
+func (s *Server) handleDiffVersions(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+
+	var payload struct {
+		From string `json:"diffFrom"`
+		To   string `json:"diffTo"`
+	}
+	if err := datastar.ReadSignals(r, &payload); err != nil {
+		http.Error(w, "invalid signals", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := s.Service.DiffVersions(r.Context(), puzzleID, payload.From, payload.To)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.patchVersionsPanel(w, r, puzzleID, nil, "version not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to compare versions", http.StatusInternalServerError)
+		return
+	}
+	s.patchVersionsPanel(w, r, puzzleID, &diff, "")
+}
+
+func (s *Server) handleRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	puzzleID := chi.URLParam(r, "id")
+	if _, ok := s.requirePuzzleRole(w, r, puzzleID, app.RoleCoConstructor); !ok {
+		return
+	}
+
+	err := s.Service.RestoreVersion(r.Context(), puzzleID, chi.URLParam(r, "versionID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore version for %s: %v", puzzleID, err)
+		http.Error(w, "failed to restore version", http.StatusInternalServerError)
+		return
+	}
+
+	s.Service.PublishGridReplaced(r.Context(), app.EventRestored, puzzleID, s.SessionManager.GetString(r.Context(), "userID"))
+	s.patchVersionsPanel(w, r, puzzleID, nil, "")
+}
+
+func (s *Server) patchVersionsPanel(w http.ResponseWriter, r *http.Request, puzzleID string, diff *app.VersionDiff, errMsg string) {
+	versions, err := s.Service.GetVersions(r.Context(), puzzleID)
+	if err != nil {
+		http.Error(w, "failed to load versions", http.StatusInternalServerError)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r, datastar.WithCompression())
+	if errMsg == "" && diff == nil {
+		sse.PatchSignals([]byte(`{"versionName": ""}`))
+	}
+	sse.PatchElementTempl(components.VersionsPanel(puzzleID, versions, diff, errMsg))
+}
@@ -18,3 +18,12 @@ window.calculatePanDeltas = (el, sidebarOpen) => {
         dy: targetY - (cellRect.top + cellRect.height / 2)
     };
 };
+
+// Mirrors app.FormatSolveTime: m:ss, or h:mm:ss past the hour.
+window.formatSolveTime = (ms) => {
+    const t = Math.max(0, Math.floor(ms / 1000));
+    const h = Math.floor(t / 3600);
+    const m = Math.floor(t / 60) % 60;
+    const s = String(t % 60).padStart(2, '0');
+    return h > 0 ? `${h}:${String(m).padStart(2, '0')}:${s}` : `${m}:${s}`;
+};
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE puzzle_members (
+    puzzle_id   TEXT NOT NULL REFERENCES puzzles(id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role        TEXT NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (puzzle_id, user_id)
+);
+
+CREATE INDEX idx_puzzle_members_user ON puzzle_members(user_id);
+
+-- +goose Down
+DROP TABLE puzzle_members;
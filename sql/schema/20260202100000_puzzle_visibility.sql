@@ -0,0 +1,6 @@
+-- +goose Up
+-- Existing puzzles start private like new ones; owners publish them when ready.
+ALTER TABLE puzzles ADD COLUMN visibility TEXT NOT NULL DEFAULT 'private';
+
+-- +goose Down
+ALTER TABLE puzzles DROP COLUMN visibility;
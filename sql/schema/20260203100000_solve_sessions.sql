@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE solve_sessions (
+    id          TEXT PRIMARY KEY,
+    puzzle_id   TEXT NOT NULL REFERENCES puzzles(id) ON DELETE CASCADE,
+    owner_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind        TEXT NOT NULL DEFAULT 'personal',
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE session_cells (
+    session_id  TEXT NOT NULL REFERENCES solve_sessions(id) ON DELETE CASCADE,
+    x           INTEGER NOT NULL,
+    y           INTEGER NOT NULL,
+    char        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (session_id, x, y)
+);
+
+CREATE INDEX idx_solve_sessions_puzzle ON solve_sessions(puzzle_id);
+CREATE UNIQUE INDEX idx_solve_sessions_personal ON solve_sessions(puzzle_id, owner_id) WHERE kind = 'personal';
+
+-- Letters typed before sessions existed become the puzzle owner's personal progress.
+INSERT INTO solve_sessions (id, puzzle_id, owner_id, kind)
+SELECT lower(hex(randomblob(16))), p.id, p.owner_id, 'personal' FROM puzzles p
+WHERE EXISTS (SELECT 1 FROM cells c WHERE c.puzzle_id = p.id AND c.char != '');
+
+INSERT INTO session_cells (session_id, x, y, char)
+SELECT s.id, c.x, c.y, c.char FROM cells c
+JOIN solve_sessions s ON s.puzzle_id = c.puzzle_id
+WHERE c.char != '';
+
+UPDATE cells SET char = '';
+
+-- +goose Down
+UPDATE cells SET char = COALESCE((
+    SELECT sc.char FROM session_cells sc
+    JOIN solve_sessions s ON s.id = sc.session_id
+    JOIN puzzles p ON p.id = s.puzzle_id
+    WHERE s.puzzle_id = cells.puzzle_id AND s.owner_id = p.owner_id AND s.kind = 'personal'
+      AND sc.x = cells.x AND sc.y = cells.y
+), '');
+
+DROP TABLE session_cells;
+DROP TABLE solve_sessions;
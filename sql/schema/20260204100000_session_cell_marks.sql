@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE session_cells ADD COLUMN is_wrong BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE session_cells ADD COLUMN revealed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE session_cells DROP COLUMN revealed;
+ALTER TABLE session_cells DROP COLUMN is_wrong;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE solve_sessions ADD COLUMN autocheck BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE solve_sessions DROP COLUMN autocheck;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE solve_sessions ADD COLUMN elapsed_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE solve_sessions ADD COLUMN running_since DATETIME;
+ALTER TABLE solve_sessions ADD COLUMN completed_at DATETIME;
+
+-- +goose Down
+ALTER TABLE solve_sessions DROP COLUMN completed_at;
+ALTER TABLE solve_sessions DROP COLUMN running_since;
+ALTER TABLE solve_sessions DROP COLUMN elapsed_ms;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE session_cells ADD COLUMN is_pencil BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE session_cells DROP COLUMN is_pencil;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE cells ADD COLUMN circled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE cells ADD COLUMN shaded BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE cells ADD COLUMN color TEXT NOT NULL DEFAULT '';
+-- Thick edges, any of "T", "R", "B", "L"
+ALTER TABLE cells ADD COLUMN bars TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE cells DROP COLUMN bars;
+ALTER TABLE cells DROP COLUMN color;
+ALTER TABLE cells DROP COLUMN shaded;
+ALTER TABLE cells DROP COLUMN circled;
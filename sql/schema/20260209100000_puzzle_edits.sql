@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE puzzle_edits (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    puzzle_id   TEXT NOT NULL REFERENCES puzzles(id) ON DELETE CASCADE,
+    kind        TEXT NOT NULL,
+    undo_state  TEXT NOT NULL,
+    redo_state  TEXT NOT NULL,
+    undone      BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_puzzle_edits_puzzle ON puzzle_edits(puzzle_id, id);
+
+-- +goose Down
+DROP TABLE puzzle_edits;
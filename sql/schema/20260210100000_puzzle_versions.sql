@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE puzzle_versions (
+    id          TEXT PRIMARY KEY,
+    puzzle_id   TEXT NOT NULL REFERENCES puzzles(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    created_by  TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    state       TEXT NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_puzzle_versions_puzzle ON puzzle_versions(puzzle_id, created_at);
+
+-- +goose Down
+DROP TABLE puzzle_versions;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE chat_messages (
+    id          TEXT PRIMARY KEY,
+    puzzle_id   TEXT NOT NULL REFERENCES puzzles(id) ON DELETE CASCADE,
+    session_id  TEXT NOT NULL DEFAULT '',
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body        TEXT NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_chat_messages_room ON chat_messages(puzzle_id, session_id, created_at);
+
+-- +goose Down
+DROP TABLE chat_messages;
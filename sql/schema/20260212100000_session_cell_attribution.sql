@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE session_cells ADD COLUMN entered_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE session_cells ADD COLUMN entered_at INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE session_cells DROP COLUMN entered_at;
+ALTER TABLE session_cells DROP COLUMN entered_by;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE puzzle_revisions (
+    puzzle_id   TEXT PRIMARY KEY REFERENCES puzzles(id) ON DELETE CASCADE,
+    revision    INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE puzzle_revisions;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Every letter typed, cleared or revealed in a session, for replays.
+-- elapsed_ms is the session's solve clock at the time; entered_at is unix ms.
+CREATE TABLE solve_entries (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id  TEXT NOT NULL REFERENCES solve_sessions(id) ON DELETE CASCADE,
+    x           INTEGER NOT NULL,
+    y           INTEGER NOT NULL,
+    char        TEXT NOT NULL DEFAULT '',
+    is_pencil   BOOLEAN NOT NULL DEFAULT FALSE,
+    revealed    BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id     TEXT NOT NULL DEFAULT '',
+    elapsed_ms  INTEGER NOT NULL DEFAULT 0,
+    entered_at  INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_solve_entries_session ON solve_entries(session_id, id);
+
+-- +goose Down
+DROP TABLE solve_entries;
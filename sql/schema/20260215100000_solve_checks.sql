@@ -0,0 +1,17 @@
+-- +goose Up
+-- Every square checked in a session, for solve analytics. wrong is whether
+-- the letter was wrong; checked_at is unix ms.
+CREATE TABLE solve_checks (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id  TEXT NOT NULL REFERENCES solve_sessions(id) ON DELETE CASCADE,
+    x           INTEGER NOT NULL,
+    y           INTEGER NOT NULL,
+    wrong       BOOLEAN NOT NULL DEFAULT FALSE,
+    elapsed_ms  INTEGER NOT NULL DEFAULT 0,
+    checked_at  INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_solve_checks_session ON solve_checks(session_id, id);
+
+-- +goose Down
+DROP TABLE solve_checks;
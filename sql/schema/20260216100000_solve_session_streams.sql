@@ -0,0 +1,14 @@
+-- +goose Up
+-- Which server instances have a solve session open, so one instance doesn't
+-- pause a timer another is still showing. Each instance refreshes seen_at
+-- (unix ms) while its streams are open; rows that stop being refreshed are
+-- ignored and swept.
+CREATE TABLE solve_session_streams (
+    session_id  TEXT NOT NULL REFERENCES solve_sessions(id) ON DELETE CASCADE,
+    instance_id TEXT NOT NULL,
+    seen_at     INTEGER NOT NULL,
+    PRIMARY KEY (session_id, instance_id)
+);
+
+-- +goose Down
+DROP TABLE solve_session_streams;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- The open tabs on each server instance, so every instance can list who is on
+-- a grid. Each instance refreshes seen_at (unix ms) for its own tabs; rows
+-- that stop being refreshed are ignored and swept. A tab briefly has a row on
+-- two instances when it reconnects to another one.
+CREATE TABLE tab_presence (
+    tab_key     TEXT NOT NULL,
+    instance_id TEXT NOT NULL,
+    puzzle_id   TEXT NOT NULL,
+    session_id  TEXT NOT NULL,
+    mode        TEXT NOT NULL,
+    user_id     TEXT NOT NULL,
+    username    TEXT NOT NULL,
+    color       TEXT NOT NULL,
+    seen_at     INTEGER NOT NULL,
+    PRIMARY KEY (tab_key, instance_id)
+);
+CREATE INDEX idx_tab_presence_grid ON tab_presence(puzzle_id, mode, session_id);
+
+-- Per-tab state, such as where a tab's cursor is, so that any instance can
+-- serve a tab's next request. updated_at is in unix ms.
+CREATE TABLE tab_state (
+    tab_key    TEXT NOT NULL,
+    field      TEXT NOT NULL,
+    value      TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (tab_key, field)
+);
+
+-- +goose Down
+DROP TABLE tab_state;
+DROP TABLE tab_presence;